@@ -2,11 +2,20 @@ package main
 
 import (
 	"log"
+	"os"
 
 	"github.com/radiophysiker/shortener_link/internal/app"
 )
 
 func main() {
+	// shortener migrate [flags] [up | down [N] | version]
+	if len(os.Args) > 1 && os.Args[1] == "migrate" {
+		os.Args = append(os.Args[:1], os.Args[2:]...)
+		if err := app.Migrate(); err != nil {
+			log.Fatalf("cannot migrate the database! %v", err)
+		}
+		return
+	}
 	err := app.Run()
 	if err != nil {
 		log.Fatalf("cannot run the app! %v", err)
@@ -1,15 +1,23 @@
 package app
 
 import (
+	"context"
 	"errors"
 	"fmt"
 	"net/http"
+	"os/signal"
+	"strings"
+	"syscall"
 
+	"github.com/prometheus/client_golang/prometheus"
 	"go.uber.org/zap"
 
+	"github.com/radiophysiker/shortener_link/internal/auth"
+	"github.com/radiophysiker/shortener_link/internal/codegen"
 	"github.com/radiophysiker/shortener_link/internal/config"
 	v1 "github.com/radiophysiker/shortener_link/internal/controller/http/v1"
 	"github.com/radiophysiker/shortener_link/internal/handlers"
+	"github.com/radiophysiker/shortener_link/internal/metrics"
 	"github.com/radiophysiker/shortener_link/internal/repository"
 	"github.com/radiophysiker/shortener_link/internal/usecases"
 )
@@ -33,48 +41,98 @@ func Run() error {
 		return fmt.Errorf("cannot load config: %w", err)
 	}
 	logger.Info("Loaded config", zap.Any("config", cfg))
+	if err := usecases.ValidateRedirectStatus(cfg.RedirectStatus); err != nil {
+		return fmt.Errorf("invalid default redirect status: %w", err)
+	}
+	if cfg.SecretKey == "" {
+		cfg.SecretKey, err = auth.NewSecret()
+		if err != nil {
+			return fmt.Errorf("cannot generate secret key: %w", err)
+		}
+		logger.Warn("Secret key is not set, auth cookies will not survive a restart")
+	}
+	shutdown := &shutdownStack{logger: logger}
+	defer shutdown.close()
 	// Create storage
 	storage, err := repository.NewStorage(cfg)
 	if err != nil {
 		return fmt.Errorf("cannot create storage: %w", err)
 	}
-	defer func(storage repository.Storage) {
-		logger.Info("Closing storage")
-		err := storage.Close()
-		if err != nil {
-			logger.Error("cannot close storage", zap.Error(err))
-		}
-	}(storage)
+	shutdown.push("storage", storage.Close)
+	var pinger handlers.Pinger
+	if p, ok := storage.(handlers.Pinger); ok {
+		pinger = p
+	}
+	if pgStorage, ok := storage.(*repository.PostgresStorage); ok {
+		prometheus.MustRegister(metrics.NewPoolCollector(pgStorage.Stat))
+	}
+	instrumentedStorage := repository.NewInstrumentedStorage(storage)
+	cachedStorage := repository.NewCachedStorage(instrumentedStorage, repository.CacheOptions{
+		Size:        cfg.CacheSize,
+		TTL:         cfg.CacheTTL,
+		NegativeTTL: cfg.CacheNegativeTTL,
+	})
+	prometheus.MustRegister(metrics.NewCacheHitRatio(cachedStorage.HitRatio))
+	codeGenerator, err := codegen.New(cfg, storage)
+	if err != nil {
+		return fmt.Errorf("cannot create code generator: %w", err)
+	}
+	codeLength, err := usecases.NewCodeLengthController(context.Background(), storage, cfg)
+	if err != nil {
+		return fmt.Errorf("cannot create code length controller: %w", err)
+	}
 	// Create use cases
-	useCasesURLShortener := usecases.NewURLShortener(storage, cfg)
+	useCasesURLShortener := usecases.NewURLShortener(cachedStorage, codeGenerator, codeLength, cfg)
+	urlDeleter := usecases.NewURLDeleter(cachedStorage)
+	shutdown.push("URL deleter", urlDeleter.Close)
+	clickRecorder := usecases.NewClickRecorder(storage, cfg.SecretKey)
+	shutdown.push("click recorder", clickRecorder.Close)
+	janitor := usecases.NewExpiredURLJanitor(cachedStorage, cfg.PurgeInterval, cfg.ExpiredRetention)
+	janitor.Start()
+	shutdown.push("expired URL janitor", janitor.Close)
 
 	// Create handlers
 	createHandler := handlers.NewCreateHandler(useCasesURLShortener, cfg)
 	createBatchURLsHandler := handlers.NewCreateBatchURLsHandler(useCasesURLShortener, cfg)
-	getHandler := handlers.NewGetHandler(useCasesURLShortener)
-	pg, err := repository.NewPostgresStorage(cfg.DatabaseDSN)
-	if err != nil {
-		return fmt.Errorf("cannot connect to postgres: %w", err)
-	}
-	defer func(pg *repository.PostgresStorage) {
-		err := pg.Close()
-		if err != nil {
-			logger.Error("cannot close postgres", zap.Error(err))
-		}
-	}(pg)
-	pingHandler := handlers.NewPingHandler(pg)
+	getHandler := handlers.NewGetHandler(useCasesURLShortener, clickRecorder, usecases.NewURLUnlocker(cfg), cfg)
+	userURLsHandler := handlers.NewUserURLsHandler(useCasesURLShortener, cfg)
+	deleteHandler := handlers.NewDeleteHandler(urlDeleter)
+	statsHandler := handlers.NewStatsHandler(useCasesURLShortener, cfg)
+	editHandler := handlers.NewEditHandler(usecases.NewURLEditor(cachedStorage), cfg)
+	pingHandler := handlers.NewPingHandler(pinger)
 
 	// Create router
-	router := v1.NewRouter(createHandler, createBatchURLsHandler, getHandler, pingHandler)
+	router := v1.NewRouter(createHandler, createBatchURLsHandler, getHandler, pingHandler, userURLsHandler, deleteHandler, statsHandler, editHandler, cfg.SecretKey, strings.HasPrefix(cfg.BaseURL, "https://"))
 	// Start server
-	logger.Info("Starting server", zap.String("port", cfg.ServerPort))
-	err = http.ListenAndServe(cfg.ServerPort, router)
-	if err != nil {
-		logger.Error("HTTP server has encountered an error", zap.Error(err))
+	server := &http.Server{
+		Addr:    cfg.ServerPort,
+		Handler: router,
+	}
+	ctx, stop := signal.NotifyContext(context.Background(), syscall.SIGINT, syscall.SIGTERM)
+	defer stop()
+	serveErr := make(chan error, 1)
+	go func() {
+		logger.Info("Starting server", zap.String("port", cfg.ServerPort))
+		serveErr <- server.ListenAndServe()
+	}()
+
+	select {
+	case err := <-serveErr:
 		if !errors.Is(err, http.ErrServerClosed) {
+			logger.Error("HTTP server has encountered an error", zap.Error(err))
 			return fmt.Errorf("HTTP server has encountered an error: %w", err)
 		}
+	case <-ctx.Done():
+		// Restore the default signal behavior, so that a second signal kills the process immediately.
+		stop()
+		logger.Info("Shutting down server", zap.Duration("timeout", cfg.ShutdownTimeout))
+		shutdownCtx, cancel := context.WithTimeout(context.Background(), cfg.ShutdownTimeout)
+		defer cancel()
+		if err := server.Shutdown(shutdownCtx); err != nil {
+			logger.Error("cannot drain in-flight requests", zap.Error(err))
+		}
 	}
+	// The deferred shutdown stops the janitor, flushes background writers and only then closes the storage.
 	logger.Info("Server has been stopped")
 	return nil
 }
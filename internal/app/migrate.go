@@ -0,0 +1,92 @@
+package app
+
+import (
+	"context"
+	"errors"
+	"flag"
+	"fmt"
+	"os/signal"
+	"strconv"
+	"syscall"
+
+	"github.com/jackc/pgx/v4/pgxpool"
+	"go.uber.org/zap"
+
+	"github.com/radiophysiker/shortener_link/internal/config"
+	"github.com/radiophysiker/shortener_link/internal/repository/migrations"
+)
+
+var ErrNoDatabase = errors.New("database DSN is not set")
+
+// Migrate runs the migrate subcommand. The arguments left after the flags select what to do:
+// up (the default) applies pending migrations, down [N] reverts the last N (1 by default) migrations,
+// version prints the current schema version without changing anything in the database.
+func Migrate() error {
+	logger, err := zap.NewProduction()
+	if err != nil {
+		return fmt.Errorf("cannot create logger: %w", err)
+	}
+	zap.ReplaceGlobals(logger)
+	defer func(logger *zap.Logger) {
+		_ = logger.Sync()
+	}(logger)
+	cfg, err := config.LoadConfig()
+	if err != nil {
+		return fmt.Errorf("cannot load config: %w", err)
+	}
+	if cfg.DatabaseDSN == "" {
+		return ErrNoDatabase
+	}
+
+	ctx, stop := signal.NotifyContext(context.Background(), syscall.SIGINT, syscall.SIGTERM)
+	defer stop()
+	pool, err := pgxpool.Connect(ctx, cfg.DatabaseDSN)
+	if err != nil {
+		return fmt.Errorf("cannot connect to database: %w", err)
+	}
+	defer pool.Close()
+	migrator, err := migrations.NewMigrator(pool)
+	if err != nil {
+		return fmt.Errorf("cannot load migrations: %w", err)
+	}
+
+	args := flag.Args()
+	command := "up"
+	if len(args) > 0 {
+		command = args[0]
+	}
+	switch command {
+	case "up":
+		count, err := migrator.Up(ctx)
+		if err != nil {
+			return err
+		}
+		logger.Info("Migrations applied", zap.Int("count", count))
+	case "down":
+		steps := 1
+		if len(args) > 1 {
+			steps, err = strconv.Atoi(args[1])
+			if err != nil || steps < 1 {
+				return fmt.Errorf("invalid number of migrations to revert: %q", args[1])
+			}
+		}
+		count, err := migrator.Down(ctx, steps)
+		if err != nil {
+			return err
+		}
+		logger.Info("Migrations reverted", zap.Int("count", count))
+	case "version":
+		version, err := migrator.Version(ctx)
+		if err != nil {
+			return err
+		}
+		if version == 0 {
+			fmt.Println("no migrations applied")
+			return nil
+		}
+		fmt.Println(version)
+	default:
+		return fmt.Errorf("unknown migrate command %q, expected up, down [N] or version", command)
+	}
+	return nil
+}
@@ -0,0 +1,33 @@
+package app
+
+import "go.uber.org/zap"
+
+// shutdownStack closes the components of the application in the reverse order of their registration,
+// so that every component is closed before the ones it depends on: background writers before the storage.
+type shutdownStack struct {
+	logger  *zap.Logger
+	closers []namedCloser
+}
+
+type namedCloser struct {
+	name  string
+	close func() error
+}
+
+// push registers a component to be closed on shutdown.
+func (s *shutdownStack) push(name string, close func() error) {
+	s.closers = append(s.closers, namedCloser{name: name, close: close})
+}
+
+// close closes the registered components, the last registered first, and waits for each of them.
+// A component that fails to close is logged and does not prevent the rest from being closed.
+func (s *shutdownStack) close() {
+	for i := len(s.closers) - 1; i >= 0; i-- {
+		closer := s.closers[i]
+		s.logger.Info("Closing " + closer.name)
+		if err := closer.close(); err != nil {
+			s.logger.Error("cannot close "+closer.name, zap.Error(err))
+		}
+	}
+	s.closers = nil
+}
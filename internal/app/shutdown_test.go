@@ -0,0 +1,61 @@
+package app
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"go.uber.org/zap"
+
+	"github.com/radiophysiker/shortener_link/internal/usecases"
+)
+
+// slowPurgeRepository purges for a while and records when the purge is over.
+type slowPurgeRepository struct {
+	mu      sync.Mutex
+	started chan struct{}
+	purged  bool
+}
+
+func (r *slowPurgeRepository) PurgeExpiredURLs(ctx context.Context, before time.Time) (int64, error) {
+	select {
+	case r.started <- struct{}{}:
+	default:
+	}
+	time.Sleep(50 * time.Millisecond)
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.purged = true
+	return 0, nil
+}
+
+func TestShutdownClosesStorageAfterJanitor(t *testing.T) {
+	repo := &slowPurgeRepository{started: make(chan struct{}, 1)}
+	var closed []string
+	shutdown := &shutdownStack{logger: zap.NewNop()}
+	shutdown.push("storage", func() error {
+		repo.mu.Lock()
+		defer repo.mu.Unlock()
+		assert.True(t, repo.purged, "the storage should be closed after the purge in progress is over")
+		closed = append(closed, "storage")
+		return nil
+	})
+	shutdown.push("failing", func() error {
+		closed = append(closed, "failing")
+		return errors.New("failed")
+	})
+	janitor := usecases.NewExpiredURLJanitor(repo, time.Millisecond, time.Hour)
+	janitor.Start()
+	shutdown.push("janitor", func() error {
+		closed = append(closed, "janitor")
+		return janitor.Close()
+	})
+	<-repo.started
+
+	shutdown.close()
+	assert.Equal(t, []string{"janitor", "failing", "storage"}, closed,
+		"components should be closed in reverse order, even if one of them fails")
+}
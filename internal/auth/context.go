@@ -0,0 +1,16 @@
+package auth
+
+import "context"
+
+type contextKey struct{}
+
+// WithUserID returns a copy of ctx carrying the given user ID.
+func WithUserID(ctx context.Context, userID string) context.Context {
+	return context.WithValue(ctx, contextKey{}, userID)
+}
+
+// UserIDFromContext returns the user ID stored in ctx, or an empty string if there is none.
+func UserIDFromContext(ctx context.Context) string {
+	userID, _ := ctx.Value(contextKey{}).(string)
+	return userID
+}
@@ -0,0 +1,68 @@
+package auth
+
+import (
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"strings"
+)
+
+const (
+	userIDBytes = 16
+	secretBytes = 32
+)
+
+var ErrInvalidToken = errors.New("invalid auth token")
+
+// NewUserID returns a new random user ID.
+func NewUserID() (string, error) {
+	userID, err := randomHex(userIDBytes)
+	if err != nil {
+		return "", fmt.Errorf("failed to generate user ID: %w", err)
+	}
+	return userID, nil
+}
+
+// NewSecret returns a new random secret key for signing tokens.
+func NewSecret() (string, error) {
+	secret, err := randomHex(secretBytes)
+	if err != nil {
+		return "", fmt.Errorf("failed to generate secret: %w", err)
+	}
+	return secret, nil
+}
+
+// Sign returns a token of the form "<userID>.<signature>" signed with secret.
+func Sign(userID, secret string) string {
+	return userID + "." + signature(userID, secret)
+}
+
+// Verify checks the token signature and returns the user ID it carries.
+func Verify(token, secret string) (string, error) {
+	userID, sig, ok := strings.Cut(token, ".")
+	if !ok || userID == "" {
+		return "", ErrInvalidToken
+	}
+	expected := signature(userID, secret)
+	if !hmac.Equal([]byte(sig), []byte(expected)) {
+		return "", ErrInvalidToken
+	}
+	return userID, nil
+}
+
+func signature(userID, secret string) string {
+	mac := hmac.New(sha256.New, []byte(secret))
+	mac.Write([]byte(userID))
+	return hex.EncodeToString(mac.Sum(nil))
+}
+
+func randomHex(n int) (string, error) {
+	b := make([]byte, n)
+	if _, err := rand.Read(b); err != nil {
+		return "", err
+	}
+	return hex.EncodeToString(b), nil
+}
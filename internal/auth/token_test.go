@@ -0,0 +1,45 @@
+package auth
+
+import (
+	"context"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestSignAndVerify(t *testing.T) {
+	userID, err := NewUserID()
+	require.NoError(t, err, "NewUserID should not return an error")
+
+	token := Sign(userID, "secret")
+	got, err := Verify(token, "secret")
+	require.NoError(t, err, "Verify should accept a token signed with the same secret")
+	assert.Equal(t, userID, got, "Verify should return the signed user ID")
+}
+
+func TestVerifyRejectsInvalidTokens(t *testing.T) {
+	token := Sign("user", "secret")
+	tests := []struct {
+		name  string
+		token string
+	}{
+		{name: "wrong secret", token: Sign("user", "another")},
+		{name: "tampered user ID", token: "admin" + token[len("user"):]},
+		{name: "no signature", token: "user"},
+		{name: "empty user ID", token: "." + token[len("user."):]},
+		{name: "empty token", token: ""},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := Verify(tt.token, "secret")
+			assert.ErrorIs(t, err, ErrInvalidToken)
+		})
+	}
+}
+
+func TestUserIDFromContext(t *testing.T) {
+	assert.Empty(t, UserIDFromContext(context.Background()), "empty context should have no user ID")
+	ctx := WithUserID(context.Background(), "user")
+	assert.Equal(t, "user", UserIDFromContext(ctx))
+}
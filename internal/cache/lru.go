@@ -0,0 +1,109 @@
+// Package cache provides an in-memory LRU cache with per-entry expiry.
+package cache
+
+import (
+	"container/list"
+	"sync"
+	"time"
+)
+
+type entry[K comparable, V any] struct {
+	key       K
+	value     V
+	expiresAt time.Time
+}
+
+// LRU keeps up to a fixed number of entries and evicts the least recently used one when full.
+// Expired entries are dropped when they are looked up or evicted. It is safe for concurrent use.
+type LRU[K comparable, V any] struct {
+	capacity int
+	now      func() time.Time
+
+	mu    sync.Mutex
+	order *list.List // front is the most recently used
+	items map[K]*list.Element
+}
+
+func NewLRU[K comparable, V any](capacity int) *LRU[K, V] {
+	return &LRU[K, V]{
+		capacity: capacity,
+		now:      time.Now,
+		order:    list.New(),
+		items:    make(map[K]*list.Element, capacity),
+	}
+}
+
+// Get returns the value of the key, if it is cached and has not expired.
+func (c *LRU[K, V]) Get(key K) (V, bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	element, ok := c.items[key]
+	if !ok {
+		var zero V
+		return zero, false
+	}
+	e := element.Value.(*entry[K, V])
+	if !c.now().Before(e.expiresAt) {
+		c.remove(element)
+		var zero V
+		return zero, false
+	}
+	c.order.MoveToFront(element)
+	return e.value, true
+}
+
+// Set caches the value for ttl, evicting the least recently used entry if the cache is full.
+func (c *LRU[K, V]) Set(key K, value V, ttl time.Duration) {
+	if c.capacity <= 0 || ttl <= 0 {
+		return
+	}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	expiresAt := c.now().Add(ttl)
+	if element, ok := c.items[key]; ok {
+		e := element.Value.(*entry[K, V])
+		e.value, e.expiresAt = value, expiresAt
+		c.order.MoveToFront(element)
+		return
+	}
+	if c.order.Len() >= c.capacity {
+		c.remove(c.order.Back())
+	}
+	c.items[key] = c.order.PushFront(&entry[K, V]{key: key, value: value, expiresAt: expiresAt})
+}
+
+// Delete removes the key from the cache.
+func (c *LRU[K, V]) Delete(key K) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if element, ok := c.items[key]; ok {
+		c.remove(element)
+	}
+}
+
+// DeleteFunc removes the entries for which del returns true.
+func (c *LRU[K, V]) DeleteFunc(del func(key K, value V) bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	for element := c.order.Front(); element != nil; {
+		next := element.Next()
+		e := element.Value.(*entry[K, V])
+		if del(e.key, e.value) {
+			c.remove(element)
+		}
+		element = next
+	}
+}
+
+// Len returns the number of cached entries, including the expired ones not dropped yet.
+func (c *LRU[K, V]) Len() int {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.order.Len()
+}
+
+// remove removes the element. The caller must hold c.mu.
+func (c *LRU[K, V]) remove(element *list.Element) {
+	c.order.Remove(element)
+	delete(c.items, element.Value.(*entry[K, V]).key)
+}
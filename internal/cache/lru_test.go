@@ -0,0 +1,97 @@
+package cache
+
+import (
+	"fmt"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestLRUEvictsLeastRecentlyUsed(t *testing.T) {
+	c := NewLRU[string, int](2)
+	c.Set("a", 1, time.Minute)
+	c.Set("b", 2, time.Minute)
+	_, ok := c.Get("a")
+	assert.True(t, ok)
+	c.Set("c", 3, time.Minute)
+
+	_, ok = c.Get("b")
+	assert.False(t, ok, "the least recently used entry should be evicted")
+	value, ok := c.Get("a")
+	assert.True(t, ok)
+	assert.Equal(t, 1, value)
+	value, ok = c.Get("c")
+	assert.True(t, ok)
+	assert.Equal(t, 3, value)
+	assert.Equal(t, 2, c.Len())
+}
+
+func TestLRUExpiry(t *testing.T) {
+	now := time.Date(2025, 3, 1, 0, 0, 0, 0, time.UTC)
+	c := NewLRU[string, int](2)
+	c.now = func() time.Time { return now }
+	c.Set("a", 1, time.Minute)
+	c.Set("b", 2, time.Hour)
+
+	now = now.Add(time.Minute)
+	_, ok := c.Get("a")
+	assert.False(t, ok, "an entry should expire after its TTL")
+	_, ok = c.Get("b")
+	assert.True(t, ok)
+	assert.Equal(t, 1, c.Len(), "the expired entry should be dropped")
+}
+
+func TestLRUSetAndDelete(t *testing.T) {
+	c := NewLRU[string, int](2)
+	c.Set("a", 1, time.Minute)
+	c.Set("a", 2, time.Minute)
+	value, _ := c.Get("a")
+	assert.Equal(t, 2, value, "Set should replace the value")
+
+	c.Delete("a")
+	_, ok := c.Get("a")
+	assert.False(t, ok)
+
+	disabled := NewLRU[string, int](0)
+	disabled.Set("a", 1, time.Minute)
+	_, ok = disabled.Get("a")
+	assert.False(t, ok, "a cache of zero capacity should keep nothing")
+}
+
+func TestLRUDeleteFunc(t *testing.T) {
+	c := NewLRU[string, int](4)
+	for i, key := range []string{"a", "b", "c", "d"} {
+		c.Set(key, i, time.Minute)
+	}
+	c.DeleteFunc(func(key string, value int) bool {
+		return value%2 == 1
+	})
+	assert.Equal(t, 2, c.Len())
+	for key, want := range map[string]bool{"a": true, "b": false, "c": true, "d": false} {
+		_, ok := c.Get(key)
+		assert.Equal(t, want, ok, "entry %s", key)
+	}
+}
+
+func TestLRUConcurrentAccess(t *testing.T) {
+	c := NewLRU[string, int](16)
+	var wg sync.WaitGroup
+	for w := range 8 {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for i := range 100 {
+				key := fmt.Sprint(i % 32)
+				c.Set(key, w, time.Minute)
+				c.Get(key)
+				if i%10 == 0 {
+					c.Delete(key)
+				}
+			}
+		}()
+	}
+	wg.Wait()
+	assert.LessOrEqual(t, c.Len(), 16)
+}
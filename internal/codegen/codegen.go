@@ -0,0 +1,114 @@
+package codegen
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sync/atomic"
+
+	"github.com/radiophysiker/shortener_link/internal/config"
+	"github.com/radiophysiker/shortener_link/internal/usecases"
+	"github.com/radiophysiker/shortener_link/internal/utils"
+)
+
+const (
+	StrategyRandom  = "random"
+	StrategyCounter = "counter"
+	StrategyHash    = "hash"
+	StrategySqids   = "sqids"
+)
+
+const (
+	minAlphabetLength = 2
+	// minSqidsAlphabetLength leaves Sqids a base of at least 2 once it takes a character for the prefix.
+	minSqidsAlphabetLength = 3
+)
+
+var (
+	ErrUnknownStrategy = errors.New("unknown code generator strategy")
+	ErrInvalidAlphabet = errors.New("invalid code alphabet")
+	ErrInvalidLength   = errors.New("invalid code length")
+)
+
+// Sequence yields unique, monotonically increasing numbers for the counter-based generators.
+type Sequence interface {
+	NextSequenceValue(ctx context.Context) (int64, error)
+}
+
+// New creates the code generator selected in the config.
+// seq is only used by the counter-based strategies.
+func New(cfg *config.Config, seq Sequence) (usecases.CodeGenerator, error) {
+	if cfg.CodeLength < 1 {
+		return nil, fmt.Errorf("%w: %d", ErrInvalidLength, cfg.CodeLength)
+	}
+	alphabet, err := newAlphabet(cfg.CodeAlphabet)
+	if err != nil {
+		return nil, err
+	}
+	switch cfg.CodeGenerator {
+	case StrategyRandom:
+		return NewRandom(alphabet), nil
+	case StrategyCounter:
+		return NewCounter(alphabet, seq), nil
+	case StrategyHash:
+		return NewHash(alphabet), nil
+	case StrategySqids:
+		if len(alphabet) < minSqidsAlphabetLength {
+			return nil, fmt.Errorf("%w: sqids needs at least %d characters", ErrInvalidAlphabet, minSqidsAlphabetLength)
+		}
+		return NewSqids(alphabet, seq), nil
+	default:
+		return nil, fmt.Errorf("%w: %s", ErrUnknownStrategy, cfg.CodeGenerator)
+	}
+}
+
+// newAlphabet checks that the alphabet only has URL-safe characters, each at most once.
+func newAlphabet(s string) ([]rune, error) {
+	alphabet := []rune(s)
+	if len(alphabet) < minAlphabetLength {
+		return nil, fmt.Errorf("%w: must have at least %d characters", ErrInvalidAlphabet, minAlphabetLength)
+	}
+	if !utils.IsShortString(s) {
+		return nil, fmt.Errorf("%w: may only contain letters, digits, '-' and '_'", ErrInvalidAlphabet)
+	}
+	seen := make(map[rune]struct{}, len(alphabet))
+	for _, r := range alphabet {
+		if _, ok := seen[r]; ok {
+			return nil, fmt.Errorf("%w: duplicate character %q", ErrInvalidAlphabet, r)
+		}
+		seen[r] = struct{}{}
+	}
+	return alphabet, nil
+}
+
+// encode writes n in the positional system over the alphabet.
+func encode(n uint64, alphabet []rune) []rune {
+	base := uint64(len(alphabet))
+	var code []rune
+	for {
+		code = append(code, alphabet[n%base])
+		n /= base
+		if n == 0 {
+			break
+		}
+	}
+	for i, j := 0, len(code)-1; i < j; i, j = i+1, j-1 {
+		code[i], code[j] = code[j], code[i]
+	}
+	return code
+}
+
+// MemorySequence is a Sequence kept in memory, it starts over on every restart.
+type MemorySequence struct {
+	value atomic.Int64
+}
+
+func NewMemorySequence(start int64) *MemorySequence {
+	s := &MemorySequence{}
+	s.value.Store(start)
+	return s
+}
+
+func (s *MemorySequence) NextSequenceValue(ctx context.Context) (int64, error) {
+	return s.value.Add(1), nil
+}
@@ -0,0 +1,111 @@
+package codegen
+
+import (
+	"context"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/radiophysiker/shortener_link/internal/config"
+	"github.com/radiophysiker/shortener_link/internal/usecases"
+)
+
+const testAlphabet = "abcdefghijklmnopqrstuvwxyzABCDEFGHIJKLMNOPQRSTUVWXYZ0123456789"
+
+func TestNew(t *testing.T) {
+	tests := []struct {
+		name    string
+		cfg     config.Config
+		wantErr error
+	}{
+		{name: "random", cfg: config.Config{CodeGenerator: StrategyRandom, CodeLength: 6, CodeAlphabet: testAlphabet}},
+		{name: "counter", cfg: config.Config{CodeGenerator: StrategyCounter, CodeLength: 6, CodeAlphabet: testAlphabet}},
+		{name: "hash", cfg: config.Config{CodeGenerator: StrategyHash, CodeLength: 6, CodeAlphabet: testAlphabet}},
+		{name: "sqids", cfg: config.Config{CodeGenerator: StrategySqids, CodeLength: 6, CodeAlphabet: testAlphabet}},
+		{name: "unknown strategy", cfg: config.Config{CodeGenerator: "uuid", CodeLength: 6, CodeAlphabet: testAlphabet}, wantErr: ErrUnknownStrategy},
+		{name: "zero length", cfg: config.Config{CodeGenerator: StrategyRandom, CodeAlphabet: testAlphabet}, wantErr: ErrInvalidLength},
+		{name: "short alphabet", cfg: config.Config{CodeGenerator: StrategyRandom, CodeLength: 6, CodeAlphabet: "a"}, wantErr: ErrInvalidAlphabet},
+		{name: "unsafe alphabet", cfg: config.Config{CodeGenerator: StrategyRandom, CodeLength: 6, CodeAlphabet: "ab/"}, wantErr: ErrInvalidAlphabet},
+		{name: "short sqids alphabet", cfg: config.Config{CodeGenerator: StrategySqids, CodeLength: 6, CodeAlphabet: "ab"}, wantErr: ErrInvalidAlphabet},
+		{name: "duplicate characters", cfg: config.Config{CodeGenerator: StrategyRandom, CodeLength: 6, CodeAlphabet: "aba"}, wantErr: ErrInvalidAlphabet},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			generator, err := New(&tt.cfg, NewMemorySequence(0))
+			if tt.wantErr != nil {
+				assert.ErrorIs(t, err, tt.wantErr)
+				return
+			}
+			require.NoError(t, err)
+			code, err := generator.Generate(context.Background(), usecases.CodeRequest{FullURL: "https://example.com", Length: 6})
+			require.NoError(t, err)
+			assert.GreaterOrEqual(t, len(code), 6)
+			for _, r := range code {
+				assert.Contains(t, testAlphabet, string(r))
+			}
+		})
+	}
+}
+
+func TestCounter(t *testing.T) {
+	generator := NewCounter([]rune(testAlphabet), NewMemorySequence(60))
+	var codes []string
+	for range 3 {
+		code, err := generator.Generate(context.Background(), usecases.CodeRequest{Length: 4})
+		require.NoError(t, err)
+		codes = append(codes, code)
+	}
+	assert.Equal(t, []string{"aaa9", "aaba", "aabb"}, codes, "codes should be base62 encoded sequence values")
+}
+
+func TestHash(t *testing.T) {
+	generator := NewHash([]rune(testAlphabet))
+	req := usecases.CodeRequest{FullURL: "https://example.com", Length: 8}
+
+	first, err := generator.Generate(context.Background(), req)
+	require.NoError(t, err)
+	second, err := generator.Generate(context.Background(), req)
+	require.NoError(t, err)
+	assert.Equal(t, first, second, "the same URL should get the same code")
+	assert.Len(t, first, 8)
+
+	req.Attempt = 1
+	retry, err := generator.Generate(context.Background(), req)
+	require.NoError(t, err)
+	assert.NotEqual(t, first, retry, "a retry should get a different code")
+
+	long, err := generator.Generate(context.Background(), usecases.CodeRequest{FullURL: "https://example.com", Length: 64})
+	require.NoError(t, err)
+	assert.Len(t, long, 64, "codes longer than one digest should be supported")
+}
+
+func TestSqids(t *testing.T) {
+	generator := NewSqids([]rune(testAlphabet), NewMemorySequence(-1))
+	// Reference values from the Sqids specification for the default alphabet.
+	for _, want := range []string{"bM", "Uk", "gb", "Ef", "Vq"} {
+		code, err := generator.Generate(context.Background(), usecases.CodeRequest{Length: 1})
+		require.NoError(t, err)
+		assert.Equal(t, want, code)
+	}
+
+	code := generator.encode(1, 10)
+	assert.Len(t, code, 10, "codes should be padded to the requested length")
+	assert.Equal(t, "Uk", code[:2], "padding should not change the code prefix")
+}
+
+func TestSqidsShortestAlphabet(t *testing.T) {
+	alphabet := "abc"
+	generator, err := New(&config.Config{CodeGenerator: StrategySqids, CodeLength: 1, CodeAlphabet: alphabet}, NewMemorySequence(0))
+	require.NoError(t, err, "the shortest alphabet allowed for sqids should be accepted")
+	seen := make(map[string]struct{})
+	for range 100 {
+		code, err := generator.Generate(context.Background(), usecases.CodeRequest{Length: 1})
+		require.NoError(t, err)
+		for _, r := range code {
+			assert.Contains(t, alphabet, string(r))
+		}
+		seen[code] = struct{}{}
+	}
+	assert.Len(t, seen, 100, "codes should be unique")
+}
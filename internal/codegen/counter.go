@@ -0,0 +1,31 @@
+package codegen
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/radiophysiker/shortener_link/internal/usecases"
+)
+
+// Counter encodes the next sequence value in the alphabet (base62 with the default one),
+// left-padded to the requested length. Codes are short and never collide, but are guessable.
+type Counter struct {
+	alphabet []rune
+	seq      Sequence
+}
+
+func NewCounter(alphabet []rune, seq Sequence) *Counter {
+	return &Counter{alphabet: alphabet, seq: seq}
+}
+
+func (g *Counter) Generate(ctx context.Context, req usecases.CodeRequest) (string, error) {
+	n, err := g.seq.NextSequenceValue(ctx)
+	if err != nil {
+		return "", fmt.Errorf("failed to get next sequence value: %w", err)
+	}
+	code := encode(uint64(n), g.alphabet)
+	for len(code) < req.Length {
+		code = append([]rune{g.alphabet[0]}, code...)
+	}
+	return string(code), nil
+}
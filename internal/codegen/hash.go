@@ -0,0 +1,47 @@
+package codegen
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/binary"
+	"strconv"
+
+	"github.com/radiophysiker/shortener_link/internal/usecases"
+)
+
+// Hash derives the code from the full URL, so the same URL always gets the same code.
+// On a collision the attempt number is mixed into the hash to get a different code.
+type Hash struct {
+	alphabet []rune
+}
+
+func NewHash(alphabet []rune) *Hash {
+	return &Hash{alphabet: alphabet}
+}
+
+func (g *Hash) Generate(ctx context.Context, req usecases.CodeRequest) (string, error) {
+	input := req.FullURL
+	if req.Attempt > 0 {
+		input += "#" + strconv.Itoa(req.Attempt)
+	}
+	sum := sha256.Sum256([]byte(input))
+
+	base := uint64(len(g.alphabet))
+	code := make([]rune, req.Length)
+	// Take characters from consecutive 64-bit words of the digest, moving to the next word
+	// once the current one is exhausted, and rehashing if the whole digest is used up.
+	word, offset := binary.BigEndian.Uint64(sum[:8]), 8
+	for i := range code {
+		if word < base {
+			if offset+8 > len(sum) {
+				sum = sha256.Sum256(sum[:])
+				offset = 0
+			}
+			word = binary.BigEndian.Uint64(sum[offset : offset+8])
+			offset += 8
+		}
+		code[i] = g.alphabet[word%base]
+		word /= base
+	}
+	return string(code), nil
+}
@@ -0,0 +1,32 @@
+package codegen
+
+import (
+	"context"
+	"crypto/rand"
+	"fmt"
+	"math/big"
+
+	"github.com/radiophysiker/shortener_link/internal/usecases"
+)
+
+// Random generates codes from a cryptographically secure random source.
+type Random struct {
+	alphabet []rune
+}
+
+func NewRandom(alphabet []rune) *Random {
+	return &Random{alphabet: alphabet}
+}
+
+func (g *Random) Generate(ctx context.Context, req usecases.CodeRequest) (string, error) {
+	base := big.NewInt(int64(len(g.alphabet)))
+	code := make([]rune, req.Length)
+	for i := range code {
+		n, err := rand.Int(rand.Reader, base)
+		if err != nil {
+			return "", fmt.Errorf("failed to read random number: %w", err)
+		}
+		code[i] = g.alphabet[n.Int64()]
+	}
+	return string(code), nil
+}
@@ -0,0 +1,69 @@
+package codegen
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/radiophysiker/shortener_link/internal/usecases"
+)
+
+// Sqids encodes the next sequence value the way Sqids (https://sqids.org) encodes a single number:
+// codes are unique like the counter ones, but consecutive values do not look consecutive.
+// The blocklist of the reference implementation is not supported.
+type Sqids struct {
+	alphabet []rune
+	seq      Sequence
+}
+
+// NewSqids returns a Sqids generator. The alphabet must have at least minSqidsAlphabetLength characters.
+func NewSqids(alphabet []rune, seq Sequence) *Sqids {
+	shuffled := make([]rune, len(alphabet))
+	copy(shuffled, alphabet)
+	return &Sqids{alphabet: shuffle(shuffled), seq: seq}
+}
+
+func (g *Sqids) Generate(ctx context.Context, req usecases.CodeRequest) (string, error) {
+	n, err := g.seq.NextSequenceValue(ctx)
+	if err != nil {
+		return "", fmt.Errorf("failed to get next sequence value: %w", err)
+	}
+	return g.encode(uint64(n), req.Length), nil
+}
+
+func (g *Sqids) encode(n uint64, minLength int) string {
+	size := uint64(len(g.alphabet))
+	offset := (uint64(g.alphabet[n%size]) + 1) % size
+
+	alphabet := make([]rune, 0, size)
+	alphabet = append(alphabet, g.alphabet[offset:]...)
+	alphabet = append(alphabet, g.alphabet[:offset]...)
+	prefix := alphabet[0]
+	reverse(alphabet)
+
+	code := []rune{prefix}
+	code = append(code, encode(n, alphabet[1:])...)
+	if len(code) < minLength {
+		code = append(code, alphabet[0])
+		for len(code) < minLength {
+			alphabet = shuffle(alphabet)
+			code = append(code, alphabet[:min(minLength-len(code), len(alphabet))]...)
+		}
+	}
+	return string(code)
+}
+
+// shuffle deterministically permutes the alphabet in place and returns it.
+func shuffle(alphabet []rune) []rune {
+	size := len(alphabet)
+	for i, j := 0, size-1; j > 0; i, j = i+1, j-1 {
+		r := (i*j + int(alphabet[i]) + int(alphabet[j])) % size
+		alphabet[i], alphabet[r] = alphabet[r], alphabet[i]
+	}
+	return alphabet
+}
+
+func reverse(alphabet []rune) {
+	for i, j := 0, len(alphabet)-1; i < j; i, j = i+1, j-1 {
+		alphabet[i], alphabet[j] = alphabet[j], alphabet[i]
+	}
+}
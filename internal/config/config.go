@@ -3,6 +3,7 @@ package config
 import (
 	"flag"
 	"fmt"
+	"time"
 
 	"github.com/caarlos0/env/v11"
 )
@@ -12,6 +13,45 @@ type Config struct {
 	ServerPort      string `env:"SERVER_ADDRESS" envDefault:"localhost:8080"`
 	FileStoragePath string `env:"FILE_STORAGE_PATH" envDefault:"/tmp/short-url-fs.json"`
 	DatabaseDSN     string `env:"DATABASE_DSN"`
+	SecretKey       string `env:"SECRET_KEY" json:"-"`
+	// SQLitePath is the SQLite database file. It is used unless DatabaseDSN is set.
+	SQLitePath string `env:"SQLITE_PATH"`
+	// PurgeInterval is how often expired URLs are purged from the storage.
+	PurgeInterval time.Duration `env:"PURGE_INTERVAL" envDefault:"1h"`
+	// ExpiredRetention is how long expired URLs are kept (and answer 410) before being purged.
+	ExpiredRetention time.Duration `env:"EXPIRED_RETENTION" envDefault:"24h"`
+	// ShutdownTimeout is how long in-flight requests are given to complete on shutdown.
+	ShutdownTimeout time.Duration `env:"SHUTDOWN_TIMEOUT" envDefault:"10s"`
+	// CodeGenerator selects how short codes are generated: random, counter, hash or sqids.
+	CodeGenerator string `env:"CODE_GENERATOR" envDefault:"random"`
+	CodeLength    int    `env:"CODE_LENGTH" envDefault:"6"`
+	CodeAlphabet  string `env:"CODE_ALPHABET" envDefault:"abcdefghijklmnopqrstuvwxyzABCDEFGHIJKLMNOPQRSTUVWXYZ0123456789-_"`
+	// CodeGrowthThreshold is the collision rate above which the code length grows, up to CodeMaxLength.
+	CodeGrowthThreshold float64 `env:"CODE_GROWTH_THRESHOLD" envDefault:"0.1"`
+	CodeMaxLength       int     `env:"CODE_MAX_LENGTH" envDefault:"16"`
+	// FileSyncPolicy defines when file storage writes are flushed to disk: always, interval or never.
+	FileSyncPolicy   string        `env:"FILE_SYNC_POLICY" envDefault:"interval"`
+	FileSyncInterval time.Duration `env:"FILE_SYNC_INTERVAL" envDefault:"1s"`
+	// CompactionRatio is how many times more records than live URLs the storage file may hold before it is compacted.
+	// Zero disables it.
+	CompactionRatio float64 `env:"COMPACTION_RATIO" envDefault:"2"`
+	// CompactionInterval is how often the storage file is compacted regardless of its size. Zero disables it.
+	CompactionInterval time.Duration `env:"COMPACTION_INTERVAL" envDefault:"0s"`
+	// CacheSize is how many short URLs the redirect cache holds. Zero disables the cache.
+	CacheSize int `env:"CACHE_SIZE" envDefault:"10000"`
+	// CacheTTL bounds how long a redirect may stay stale after the short URL is changed by another replica or expires.
+	CacheTTL time.Duration `env:"CACHE_TTL" envDefault:"1m"`
+	// CacheNegativeTTL is how long unknown, deleted, expired and exhausted short URLs are remembered.
+	CacheNegativeTTL time.Duration `env:"CACHE_NEGATIVE_TTL" envDefault:"10s"`
+	// RedirectStatus is the HTTP status short URLs redirect with unless they have their own: 301, 302, 307 or 308.
+	RedirectStatus int `env:"REDIRECT_STATUS" envDefault:"307"`
+	// PermanentRedirectMaxAge is how long clients may cache permanent redirects.
+	PermanentRedirectMaxAge time.Duration `env:"PERMANENT_REDIRECT_MAX_AGE" envDefault:"24h"`
+	// PasswordAttemptsPerURL and PasswordAttemptsPerIP limit the failed password attempts of protected short URLs
+	// per PasswordAttemptWindow. Zero disables the limit.
+	PasswordAttemptsPerURL int           `env:"PASSWORD_ATTEMPTS_PER_URL" envDefault:"20"`
+	PasswordAttemptsPerIP  int           `env:"PASSWORD_ATTEMPTS_PER_IP" envDefault:"5"`
+	PasswordAttemptWindow  time.Duration `env:"PASSWORD_ATTEMPT_WINDOW" envDefault:"15m"`
 }
 
 var cfg Config
@@ -24,6 +64,28 @@ func LoadConfig() (*Config, error) {
 	flag.StringVar(&cfg.ServerPort, "a", cfg.ServerPort, "address and port for result url")
 	flag.StringVar(&cfg.FileStoragePath, "f", cfg.FileStoragePath, "the full name of the file where the data is saved")
 	flag.StringVar(&cfg.DatabaseDSN, "d", cfg.DatabaseDSN, "PostgresSQL DSN")
+	flag.StringVar(&cfg.SQLitePath, "s", cfg.SQLitePath, "SQLite database file")
+	flag.StringVar(&cfg.SecretKey, "k", cfg.SecretKey, "secret key for signing auth cookies")
+	flag.DurationVar(&cfg.PurgeInterval, "purge-interval", cfg.PurgeInterval, "how often expired URLs are purged")
+	flag.DurationVar(&cfg.ExpiredRetention, "expired-retention", cfg.ExpiredRetention, "how long expired URLs are kept before purging")
+	flag.DurationVar(&cfg.ShutdownTimeout, "shutdown-timeout", cfg.ShutdownTimeout, "how long in-flight requests are drained on shutdown")
+	flag.StringVar(&cfg.CodeGenerator, "g", cfg.CodeGenerator, "short code generator: random, counter, hash or sqids")
+	flag.IntVar(&cfg.CodeLength, "code-length", cfg.CodeLength, "length of generated short codes")
+	flag.StringVar(&cfg.CodeAlphabet, "code-alphabet", cfg.CodeAlphabet, "characters used in generated short codes")
+	flag.Float64Var(&cfg.CodeGrowthThreshold, "code-growth-threshold", cfg.CodeGrowthThreshold, "collision rate above which the code length grows")
+	flag.IntVar(&cfg.CodeMaxLength, "code-max-length", cfg.CodeMaxLength, "maximum length of generated short codes")
+	flag.StringVar(&cfg.FileSyncPolicy, "file-sync", cfg.FileSyncPolicy, "when file storage writes are flushed to disk: always, interval or never")
+	flag.DurationVar(&cfg.FileSyncInterval, "file-sync-interval", cfg.FileSyncInterval, "how often file storage writes are flushed with the interval policy")
+	flag.Float64Var(&cfg.CompactionRatio, "compaction-ratio", cfg.CompactionRatio, "ratio of file records to live URLs that triggers compaction, 0 disables it")
+	flag.DurationVar(&cfg.CompactionInterval, "compaction-interval", cfg.CompactionInterval, "how often the storage file is compacted, 0 disables it")
+	flag.IntVar(&cfg.CacheSize, "cache-size", cfg.CacheSize, "how many short URLs the redirect cache holds, 0 disables it")
+	flag.DurationVar(&cfg.CacheTTL, "cache-ttl", cfg.CacheTTL, "how long full URLs are cached")
+	flag.DurationVar(&cfg.CacheNegativeTTL, "cache-negative-ttl", cfg.CacheNegativeTTL, "how long unknown short URLs are cached")
+	flag.IntVar(&cfg.RedirectStatus, "redirect-status", cfg.RedirectStatus, "default redirect status: 301, 302, 307 or 308")
+	flag.DurationVar(&cfg.PermanentRedirectMaxAge, "permanent-redirect-max-age", cfg.PermanentRedirectMaxAge, "how long clients may cache permanent redirects")
+	flag.IntVar(&cfg.PasswordAttemptsPerURL, "password-attempts-per-url", cfg.PasswordAttemptsPerURL, "failed password attempts allowed per short URL per window, 0 disables the limit")
+	flag.IntVar(&cfg.PasswordAttemptsPerIP, "password-attempts-per-ip", cfg.PasswordAttemptsPerIP, "failed password attempts allowed per client IP per window, 0 disables the limit")
+	flag.DurationVar(&cfg.PasswordAttemptWindow, "password-attempt-window", cfg.PasswordAttemptWindow, "window the failed password attempts are counted in")
 	flag.Parse()
 	return &cfg, nil
 }
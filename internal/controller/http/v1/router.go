@@ -2,27 +2,55 @@ package v1
 
 import (
 	"github.com/go-chi/chi"
+	"github.com/prometheus/client_golang/prometheus/promhttp"
 
 	"github.com/radiophysiker/shortener_link/internal/handlers"
 	"github.com/radiophysiker/shortener_link/internal/middleware"
 )
 
 // NewRouter creates a new router for the v1 API.
+// The first path segments of its routes are reserved as custom aliases in usecases.
+// Only the routes that create URLs issue user ID cookies; secureCookies marks the cookies Secure.
 func NewRouter(
 	createHandler *handlers.CreateHandler,
 	createBatchURLsHandler *handlers.CreateBatchURLsHandler,
 	getHandler *handlers.GetHandler,
 	pingHandler *handlers.PingHandler,
+	userURLsHandler *handlers.UserURLsHandler,
+	deleteHandler *handlers.DeleteHandler,
+	statsHandler *handlers.StatsHandler,
+	editHandler *handlers.EditHandler,
+	secretKey string,
+	secureCookies bool,
 ) *chi.Mux {
 	r := chi.NewRouter()
 
+	r.Use(middleware.Metrics())
 	r.Use(middleware.RequestLogger())
 	r.Use(middleware.GzipMiddleware)
 
-	r.Post("/", createHandler.CreateShortURL)
+	r.Group(func(r chi.Router) {
+		r.Use(middleware.Authenticate(secretKey, secureCookies))
+		r.Post("/", createHandler.CreateShortURL)
+		r.Post("/api/shorten", createHandler.CreateShortURLWithJSON)
+		r.Post("/api/shorten/batch", createBatchURLsHandler.CreateBatchURLs)
+	})
 	r.Get("/{id}", getHandler.GetFullURL)
-	r.Post("/api/shorten", createHandler.CreateShortURLWithJSON)
-	r.Post("/api/shorten/batch", createBatchURLsHandler.CreateBatchURLs)
+	r.Get("/{id}/*", getHandler.GetFullURL)
+	r.Post("/{id}", getHandler.UnlockURL)
+	r.Post("/{id}/*", getHandler.UnlockURL)
 	r.Get("/ping", pingHandler.Ping)
+	r.Handle("/metrics", promhttp.Handler())
+	r.Group(func(r chi.Router) {
+		r.Use(middleware.RequireAuth(secretKey))
+		r.Get("/api/urls/{id}/stats", statsHandler.GetClickStats)
+		r.Patch("/api/urls/{id}", editHandler.UpdateURL)
+		r.Get("/api/urls/{id}/history", editHandler.GetURLHistory)
+	})
+	r.Route("/api/user", func(r chi.Router) {
+		r.Use(middleware.RequireAuth(secretKey))
+		r.Get("/urls", userURLsHandler.GetUserURLs)
+		r.Delete("/urls", deleteHandler.DeleteUserURLs)
+	})
 	return r
 }
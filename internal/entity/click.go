@@ -0,0 +1,28 @@
+package entity
+
+import "time"
+
+type (
+	// Click is a single redirect through a short URL.
+	Click struct {
+		ShortURL  string
+		ClickedAt time.Time
+		Referrer  string
+		UserAgent string
+		// IPHash is a salted hash of the client IP, the raw IP is never stored.
+		IPHash string
+	}
+
+	// ClickStats is the click summary of a short URL.
+	ClickStats struct {
+		ShortURL string
+		Total    int64
+		Daily    []DailyClicks
+	}
+
+	// DailyClicks is the number of clicks during one UTC day.
+	DailyClicks struct {
+		Date   time.Time
+		Clicks int64
+	}
+)
@@ -1,8 +1,61 @@
 package entity
 
+import "time"
+
 type (
 	URL struct {
-		ShortURL string
-		FullURL  string
+		ShortURL  string
+		FullURL   string
+		UserID    string
+		IsDeleted bool
+		// ExpiresAt is the moment after which the URL stops redirecting. Zero means it never expires.
+		ExpiresAt time.Time
+		// RedirectStatus is the HTTP status the short URL redirects with. Zero means the configured default.
+		RedirectStatus int
+		// Passthrough says what the redirect carries over from the request. Empty means PassthroughNone.
+		Passthrough PassthroughMode
+		// PasswordHash is the bcrypt hash of the password the short URL is protected with. Empty means no password.
+		PasswordHash string
+		// MaxClicks is how many times the short URL redirects before it is exhausted. Zero means no limit.
+		MaxClicks int64
+		// Redirects is how many times a short URL with MaxClicks has redirected.
+		Redirects int64
 	}
 )
+
+// PassthroughMode says what part of a request to a short URL the redirect carries over to the full URL.
+type PassthroughMode string
+
+const (
+	// PassthroughNone redirects to the full URL as is.
+	PassthroughNone PassthroughMode = "none"
+	// PassthroughQuery merges the query string of the request into the full URL.
+	PassthroughQuery PassthroughMode = "query"
+	// PassthroughPathAndQuery also appends the path following the short code to the full URL.
+	PassthroughPathAndQuery PassthroughMode = "path_and_query"
+)
+
+// IsExpired reports whether the URL has expired at the given moment.
+func (u URL) IsExpired(now time.Time) bool {
+	return !u.ExpiresAt.IsZero() && !now.Before(u.ExpiresAt)
+}
+
+// IsExhausted reports whether the URL has redirected MaxClicks times.
+func (u URL) IsExhausted() bool {
+	return u.MaxClicks > 0 && u.Redirects >= u.MaxClicks
+}
+
+// HasOptions reports whether the URL has an expiry, a redirect status, a passthrough mode, a password
+// or a click limit. Only URLs without options are deduplicated by their full URLs: a URL with options
+// is not interchangeable with another URL of the same full URL.
+func (u URL) HasOptions() bool {
+	return !u.ExpiresAt.IsZero() || u.RedirectStatus != 0 || u.Passthrough != "" || u.PasswordHash != "" || u.MaxClicks != 0
+}
+
+// URLRevision is a change of the full URL a short URL redirects to.
+type URLRevision struct {
+	ShortURL        string
+	PreviousFullURL string
+	FullURL         string
+	ChangedAt       time.Time
+}
@@ -3,26 +3,51 @@ package handlers
 import (
 	"context"
 	"encoding/json"
-	"errors"
+	"fmt"
 	"io"
 	"net/http"
 	"net/url"
+	"time"
 
 	"go.uber.org/zap"
 
 	"github.com/radiophysiker/shortener_link/internal/config"
+	"github.com/radiophysiker/shortener_link/internal/entity"
+	"github.com/radiophysiker/shortener_link/internal/problem"
 	"github.com/radiophysiker/shortener_link/internal/usecases"
 	"github.com/radiophysiker/shortener_link/internal/utils"
 )
 
 type BatchURLRequest struct {
-	CorrelationID string `json:"correlation_id"`
-	OriginalURL   string `json:"original_url"`
+	CorrelationID string     `json:"correlation_id"`
+	OriginalURL   string     `json:"original_url"`
+	Alias         string     `json:"alias,omitempty"`
+	ExpiresAt     *time.Time `json:"expires_at,omitempty"`
+	TTLSeconds    int64      `json:"ttl_seconds,omitempty"`
+	// RedirectType is the HTTP status the short URL redirects with: 301, 302, 307 or 308.
+	RedirectType int `json:"redirect_type,omitempty"`
+	// Passthrough is none, query or path_and_query.
+	Passthrough string `json:"passthrough,omitempty"`
+	// Password protects the short URL: it redirects only after the password is entered.
+	Password string `json:"password,omitempty"`
+	// MaxClicks is how many times the short URL redirects before it answers 410 Gone, 1 makes a one-time link.
+	MaxClicks int64 `json:"max_clicks,omitempty"`
 }
 
+// Statuses of a batch item in BatchURLResponse.
+const (
+	BatchItemCreated  = "created"
+	BatchItemExisting = "existing"
+	BatchItemFailed   = "failed"
+)
+
 type BatchURLResponse struct {
 	CorrelationID string `json:"correlation_id"`
-	ShortURL      string `json:"short_url"`
+	ShortURL      string `json:"short_url,omitempty"`
+	Status        string `json:"status"`
+	// Error and Code describe why a failed item could not be saved.
+	Error string       `json:"error,omitempty"`
+	Code  problem.Code `json:"code,omitempty"`
 }
 
 type CreateBatchURLs interface {
@@ -38,116 +63,102 @@ func NewCreateBatchURLsHandler(createBatchURLs CreateBatchURLs, cfg *config.Conf
 	return &CreateBatchURLsHandler{creator: createBatchURLs, config: cfg}
 }
 
+// CreateBatchURLs creates short URLs from a JSON batch. Errors of the whole batch are reported as problem details,
+// errors of single items are reported in their response items.
 func (h *CreateBatchURLsHandler) CreateBatchURLs(w http.ResponseWriter, r *http.Request) {
 	ctx := r.Context()
 	body, err := io.ReadAll(r.Body)
 	if err != nil {
-		w.WriteHeader(http.StatusInternalServerError)
 		zap.L().Error("cannot read request body", zap.Error(err))
+		problem.Write(w, http.StatusInternalServerError, problem.CodeInternal, "")
 		return
 	}
 
 	if len(body) == 0 {
-		w.WriteHeader(http.StatusBadRequest)
-		_, err := w.Write([]byte("empty request body"))
-		if err != nil {
-			utils.WriteErrorWithCannotWriteResponse(w, err)
-		}
+		problem.Write(w, http.StatusBadRequest, problem.CodeEmptyBody, "empty request body")
 		return
 	}
 
 	var requestItems []BatchURLRequest
 	if err := json.Unmarshal(body, &requestItems); err != nil {
-		w.WriteHeader(http.StatusBadRequest)
-		_, err := w.Write([]byte("invalid json format"))
-		if err != nil {
-			utils.WriteErrorWithCannotWriteResponse(w, err)
-		}
+		problem.Write(w, http.StatusBadRequest, problem.CodeInvalidJSON, "invalid json format")
 		return
 	}
 
 	if len(requestItems) == 0 {
-		w.WriteHeader(http.StatusBadRequest)
-		_, err := w.Write([]byte("empty batch"))
-		if err != nil {
-			utils.WriteErrorWithCannotWriteResponse(w, err)
-		}
+		problem.Write(w, http.StatusBadRequest, problem.CodeEmptyBatch, "empty batch")
 		return
 	}
 
 	batchItems := make([]usecases.BatchItem, 0, len(requestItems))
 	for _, item := range requestItems {
 		if item.OriginalURL == "" {
-			w.WriteHeader(http.StatusBadRequest)
-			_, err := w.Write([]byte("original_url is empty"))
-			if err != nil {
-				utils.WriteErrorWithCannotWriteResponse(w, err)
-			}
+			problem.Write(w, http.StatusBadRequest, problem.CodeEmptyURL, "original_url is empty")
 			return
 		}
 
 		if item.CorrelationID == "" {
-			w.WriteHeader(http.StatusBadRequest)
-			_, err := w.Write([]byte("correlation_id is empty"))
-			if err != nil {
-				utils.WriteErrorWithCannotWriteResponse(w, err)
-			}
+			problem.Write(w, http.StatusBadRequest, problem.CodeEmptyCorrelationID, "correlation_id is empty")
 			return
 		}
 
 		// Validate URL format
 		parsedURL, err := url.Parse(item.OriginalURL)
 		if err != nil || parsedURL.Scheme == "" || parsedURL.Host == "" {
-			w.WriteHeader(http.StatusBadRequest)
 			zap.L().Error("invalid url format", zap.Error(err), zap.String("url", item.OriginalURL))
-			_, err := w.Write([]byte("invalid url format"))
-			if err != nil {
-				utils.WriteErrorWithCannotWriteResponse(w, err)
-			}
+			problem.Write(w, http.StatusBadRequest, problem.CodeInvalidURL, "invalid url format: "+item.OriginalURL)
 			return
 		}
 
 		batchItems = append(batchItems, usecases.BatchItem{
-			CorrelationID: item.CorrelationID,
-			OriginalURL:   item.OriginalURL,
+			CorrelationID:  item.CorrelationID,
+			OriginalURL:    item.OriginalURL,
+			Alias:          item.Alias,
+			Expiry:         newExpiry(item.ExpiresAt, item.TTLSeconds),
+			RedirectStatus: item.RedirectType,
+			Passthrough:    entity.PassthroughMode(item.Passthrough),
+			Password:       item.Password,
+			MaxClicks:      item.MaxClicks,
 		})
 	}
 
 	resultItems, err := h.creator.CreateBatchURLs(ctx, batchItems)
 	if err != nil {
-		if errors.Is(err, usecases.ErrURLConflict) {
-			zap.L().Warn("some URLs in batch already exist", zap.Error(err))
-			if len(resultItems) == 0 {
-				w.WriteHeader(http.StatusConflict)
-				_, err := w.Write([]byte("all URLs in batch already exist"))
-				if err != nil {
-					utils.WriteErrorWithCannotWriteResponse(w, err)
-				}
-				return
-			}
-		} else {
-			// Для других ошибок возвращаем 500
-			zap.L().Error("cannot create batch of short URLs", zap.Error(err))
-			w.WriteHeader(http.StatusInternalServerError)
-			return
-		}
+		problem.WriteError(w, err)
+		return
 	}
 
+	var created, existing int
+	failedStatuses := make(map[int]struct{})
 	responseItems := make([]BatchURLResponse, 0, len(resultItems))
 	baseURL := h.config.BaseURL
 	for _, item := range resultItems {
-		shortURLPath, err := url.JoinPath(baseURL, item.ShortURL)
+		responseItem := BatchURLResponse{CorrelationID: item.CorrelationID}
+		switch {
+		case item.Err != nil:
+			var failedStatus int
+			failedStatus, responseItem.Code = problem.FromError(item.Err)
+			failedStatuses[failedStatus] = struct{}{}
+			responseItem.Status = BatchItemFailed
+			responseItem.Error = item.Err.Error()
+			responseItems = append(responseItems, responseItem)
+			continue
+		case item.Existing:
+			responseItem.Status = BatchItemExisting
+			existing++
+		default:
+			responseItem.Status = BatchItemCreated
+			created++
+		}
+		responseItem.ShortURL, err = url.JoinPath(baseURL, item.ShortURL)
 		if err != nil {
-			zap.L().Error("cannot join base URL and short URL", zap.Error(err))
-			w.WriteHeader(http.StatusInternalServerError)
+			problem.WriteError(w, fmt.Errorf("cannot join base URL and short URL: %w", err))
 			return
 		}
-		responseItems = append(responseItems, BatchURLResponse{
-			CorrelationID: item.CorrelationID,
-			ShortURL:      shortURLPath,
-		})
+		responseItems = append(responseItems, responseItem)
 	}
 
+	status := batchStatus(created, existing, failedStatuses)
 	jsonResp, err := json.Marshal(responseItems)
 	if err != nil {
 		utils.WriteErrorWithCannotWriteResponse(w, err)
@@ -155,9 +166,26 @@ func (h *CreateBatchURLsHandler) CreateBatchURLs(w http.ResponseWriter, r *http.
 	}
 
 	w.Header().Set("Content-Type", "application/json")
-	w.WriteHeader(http.StatusCreated)
+	w.WriteHeader(status)
 	_, err = w.Write(jsonResp)
 	if err != nil {
 		utils.WriteErrorWithCannotWriteResponse(w, err)
 	}
 }
+
+// batchStatus returns the status of a batch response from the outcomes of its items.
+// The batch is a success if at least one of its URLs has been created. If all of them already existed, it is a conflict,
+// and if all of them failed alike, it has the status of their errors. Any other mix is reported as 207 Multi-Status.
+func batchStatus(created, existing int, failedStatuses map[int]struct{}) int {
+	switch {
+	case created > 0:
+		return http.StatusCreated
+	case len(failedStatuses) == 0:
+		return http.StatusConflict
+	case existing == 0 && len(failedStatuses) == 1:
+		for status := range failedStatuses {
+			return status
+		}
+	}
+	return http.StatusMultiStatus
+}
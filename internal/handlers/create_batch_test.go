@@ -0,0 +1,72 @@
+package handlers
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/radiophysiker/shortener_link/internal/config"
+	"github.com/radiophysiker/shortener_link/internal/usecases"
+)
+
+// fakeBatchCreator gives the items the outcomes of the same index.
+type fakeBatchCreator struct {
+	outcomes []usecases.BatchItem
+}
+
+func (f *fakeBatchCreator) CreateBatchURLs(ctx context.Context, items []usecases.BatchItem) ([]usecases.BatchItem, error) {
+	for i := range items {
+		items[i].ShortURL = f.outcomes[i].ShortURL
+		items[i].Existing = f.outcomes[i].Existing
+		items[i].Err = f.outcomes[i].Err
+	}
+	return items, nil
+}
+
+func TestCreateBatchURLsStatus(t *testing.T) {
+	created := usecases.BatchItem{ShortURL: "created"}
+	existing := usecases.BatchItem{ShortURL: "existing", Existing: true}
+	invalidAlias := usecases.BatchItem{Err: usecases.ErrInvalidAlias}
+	aliasTaken := usecases.BatchItem{Err: usecases.ErrAliasTaken}
+	internal := usecases.BatchItem{Err: errors.New("storage is down")}
+
+	tests := []struct {
+		name       string
+		outcomes   []usecases.BatchItem
+		wantStatus int
+	}{
+		{name: "all created", outcomes: []usecases.BatchItem{created, created}, wantStatus: http.StatusCreated},
+		{name: "partly created", outcomes: []usecases.BatchItem{created, existing, invalidAlias}, wantStatus: http.StatusCreated},
+		{name: "all existing", outcomes: []usecases.BatchItem{existing, existing}, wantStatus: http.StatusConflict},
+		{name: "all failed alike", outcomes: []usecases.BatchItem{invalidAlias, invalidAlias}, wantStatus: http.StatusBadRequest},
+		{name: "all failed internally", outcomes: []usecases.BatchItem{internal}, wantStatus: http.StatusInternalServerError},
+		{name: "failed differently", outcomes: []usecases.BatchItem{invalidAlias, aliasTaken}, wantStatus: http.StatusMultiStatus},
+		{name: "existing and failed", outcomes: []usecases.BatchItem{existing, invalidAlias}, wantStatus: http.StatusMultiStatus},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			h := NewCreateBatchURLsHandler(&fakeBatchCreator{outcomes: tt.outcomes}, &config.Config{BaseURL: "http://localhost:8080"})
+			request := make([]BatchURLRequest, len(tt.outcomes))
+			for i := range request {
+				request[i] = BatchURLRequest{CorrelationID: string(rune('a' + i)), OriginalURL: "https://example.com"}
+			}
+			body, err := json.Marshal(request)
+			require.NoError(t, err)
+
+			rec := httptest.NewRecorder()
+			h.CreateBatchURLs(rec, httptest.NewRequest(http.MethodPost, "/api/shorten/batch", strings.NewReader(string(body))))
+			assert.Equal(t, tt.wantStatus, rec.Code)
+
+			var response []BatchURLResponse
+			require.NoError(t, json.Unmarshal(rec.Body.Bytes(), &response))
+			assert.Len(t, response, len(tt.outcomes), "every item should be reported whatever the status")
+		})
+	}
+}
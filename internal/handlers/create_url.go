@@ -4,19 +4,23 @@ import (
 	"context"
 	"encoding/json"
 	"errors"
+	"fmt"
 	"io"
 	"net/http"
 	"net/url"
+	"time"
 
 	"go.uber.org/zap"
 
 	"github.com/radiophysiker/shortener_link/internal/config"
+	"github.com/radiophysiker/shortener_link/internal/entity"
+	"github.com/radiophysiker/shortener_link/internal/problem"
 	"github.com/radiophysiker/shortener_link/internal/usecases"
 	"github.com/radiophysiker/shortener_link/internal/utils"
 )
 
 type URLCreator interface {
-	CreateShortURL(ctx context.Context, fullURL string) (string, error)
+	CreateShortURL(ctx context.Context, fullURL string, opts usecases.CreateOptions) (string, error)
 }
 
 type CreateHandler struct {
@@ -64,12 +68,13 @@ func (h *CreateHandler) CreateShortURL(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	shortURL, err := h.creator.CreateShortURL(ctx, fullURL)
+	shortURL, err := h.creator.CreateShortURL(ctx, fullURL, usecases.CreateOptions{})
 	if err != nil {
-		if errors.Is(err, usecases.ErrURLConflict) {
+		var conflict *usecases.ConflictError
+		if errors.As(err, &conflict) {
 			w.WriteHeader(http.StatusConflict)
 			baseURL := h.config.BaseURL
-			shortURLPath, err := url.JoinPath(baseURL, shortURL)
+			shortURLPath, err := url.JoinPath(baseURL, conflict.ExistingShortURL)
 			if err != nil {
 				zap.L().Error("cannot join base URL and short URL", zap.Error(err))
 				w.WriteHeader(http.StatusInternalServerError)
@@ -117,106 +122,78 @@ func (h *CreateHandler) CreateShortURL(w http.ResponseWriter, r *http.Request) {
 }
 
 type CreateShortURLEntryRequest struct {
-	FullURL string `json:"url"`
+	FullURL    string     `json:"url"`
+	Alias      string     `json:"alias,omitempty"`
+	ExpiresAt  *time.Time `json:"expires_at,omitempty"`
+	TTLSeconds int64      `json:"ttl_seconds,omitempty"`
+	// RedirectType is the HTTP status the short URL redirects with: 301, 302, 307 or 308.
+	RedirectType int `json:"redirect_type,omitempty"`
+	// Passthrough is none, query or path_and_query.
+	Passthrough string `json:"passthrough,omitempty"`
+	// Password protects the short URL: it redirects only after the password is entered.
+	Password string `json:"password,omitempty"`
+	// MaxClicks is how many times the short URL redirects before it answers 410 Gone, 1 makes a one-time link.
+	MaxClicks int64 `json:"max_clicks,omitempty"`
 }
 
 type CreateShortURLEntryResponse struct {
 	ShortURL string `json:"result"`
 }
 
+// CreateShortURLWithJSON creates a short URL from a JSON request. Errors are reported as problem details.
 func (h *CreateHandler) CreateShortURLWithJSON(w http.ResponseWriter, r *http.Request) {
 	body, err := io.ReadAll(r.Body)
 	ctx := r.Context()
 	if err != nil {
-		w.WriteHeader(http.StatusInternalServerError)
 		zap.L().Error("cannot read request body: %v", zap.Error(err))
+		problem.Write(w, http.StatusInternalServerError, problem.CodeInternal, "")
 		return
 	}
 
 	var request CreateShortURLEntryRequest
 	err = json.Unmarshal(body, &request)
 	if err != nil {
-		w.WriteHeader(http.StatusBadRequest)
-		_, err := w.Write([]byte("invalid json format"))
-		if err != nil {
-			utils.WriteErrorWithCannotWriteResponse(w, err)
-		}
+		problem.Write(w, http.StatusBadRequest, problem.CodeInvalidJSON, "invalid json format")
 		return
 	}
 
 	var fullURL = request.FullURL
 	if fullURL == "" {
-		w.WriteHeader(http.StatusBadRequest)
-		_, err = w.Write([]byte("fullURL is empty"))
-		if err != nil {
-			utils.WriteErrorWithCannotWriteResponse(w, err)
-			return
-		}
+		problem.Write(w, http.StatusBadRequest, problem.CodeEmptyURL, "url is empty")
 		return
 	}
 
 	parsedURL, err := url.Parse(fullURL)
 	if err != nil || parsedURL.Scheme == "" || parsedURL.Host == "" {
-		w.WriteHeader(http.StatusBadRequest)
 		zap.L().Error("invalid url format", zap.Error(err), zap.String("url", fullURL))
-		_, err := w.Write([]byte("invalid url format"))
-		if err != nil {
-			utils.WriteErrorWithCannotWriteResponse(w, err)
-			return
-		}
+		problem.Write(w, http.StatusBadRequest, problem.CodeInvalidURL, "invalid url format")
 		return
 	}
 
-	shortURL, err := h.creator.CreateShortURL(ctx, fullURL)
+	opts := usecases.CreateOptions{
+		Alias:          request.Alias,
+		Expiry:         newExpiry(request.ExpiresAt, request.TTLSeconds),
+		RedirectStatus: request.RedirectType,
+		Passthrough:    entity.PassthroughMode(request.Passthrough),
+		Password:       request.Password,
+		MaxClicks:      request.MaxClicks,
+	}
+	shortURL, err := h.creator.CreateShortURL(ctx, fullURL, opts)
+	status := http.StatusCreated
 	if err != nil {
-		if errors.Is(err, usecases.ErrURLConflict) {
-			baseURL := h.config.BaseURL
-			shortURLPath, err := url.JoinPath(baseURL, shortURL)
-			if err != nil {
-				zap.L().Error("cannot join base URL and short URL", zap.Error(err))
-				w.WriteHeader(http.StatusInternalServerError)
-				return
-			}
-			resp := CreateShortURLEntryResponse{ShortURL: shortURLPath}
-			jsonResp, err := json.Marshal(resp)
-			if err != nil {
-				utils.WriteErrorWithCannotWriteResponse(w, err)
-				return
-			}
-			w.Header().Set("Content-Type", "application/json")
-			w.WriteHeader(http.StatusConflict)
-			_, err = w.Write(jsonResp)
-			if err != nil {
-				utils.WriteErrorWithCannotWriteResponse(w, err)
-			}
-			return
-		}
-		if errors.Is(err, usecases.ErrFailedToGenerateShortURL) {
-			w.WriteHeader(http.StatusInternalServerError)
-			zap.L().Error("failed to generate short URL", zap.Error(err), zap.String("url", fullURL))
-			_, err := w.Write([]byte("failed to generate short URL"))
-			if err != nil {
-				utils.WriteErrorWithCannotWriteResponse(w, err)
-			}
-		}
-		if errors.Is(err, usecases.ErrEmptyFullURL) {
-			w.WriteHeader(http.StatusBadRequest)
-			zap.L().Error("url is empty", zap.Error(err), zap.String("url", fullURL))
-			_, err := w.Write([]byte("url is empty"))
-			if err != nil {
-				utils.WriteErrorWithCannotWriteResponse(w, err)
-			}
+		// A conflict is answered with the existing short URL instead of a problem.
+		var conflict *usecases.ConflictError
+		if !errors.As(err, &conflict) {
+			problem.WriteError(w, err)
 			return
 		}
-		zap.L().Error("cannot create short URL: %v", zap.Error(err))
-		w.WriteHeader(http.StatusInternalServerError)
-		return
+		shortURL = conflict.ExistingShortURL
+		status = http.StatusConflict
 	}
 	baseURL := h.config.BaseURL
 	shortURLPath, err := url.JoinPath(baseURL, shortURL)
 	if err != nil {
-		zap.L().Error("cannot join base URL and short URL", zap.Error(err))
-		w.WriteHeader(http.StatusInternalServerError)
+		problem.WriteError(w, fmt.Errorf("cannot join base URL and short URL: %w", err))
 		return
 	}
 	resp := CreateShortURLEntryResponse{ShortURL: shortURLPath}
@@ -226,9 +203,17 @@ func (h *CreateHandler) CreateShortURLWithJSON(w http.ResponseWriter, r *http.Re
 		return
 	}
 	w.Header().Set("Content-Type", "application/json")
-	w.WriteHeader(http.StatusCreated)
+	w.WriteHeader(status)
 	_, err = w.Write(jsonResp)
 	if err != nil {
 		utils.WriteErrorWithCannotWriteResponse(w, err)
 	}
 }
+
+// newExpiry converts the expiry fields of a create request to usecases.Expiry.
+func newExpiry(expiresAt *time.Time, ttlSeconds int64) usecases.Expiry {
+	return usecases.Expiry{
+		ExpiresAt: expiresAt,
+		TTL:       time.Duration(ttlSeconds) * time.Second,
+	}
+}
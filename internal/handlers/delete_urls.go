@@ -0,0 +1,69 @@
+package handlers
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"io"
+	"net/http"
+	"strconv"
+
+	"go.uber.org/zap"
+
+	"github.com/radiophysiker/shortener_link/internal/problem"
+	"github.com/radiophysiker/shortener_link/internal/usecases"
+)
+
+const (
+	// maxDeleteBodySize limits the body of a deletion request. It fits a full batch of the longest aliases.
+	maxDeleteBodySize = 128 << 10
+	// deleteRetryAfter is how many seconds a client is asked to wait when too many deletions are pending.
+	deleteRetryAfter = 1
+)
+
+type URLsDeleter interface {
+	DeleteUserURLs(ctx context.Context, shortURLs []string) error
+}
+
+type DeleteHandler struct {
+	deleter URLsDeleter
+}
+
+func NewDeleteHandler(deleter URLsDeleter) *DeleteHandler {
+	return &DeleteHandler{deleter: deleter}
+}
+
+// DeleteUserURLs schedules the deletion of the short URLs listed in the JSON body and answers 202 Accepted.
+// If too many deletions are pending, it answers 503 Service Unavailable with a Retry-After header.
+func (h *DeleteHandler) DeleteUserURLs(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+	r.Body = http.MaxBytesReader(w, r.Body, maxDeleteBodySize)
+	body, err := io.ReadAll(r.Body)
+	if err != nil {
+		var tooLarge *http.MaxBytesError
+		if errors.As(err, &tooLarge) {
+			problem.Write(w, http.StatusRequestEntityTooLarge, problem.CodeBatchTooLarge, "request body is too large")
+			return
+		}
+		zap.L().Error("cannot read request body", zap.Error(err))
+		problem.Write(w, http.StatusInternalServerError, problem.CodeInternal, "")
+		return
+	}
+
+	var shortURLs []string
+	if err := json.Unmarshal(body, &shortURLs); err != nil {
+		problem.Write(w, http.StatusBadRequest, problem.CodeInvalidJSON, "invalid json format")
+		return
+	}
+
+	err = h.deleter.DeleteUserURLs(ctx, shortURLs)
+	if err != nil {
+		if errors.Is(err, usecases.ErrDeleteQueueFull) {
+			zap.L().Warn("delete queue is full")
+			w.Header().Set("Retry-After", strconv.Itoa(deleteRetryAfter))
+		}
+		problem.WriteError(w, err)
+		return
+	}
+	w.WriteHeader(http.StatusAccepted)
+}
@@ -0,0 +1,53 @@
+package handlers
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+
+	"github.com/radiophysiker/shortener_link/internal/usecases"
+)
+
+type fakeURLsDeleter struct {
+	err       error
+	shortURLs []string
+}
+
+func (f *fakeURLsDeleter) DeleteUserURLs(ctx context.Context, shortURLs []string) error {
+	f.shortURLs = shortURLs
+	return f.err
+}
+
+func TestDeleteUserURLs(t *testing.T) {
+	deleter := &fakeURLsDeleter{}
+	h := NewDeleteHandler(deleter)
+
+	rec := httptest.NewRecorder()
+	h.DeleteUserURLs(rec, httptest.NewRequest(http.MethodDelete, "/api/user/urls", strings.NewReader(`["abc","def"]`)))
+	assert.Equal(t, http.StatusAccepted, rec.Code)
+	assert.Equal(t, []string{"abc", "def"}, deleter.shortURLs)
+}
+
+func TestDeleteUserURLsBodyTooLarge(t *testing.T) {
+	deleter := &fakeURLsDeleter{}
+	h := NewDeleteHandler(deleter)
+
+	body := `["` + strings.Repeat("a", maxDeleteBodySize) + `"]`
+	rec := httptest.NewRecorder()
+	h.DeleteUserURLs(rec, httptest.NewRequest(http.MethodDelete, "/api/user/urls", strings.NewReader(body)))
+	assert.Equal(t, http.StatusRequestEntityTooLarge, rec.Code)
+	assert.Nil(t, deleter.shortURLs, "nothing should be scheduled for deletion")
+}
+
+func TestDeleteUserURLsQueueFull(t *testing.T) {
+	h := NewDeleteHandler(&fakeURLsDeleter{err: usecases.ErrDeleteQueueFull})
+
+	rec := httptest.NewRecorder()
+	h.DeleteUserURLs(rec, httptest.NewRequest(http.MethodDelete, "/api/user/urls", strings.NewReader(`["abc"]`)))
+	assert.Equal(t, http.StatusServiceUnavailable, rec.Code)
+	assert.Equal(t, "1", rec.Header().Get("Retry-After"))
+}
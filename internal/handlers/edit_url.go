@@ -0,0 +1,127 @@
+package handlers
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"time"
+
+	"github.com/go-chi/chi"
+	"go.uber.org/zap"
+
+	"github.com/radiophysiker/shortener_link/internal/config"
+	"github.com/radiophysiker/shortener_link/internal/entity"
+	"github.com/radiophysiker/shortener_link/internal/problem"
+	"github.com/radiophysiker/shortener_link/internal/utils"
+)
+
+type URLEditor interface {
+	UpdateFullURL(ctx context.Context, shortURL, fullURL string) error
+	GetURLHistory(ctx context.Context, shortURL string) (entity.URL, []entity.URLRevision, error)
+}
+
+type UpdateURLRequest struct {
+	FullURL string `json:"url"`
+}
+
+type URLRevisionResponse struct {
+	PreviousURL string    `json:"previous_url"`
+	OriginalURL string    `json:"original_url"`
+	ChangedAt   time.Time `json:"changed_at"`
+}
+
+type URLHistoryResponse struct {
+	ShortURL    string `json:"short_url"`
+	OriginalURL string `json:"original_url"`
+	// Revisions are ordered from the oldest to the newest.
+	Revisions []URLRevisionResponse `json:"revisions"`
+}
+
+type EditHandler struct {
+	editor URLEditor
+	config *config.Config
+}
+
+func NewEditHandler(editor URLEditor, cfg *config.Config) *EditHandler {
+	return &EditHandler{
+		editor: editor,
+		config: cfg,
+	}
+}
+
+// UpdateURL changes the full URL of a short URL owned by the caller and responds with the short URL history.
+func (h *EditHandler) UpdateURL(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+	shortURL := chi.URLParam(r, "id")
+	body, err := io.ReadAll(r.Body)
+	if err != nil {
+		zap.L().Error("cannot read request body", zap.Error(err))
+		problem.Write(w, http.StatusInternalServerError, problem.CodeInternal, "")
+		return
+	}
+
+	var request UpdateURLRequest
+	if err := json.Unmarshal(body, &request); err != nil {
+		problem.Write(w, http.StatusBadRequest, problem.CodeInvalidJSON, "invalid json format")
+		return
+	}
+	if request.FullURL == "" {
+		problem.Write(w, http.StatusBadRequest, problem.CodeEmptyURL, "url is empty")
+		return
+	}
+	parsedURL, err := url.Parse(request.FullURL)
+	if err != nil || parsedURL.Scheme == "" || parsedURL.Host == "" {
+		problem.Write(w, http.StatusBadRequest, problem.CodeInvalidURL, "invalid url format")
+		return
+	}
+
+	if err := h.editor.UpdateFullURL(ctx, shortURL, request.FullURL); err != nil {
+		problem.WriteError(w, err)
+		return
+	}
+	h.GetURLHistory(w, r)
+}
+
+// GetURLHistory responds with the current full URL of a short URL owned by the caller and its revisions.
+func (h *EditHandler) GetURLHistory(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+	shortURL := chi.URLParam(r, "id")
+	u, revisions, err := h.editor.GetURLHistory(ctx, shortURL)
+	if err != nil {
+		problem.WriteError(w, err)
+		return
+	}
+
+	shortURLPath, err := url.JoinPath(h.config.BaseURL, u.ShortURL)
+	if err != nil {
+		problem.WriteError(w, fmt.Errorf("cannot join base URL and short URL: %w", err))
+		return
+	}
+	resp := URLHistoryResponse{
+		ShortURL:    shortURLPath,
+		OriginalURL: u.FullURL,
+		Revisions:   make([]URLRevisionResponse, 0, len(revisions)),
+	}
+	for _, revision := range revisions {
+		resp.Revisions = append(resp.Revisions, URLRevisionResponse{
+			PreviousURL: revision.PreviousFullURL,
+			OriginalURL: revision.FullURL,
+			ChangedAt:   revision.ChangedAt.UTC(),
+		})
+	}
+
+	jsonResp, err := json.Marshal(resp)
+	if err != nil {
+		utils.WriteErrorWithCannotWriteResponse(w, err)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusOK)
+	_, err = w.Write(jsonResp)
+	if err != nil {
+		utils.WriteErrorWithCannotWriteResponse(w, err)
+	}
+}
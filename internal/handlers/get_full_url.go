@@ -3,57 +3,214 @@ package handlers
 import (
 	"context"
 	"errors"
+	"fmt"
+	"math"
+	"net"
 	"net/http"
+	"strconv"
+	"time"
 
 	"github.com/go-chi/chi"
 	"go.uber.org/zap"
 
+	"github.com/radiophysiker/shortener_link/internal/config"
+	"github.com/radiophysiker/shortener_link/internal/entity"
 	"github.com/radiophysiker/shortener_link/internal/usecases"
 	"github.com/radiophysiker/shortener_link/internal/utils"
 )
 
-type URLGetter interface {
-	GetFullURL(ctx context.Context, shortURL string) (string, error)
+type URLResolver interface {
+	ResolveURL(ctx context.Context, shortURL string) (entity.URL, error)
+	ConsumeClick(ctx context.Context, url entity.URL) error
+}
+
+type ClickRecorder interface {
+	RecordClick(shortURL string, info usecases.ClickInfo)
+}
+
+type URLUnlocker interface {
+	Unlock(url entity.URL, password, ip string) error
 }
 
 type GetHandler struct {
-	getter URLGetter
+	resolver URLResolver
+	recorder ClickRecorder
+	unlocker URLUnlocker
+	config   *config.Config
 }
 
-func NewGetHandler(getter URLGetter) *GetHandler {
+func NewGetHandler(resolver URLResolver, recorder ClickRecorder, unlocker URLUnlocker, cfg *config.Config) *GetHandler {
 	return &GetHandler{
-		getter: getter,
+		resolver: resolver,
+		recorder: recorder,
+		unlocker: unlocker,
+		config:   cfg,
 	}
 }
 
+// GetFullURL redirects to the full URL of the short URL. The path following the short code and the query string
+// are carried over as the passthrough mode of the URL allows.
+// A URL protected with a password is answered with a form that submits the password to UnlockURL instead.
 func (h *GetHandler) GetFullURL(w http.ResponseWriter, r *http.Request) {
-	ctx := r.Context()
-	shortURL := chi.URLParam(r, "id")
-	fullURL, err := h.getter.GetFullURL(ctx, shortURL)
+	u, location, ok := h.resolve(w, r)
+	if !ok {
+		return
+	}
+	if u.PasswordHash != "" {
+		writePasswordForm(w, r, http.StatusOK, "")
+		return
+	}
+	h.redirect(w, r, u, location, u.RedirectStatus)
+}
+
+// UnlockURL checks the password submitted by the form of a protected short URL and redirects to its full URL.
+// The redirect is always 303 See Other, so that the client follows it with GET and does not resend the password.
+func (h *GetHandler) UnlockURL(w http.ResponseWriter, r *http.Request) {
+	u, location, ok := h.resolve(w, r)
+	if !ok {
+		return
+	}
+	r.Body = http.MaxBytesReader(w, r.Body, maxPasswordFormSize)
+	if err := r.ParseForm(); err != nil {
+		w.WriteHeader(http.StatusBadRequest)
+		_, err := w.Write([]byte("invalid form"))
+		if err != nil {
+			utils.WriteErrorWithCannotWriteResponse(w, err)
+		}
+		return
+	}
+
+	err := h.unlocker.Unlock(u, r.PostFormValue("password"), clientIP(r))
 	if err != nil {
-		if errors.Is(err, usecases.ErrEmptyShortURL) {
-			zap.L().Error("short url is empty", zap.Error(err), zap.String("shortURL", shortURL))
-			w.WriteHeader(http.StatusBadRequest)
-			_, err := w.Write([]byte("short url is empty"))
-			if err != nil {
-				utils.WriteErrorWithCannotWriteResponse(w, err)
-			}
+		var tooMany *usecases.TooManyAttemptsError
+		if errors.As(err, &tooMany) {
+			zap.L().Warn("too many password attempts", zap.String("shortURL", u.ShortURL), zap.String("ip", clientIP(r)))
+			w.Header().Set("Retry-After", strconv.Itoa(int(math.Ceil(tooMany.RetryAfter.Seconds()))))
+			writePasswordForm(w, r, http.StatusTooManyRequests, "Too many attempts, try again later.")
 			return
 		}
-		if errors.Is(err, usecases.ErrURLNotFound) {
-			zap.L().Error("url is not found for shortURL", zap.Error(err), zap.String("shortURL", shortURL))
-			w.WriteHeader(http.StatusNotFound)
-			_, err := w.Write([]byte("url is not found for " + shortURL))
-			if err != nil {
-				utils.WriteErrorWithCannotWriteResponse(w, err)
-			}
+		if errors.Is(err, usecases.ErrWrongPassword) {
+			zap.L().Info("wrong password", zap.String("shortURL", u.ShortURL))
+			writePasswordForm(w, r, http.StatusUnauthorized, "Wrong password.")
 			return
 		}
-		zap.L().Error("cannot get full URL: %v", zap.Error(err))
+		zap.L().Error("cannot unlock URL", zap.Error(err))
 		w.WriteHeader(http.StatusInternalServerError)
 		return
 	}
-	zap.L().Info("get full URL", zap.String("shortURL", shortURL), zap.String("fullURL", fullURL))
-	w.Header().Set("Location", fullURL)
-	w.WriteHeader(http.StatusTemporaryRedirect)
+	h.redirect(w, r, u, location, http.StatusSeeOther)
+}
+
+// resolve finds the URL the request is for and where it redirects to.
+// If there is none, the error is written to w and ok is false.
+func (h *GetHandler) resolve(w http.ResponseWriter, r *http.Request) (u entity.URL, location string, ok bool) {
+	ctx := r.Context()
+	shortURL := chi.URLParam(r, "id")
+	u, err := h.resolver.ResolveURL(ctx, shortURL)
+	if err == nil {
+		location, err = usecases.RedirectLocation(u, chi.URLParam(r, "*"), r.URL.RawQuery)
+	}
+	if err != nil {
+		writeURLError(w, shortURL, err)
+		return entity.URL{}, "", false
+	}
+	return u, location, true
+}
+
+// redirect takes a click of a URL with a click limit, records the click and redirects to the location
+// with the given status. A URL that has no clicks left is answered with 410 Gone.
+func (h *GetHandler) redirect(w http.ResponseWriter, r *http.Request, u entity.URL, location string, status int) {
+	// The URL may have changed since it was resolved, e.g. a concurrent redirect may have taken its last click.
+	if err := h.resolver.ConsumeClick(r.Context(), u); err != nil {
+		writeURLError(w, u.ShortURL, err)
+		return
+	}
+	zap.L().Info("get full URL", zap.String("shortURL", u.ShortURL), zap.String("location", location))
+	h.recorder.RecordClick(u.ShortURL, usecases.ClickInfo{
+		Referrer:  r.Referer(),
+		UserAgent: r.UserAgent(),
+		IP:        clientIP(r),
+	})
+	w.Header().Set("Location", location)
+	w.Header().Set("Cache-Control", h.cacheControl(u, time.Now()))
+	w.WriteHeader(status)
+}
+
+// writeURLError answers a request for a short URL that does not redirect.
+func writeURLError(w http.ResponseWriter, shortURL string, err error) {
+	if errors.Is(err, usecases.ErrEmptyShortURL) {
+		zap.L().Error("short url is empty", zap.Error(err), zap.String("shortURL", shortURL))
+		w.WriteHeader(http.StatusBadRequest)
+		_, err := w.Write([]byte("short url is empty"))
+		if err != nil {
+			utils.WriteErrorWithCannotWriteResponse(w, err)
+		}
+		return
+	}
+	if errors.Is(err, usecases.ErrURLNotFound) {
+		zap.L().Error("url is not found for shortURL", zap.Error(err), zap.String("shortURL", shortURL))
+		w.WriteHeader(http.StatusNotFound)
+		_, err := w.Write([]byte("url is not found for " + shortURL))
+		if err != nil {
+			utils.WriteErrorWithCannotWriteResponse(w, err)
+		}
+		return
+	}
+	if errors.Is(err, usecases.ErrURLDeleted) {
+		zap.L().Info("url is deleted for shortURL", zap.String("shortURL", shortURL))
+		w.WriteHeader(http.StatusGone)
+		_, err := w.Write([]byte("url is deleted for " + shortURL))
+		if err != nil {
+			utils.WriteErrorWithCannotWriteResponse(w, err)
+		}
+		return
+	}
+	if errors.Is(err, usecases.ErrURLExpired) {
+		zap.L().Info("url is expired for shortURL", zap.String("shortURL", shortURL))
+		w.WriteHeader(http.StatusGone)
+		_, err := w.Write([]byte("url is expired for " + shortURL))
+		if err != nil {
+			utils.WriteErrorWithCannotWriteResponse(w, err)
+		}
+		return
+	}
+	if errors.Is(err, usecases.ErrURLExhausted) {
+		zap.L().Info("url is exhausted for shortURL", zap.String("shortURL", shortURL))
+		w.WriteHeader(http.StatusGone)
+		_, err := w.Write([]byte("url is exhausted for " + shortURL))
+		if err != nil {
+			utils.WriteErrorWithCannotWriteResponse(w, err)
+		}
+		return
+	}
+	zap.L().Error("cannot get full URL: %v", zap.Error(err))
+	w.WriteHeader(http.StatusInternalServerError)
+}
+
+// cacheControl returns the Cache-Control header of a redirect to the URL.
+// Permanent redirects may be cached by clients, though not past the moment the URL expires.
+// Temporary redirects must not be cached, so that every click reaches the server.
+// Redirects of protected URLs are not stored at all, as they reveal the full URL,
+// nor are redirects of URLs with a click limit, as every redirect has to be counted.
+func (h *GetHandler) cacheControl(u entity.URL, now time.Time) string {
+	if u.PasswordHash != "" || u.MaxClicks > 0 {
+		return "no-store"
+	}
+	if !usecases.IsPermanentRedirect(u.RedirectStatus) {
+		return "private, no-cache"
+	}
+	maxAge := h.config.PermanentRedirectMaxAge
+	if !u.ExpiresAt.IsZero() {
+		maxAge = min(maxAge, u.ExpiresAt.Sub(now))
+	}
+	return fmt.Sprintf("public, max-age=%d", max(int64(maxAge.Seconds()), 0))
+}
+
+// clientIP returns the IP address of the client without the port.
+func clientIP(r *http.Request) string {
+	host, _, err := net.SplitHostPort(r.RemoteAddr)
+	if err != nil {
+		return r.RemoteAddr
+	}
+	return host
 }
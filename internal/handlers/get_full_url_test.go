@@ -0,0 +1,172 @@
+package handlers
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/go-chi/chi"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"golang.org/x/crypto/bcrypt"
+
+	"github.com/radiophysiker/shortener_link/internal/config"
+	"github.com/radiophysiker/shortener_link/internal/entity"
+	"github.com/radiophysiker/shortener_link/internal/usecases"
+)
+
+type fakeResolver struct {
+	urls map[string]entity.URL
+}
+
+func (f *fakeResolver) ResolveURL(ctx context.Context, shortURL string) (entity.URL, error) {
+	u, ok := f.urls[shortURL]
+	if !ok {
+		return entity.URL{}, fmt.Errorf("%w for: %s", usecases.ErrURLNotFound, shortURL)
+	}
+	return u, nil
+}
+
+func (f *fakeResolver) ConsumeClick(ctx context.Context, url entity.URL) error {
+	return nil
+}
+
+type fakeRecorder struct {
+	mu     sync.Mutex
+	clicks []string
+}
+
+func (f *fakeRecorder) RecordClick(shortURL string, info usecases.ClickInfo) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.clicks = append(f.clicks, shortURL)
+}
+
+// newGetRouter routes the handler the way the v1 router does.
+func newGetRouter(t *testing.T, cfg *config.Config, cost int) (http.Handler, *fakeRecorder) {
+	t.Helper()
+	hash, err := bcrypt.GenerateFromPassword([]byte("secret"), cost)
+	require.NoError(t, err)
+	resolver := &fakeResolver{urls: map[string]entity.URL{
+		"open":      {ShortURL: "open", FullURL: "https://example.com/open", RedirectStatus: http.StatusTemporaryRedirect},
+		"protected": {ShortURL: "protected", FullURL: "https://example.com/protected", RedirectStatus: http.StatusMovedPermanently, PasswordHash: string(hash)},
+	}}
+	recorder := &fakeRecorder{}
+	h := NewGetHandler(resolver, recorder, usecases.NewURLUnlocker(cfg), cfg)
+	r := chi.NewRouter()
+	r.Get("/{id}", h.GetFullURL)
+	r.Post("/{id}", h.UnlockURL)
+	return r, recorder
+}
+
+func newUnlockRequest(password, remoteAddr string) *http.Request {
+	req := httptest.NewRequest(http.MethodPost, "/protected", strings.NewReader(url.Values{"password": {password}}.Encode()))
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	req.RemoteAddr = remoteAddr
+	return req
+}
+
+func newGetConfig(attemptsPerIP int) *config.Config {
+	return &config.Config{
+		PasswordAttemptsPerURL:  100,
+		PasswordAttemptsPerIP:   attemptsPerIP,
+		PasswordAttemptWindow:   time.Minute,
+		PermanentRedirectMaxAge: time.Hour,
+	}
+}
+
+func TestGetFullURL(t *testing.T) {
+	router, recorder := newGetRouter(t, newGetConfig(5), bcrypt.MinCost)
+
+	rec := httptest.NewRecorder()
+	router.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/open", nil))
+	assert.Equal(t, http.StatusTemporaryRedirect, rec.Code)
+	assert.Equal(t, "https://example.com/open", rec.Header().Get("Location"))
+
+	rec = httptest.NewRecorder()
+	router.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/missing", nil))
+	assert.Equal(t, http.StatusNotFound, rec.Code)
+
+	rec = httptest.NewRecorder()
+	router.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/protected", nil))
+	assert.Equal(t, http.StatusOK, rec.Code)
+	assert.Empty(t, rec.Header().Get("Location"), "a protected URL should not reveal its full URL")
+	assert.NotContains(t, rec.Body.String(), "https://example.com/protected")
+	assert.Contains(t, rec.Body.String(), `<form method="post" action="/protected">`)
+	assert.Equal(t, "no-store", rec.Header().Get("Cache-Control"))
+	assert.Equal(t, []string{"open"}, recorder.clicks, "showing the password form should not count as a click")
+}
+
+func TestUnlockURL(t *testing.T) {
+	router, recorder := newGetRouter(t, newGetConfig(5), bcrypt.MinCost)
+
+	rec := httptest.NewRecorder()
+	router.ServeHTTP(rec, newUnlockRequest("wrong", "10.0.0.1:1234"))
+	assert.Equal(t, http.StatusUnauthorized, rec.Code)
+	assert.Contains(t, rec.Body.String(), "Wrong password.")
+	assert.Equal(t, "no-store", rec.Header().Get("Cache-Control"))
+
+	rec = httptest.NewRecorder()
+	router.ServeHTTP(rec, newUnlockRequest("secret", "10.0.0.1:1234"))
+	assert.Equal(t, http.StatusSeeOther, rec.Code, "the redirect should be followed with GET whatever the status of the URL")
+	assert.Equal(t, "https://example.com/protected", rec.Header().Get("Location"))
+	assert.Equal(t, "no-store", rec.Header().Get("Cache-Control"))
+	assert.Equal(t, []string{"protected"}, recorder.clicks)
+}
+
+func TestUnlockURLTooManyAttempts(t *testing.T) {
+	router, _ := newGetRouter(t, newGetConfig(1), bcrypt.MinCost)
+
+	rec := httptest.NewRecorder()
+	router.ServeHTTP(rec, newUnlockRequest("wrong", "10.0.0.1:1234"))
+	require.Equal(t, http.StatusUnauthorized, rec.Code)
+
+	rec = httptest.NewRecorder()
+	router.ServeHTTP(rec, newUnlockRequest("secret", "10.0.0.1:1234"))
+	assert.Equal(t, http.StatusTooManyRequests, rec.Code)
+	assert.Equal(t, "60", rec.Header().Get("Retry-After"))
+	assert.Equal(t, "no-store", rec.Header().Get("Cache-Control"))
+	assert.Empty(t, rec.Header().Get("Location"))
+
+	rec = httptest.NewRecorder()
+	router.ServeHTTP(rec, newUnlockRequest("secret", "10.0.0.2:1234"))
+	assert.Equal(t, http.StatusSeeOther, rec.Code, "other IP addresses should not be throttled")
+}
+
+func TestUnlockURLThrottlesConcurrentAttempts(t *testing.T) {
+	const limit, clients = 5, 50
+	// The hash is slow enough for the attempts to overlap.
+	router, _ := newGetRouter(t, newGetConfig(limit), bcrypt.MinCost+4)
+
+	var (
+		wg       sync.WaitGroup
+		mu       sync.Mutex
+		statuses = make(map[int]int)
+		start    = make(chan struct{})
+	)
+	for range clients {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			req := newUnlockRequest("wrong", "10.0.0.1:1234")
+			rec := httptest.NewRecorder()
+			<-start
+			router.ServeHTTP(rec, req)
+			mu.Lock()
+			defer mu.Unlock()
+			statuses[rec.Code]++
+		}()
+	}
+	close(start)
+	wg.Wait()
+	assert.Equal(t, map[int]int{
+		http.StatusUnauthorized:    limit,
+		http.StatusTooManyRequests: clients - limit,
+	}, statuses, "only the allowed number of passwords should be checked")
+}
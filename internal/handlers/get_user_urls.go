@@ -0,0 +1,74 @@
+package handlers
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/url"
+
+	"github.com/radiophysiker/shortener_link/internal/config"
+	"github.com/radiophysiker/shortener_link/internal/entity"
+	"github.com/radiophysiker/shortener_link/internal/problem"
+	"github.com/radiophysiker/shortener_link/internal/utils"
+)
+
+type UserURLsGetter interface {
+	GetUserURLs(ctx context.Context) ([]entity.URL, error)
+}
+
+type UserURLResponse struct {
+	ShortURL    string `json:"short_url"`
+	OriginalURL string `json:"original_url"`
+}
+
+type UserURLsHandler struct {
+	getter UserURLsGetter
+	config *config.Config
+}
+
+func NewUserURLsHandler(getter UserURLsGetter, cfg *config.Config) *UserURLsHandler {
+	return &UserURLsHandler{
+		getter: getter,
+		config: cfg,
+	}
+}
+
+func (h *UserURLsHandler) GetUserURLs(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+	urls, err := h.getter.GetUserURLs(ctx)
+	if err != nil {
+		problem.WriteError(w, err)
+		return
+	}
+	if len(urls) == 0 {
+		w.WriteHeader(http.StatusNoContent)
+		return
+	}
+
+	responseItems := make([]UserURLResponse, 0, len(urls))
+	baseURL := h.config.BaseURL
+	for _, u := range urls {
+		shortURLPath, err := url.JoinPath(baseURL, u.ShortURL)
+		if err != nil {
+			problem.WriteError(w, fmt.Errorf("cannot join base URL and short URL: %w", err))
+			return
+		}
+		responseItems = append(responseItems, UserURLResponse{
+			ShortURL:    shortURLPath,
+			OriginalURL: u.FullURL,
+		})
+	}
+
+	jsonResp, err := json.Marshal(responseItems)
+	if err != nil {
+		utils.WriteErrorWithCannotWriteResponse(w, err)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusOK)
+	_, err = w.Write(jsonResp)
+	if err != nil {
+		utils.WriteErrorWithCannotWriteResponse(w, err)
+	}
+}
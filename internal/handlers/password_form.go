@@ -0,0 +1,48 @@
+package handlers
+
+import (
+	"html/template"
+	"net/http"
+
+	"go.uber.org/zap"
+)
+
+// maxPasswordFormSize limits the body of a submitted password form.
+const maxPasswordFormSize = 4 << 10
+
+var passwordForm = template.Must(template.New("password").Parse(`<!DOCTYPE html>
+<html lang="en">
+<head>
+<meta charset="utf-8">
+<meta name="viewport" content="width=device-width, initial-scale=1">
+<meta name="robots" content="noindex">
+<title>Password required</title>
+</head>
+<body>
+<form method="post" action="{{.Action}}">
+<p>This link is protected with a password.</p>
+{{if .Error}}<p role="alert">{{.Error}}</p>{{end}}
+<label>Password <input type="password" name="password" autocomplete="current-password" required autofocus></label>
+<button type="submit">Continue</button>
+</form>
+</body>
+</html>
+`))
+
+// writePasswordForm writes the form that submits the password of a protected short URL to the requested URL.
+func writePasswordForm(w http.ResponseWriter, r *http.Request, status int, errorMessage string) {
+	w.Header().Set("Content-Type", "text/html; charset=utf-8")
+	w.Header().Set("Cache-Control", "no-store")
+	w.Header().Set("X-Frame-Options", "DENY")
+	w.WriteHeader(status)
+	err := passwordForm.Execute(w, struct {
+		Action string
+		Error  string
+	}{
+		Action: r.URL.RequestURI(),
+		Error:  errorMessage,
+	})
+	if err != nil {
+		zap.L().Error("cannot write password form", zap.Error(err))
+	}
+}
@@ -0,0 +1,83 @@
+package handlers
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/url"
+
+	"github.com/go-chi/chi"
+
+	"github.com/radiophysiker/shortener_link/internal/config"
+	"github.com/radiophysiker/shortener_link/internal/entity"
+	"github.com/radiophysiker/shortener_link/internal/problem"
+	"github.com/radiophysiker/shortener_link/internal/utils"
+)
+
+type ClickStatsGetter interface {
+	GetClickStats(ctx context.Context, shortURL string) (entity.ClickStats, error)
+}
+
+type DailyClicksResponse struct {
+	Date   string `json:"date"`
+	Clicks int64  `json:"clicks"`
+}
+
+type ClickStatsResponse struct {
+	ShortURL    string                `json:"short_url"`
+	TotalClicks int64                 `json:"total_clicks"`
+	Daily       []DailyClicksResponse `json:"daily"`
+}
+
+type StatsHandler struct {
+	getter ClickStatsGetter
+	config *config.Config
+}
+
+func NewStatsHandler(getter ClickStatsGetter, cfg *config.Config) *StatsHandler {
+	return &StatsHandler{
+		getter: getter,
+		config: cfg,
+	}
+}
+
+// GetClickStats responds with the click statistics of a short URL owned by the caller.
+func (h *StatsHandler) GetClickStats(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+	shortURL := chi.URLParam(r, "id")
+	stats, err := h.getter.GetClickStats(ctx, shortURL)
+	if err != nil {
+		problem.WriteError(w, err)
+		return
+	}
+
+	shortURLPath, err := url.JoinPath(h.config.BaseURL, stats.ShortURL)
+	if err != nil {
+		problem.WriteError(w, fmt.Errorf("cannot join base URL and short URL: %w", err))
+		return
+	}
+	resp := ClickStatsResponse{
+		ShortURL:    shortURLPath,
+		TotalClicks: stats.Total,
+		Daily:       make([]DailyClicksResponse, 0, len(stats.Daily)),
+	}
+	for _, daily := range stats.Daily {
+		resp.Daily = append(resp.Daily, DailyClicksResponse{
+			Date:   daily.Date.Format("2006-01-02"),
+			Clicks: daily.Clicks,
+		})
+	}
+
+	jsonResp, err := json.Marshal(resp)
+	if err != nil {
+		utils.WriteErrorWithCannotWriteResponse(w, err)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusOK)
+	_, err = w.Write(jsonResp)
+	if err != nil {
+		utils.WriteErrorWithCannotWriteResponse(w, err)
+	}
+}
@@ -0,0 +1,57 @@
+package metrics
+
+import (
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promauto"
+)
+
+const namespace = "shortener"
+
+var (
+	HTTPRequestsTotal = promauto.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "http_requests_total",
+		Help:      "Number of HTTP requests by route, method and status.",
+	}, []string{"route", "method", "status"})
+
+	HTTPRequestDuration = promauto.NewHistogramVec(prometheus.HistogramOpts{
+		Namespace: namespace,
+		Name:      "http_request_duration_seconds",
+		Help:      "HTTP request latency by route, method and status.",
+		Buckets:   prometheus.DefBuckets,
+	}, []string{"route", "method", "status"})
+
+	StorageOperationDuration = promauto.NewHistogramVec(prometheus.HistogramOpts{
+		Namespace: namespace,
+		Name:      "storage_operation_duration_seconds",
+		Help:      "Storage operation latency by operation and result.",
+		Buckets:   prometheus.DefBuckets,
+	}, []string{"operation", "result"})
+
+	ShortURLCollisionsTotal = promauto.NewCounter(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "short_url_collisions_total",
+		Help:      "Number of generated short URLs that were already taken and had to be regenerated.",
+	})
+
+	CacheLookupsTotal = promauto.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "cache_lookups_total",
+		Help:      "Number of short URL cache lookups by result: hit or miss.",
+	}, []string{"result"})
+
+	CodeLength = promauto.NewGauge(prometheus.GaugeOpts{
+		Namespace: namespace,
+		Name:      "code_length",
+		Help:      "Current length of generated short codes.",
+	})
+)
+
+// NewCacheHitRatio returns a gauge reporting the share of short URL lookups answered from the cache.
+func NewCacheHitRatio(ratio func() float64) prometheus.GaugeFunc {
+	return prometheus.NewGaugeFunc(prometheus.GaugeOpts{
+		Namespace: namespace,
+		Name:      "cache_hit_ratio",
+		Help:      "Share of short URL lookups answered from the cache since the start.",
+	}, ratio)
+}
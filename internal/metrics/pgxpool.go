@@ -0,0 +1,65 @@
+package metrics
+
+import (
+	"github.com/jackc/pgx/v4/pgxpool"
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+// PoolCollector exports pgx connection pool statistics.
+type PoolCollector struct {
+	stat func() *pgxpool.Stat
+
+	acquireCount         *prometheus.Desc
+	acquireDuration      *prometheus.Desc
+	acquiredConns        *prometheus.Desc
+	canceledAcquireCount *prometheus.Desc
+	constructingConns    *prometheus.Desc
+	emptyAcquireCount    *prometheus.Desc
+	idleConns            *prometheus.Desc
+	maxConns             *prometheus.Desc
+	totalConns           *prometheus.Desc
+}
+
+// NewPoolCollector returns a collector that reads pool statistics from stat on every scrape.
+func NewPoolCollector(stat func() *pgxpool.Stat) *PoolCollector {
+	desc := func(name, help string) *prometheus.Desc {
+		return prometheus.NewDesc(prometheus.BuildFQName(namespace, "pgxpool", name), help, nil, nil)
+	}
+	return &PoolCollector{
+		stat:                 stat,
+		acquireCount:         desc("acquire_count_total", "Number of successful connection acquires from the pool."),
+		acquireDuration:      desc("acquire_duration_seconds_total", "Total time spent acquiring connections from the pool."),
+		acquiredConns:        desc("acquired_conns", "Number of currently acquired connections."),
+		canceledAcquireCount: desc("canceled_acquire_count_total", "Number of acquires canceled by a context."),
+		constructingConns:    desc("constructing_conns", "Number of connections being constructed."),
+		emptyAcquireCount:    desc("empty_acquire_count_total", "Number of acquires that had to wait for a connection."),
+		idleConns:            desc("idle_conns", "Number of idle connections."),
+		maxConns:             desc("max_conns", "Maximum size of the pool."),
+		totalConns:           desc("total_conns", "Total number of connections in the pool."),
+	}
+}
+
+func (c *PoolCollector) Describe(ch chan<- *prometheus.Desc) {
+	ch <- c.acquireCount
+	ch <- c.acquireDuration
+	ch <- c.acquiredConns
+	ch <- c.canceledAcquireCount
+	ch <- c.constructingConns
+	ch <- c.emptyAcquireCount
+	ch <- c.idleConns
+	ch <- c.maxConns
+	ch <- c.totalConns
+}
+
+func (c *PoolCollector) Collect(ch chan<- prometheus.Metric) {
+	s := c.stat()
+	ch <- prometheus.MustNewConstMetric(c.acquireCount, prometheus.CounterValue, float64(s.AcquireCount()))
+	ch <- prometheus.MustNewConstMetric(c.acquireDuration, prometheus.CounterValue, s.AcquireDuration().Seconds())
+	ch <- prometheus.MustNewConstMetric(c.acquiredConns, prometheus.GaugeValue, float64(s.AcquiredConns()))
+	ch <- prometheus.MustNewConstMetric(c.canceledAcquireCount, prometheus.CounterValue, float64(s.CanceledAcquireCount()))
+	ch <- prometheus.MustNewConstMetric(c.constructingConns, prometheus.GaugeValue, float64(s.ConstructingConns()))
+	ch <- prometheus.MustNewConstMetric(c.emptyAcquireCount, prometheus.CounterValue, float64(s.EmptyAcquireCount()))
+	ch <- prometheus.MustNewConstMetric(c.idleConns, prometheus.GaugeValue, float64(s.IdleConns()))
+	ch <- prometheus.MustNewConstMetric(c.maxConns, prometheus.GaugeValue, float64(s.MaxConns()))
+	ch <- prometheus.MustNewConstMetric(c.totalConns, prometheus.GaugeValue, float64(s.TotalConns()))
+}
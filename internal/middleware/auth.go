@@ -0,0 +1,66 @@
+package middleware
+
+import (
+	"net/http"
+
+	"go.uber.org/zap"
+
+	"github.com/radiophysiker/shortener_link/internal/auth"
+	"github.com/radiophysiker/shortener_link/internal/problem"
+)
+
+const AuthCookieName = "user_id"
+
+// Authenticate reads the signed user ID cookie and puts the user ID into the request context.
+// If the cookie is missing or its signature is invalid, a new user ID is issued in a cookie,
+// so the middleware must only wrap routes that need an identity and are never cached publicly.
+// The cookie is marked Secure when secure is set, that is when the service is served over HTTPS.
+func Authenticate(secret string, secure bool) func(next http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			if cookie, err := r.Cookie(AuthCookieName); err == nil {
+				userID, err := auth.Verify(cookie.Value, secret)
+				if err == nil {
+					next.ServeHTTP(w, r.WithContext(auth.WithUserID(r.Context(), userID)))
+					return
+				}
+				zap.L().Warn("invalid auth cookie", zap.Error(err))
+			}
+
+			userID, err := auth.NewUserID()
+			if err != nil {
+				zap.L().Error("cannot issue user ID", zap.Error(err))
+				w.WriteHeader(http.StatusInternalServerError)
+				return
+			}
+			http.SetCookie(w, &http.Cookie{
+				Name:     AuthCookieName,
+				Value:    auth.Sign(userID, secret),
+				Path:     "/",
+				HttpOnly: true,
+				Secure:   secure,
+				SameSite: http.SameSiteLaxMode,
+			})
+			next.ServeHTTP(w, r.WithContext(auth.WithUserID(r.Context(), userID)))
+		})
+	}
+}
+
+// RequireAuth responds with a 401 Unauthorized problem unless the request carries a validly signed user ID cookie.
+func RequireAuth(secret string) func(next http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			cookie, err := r.Cookie(AuthCookieName)
+			if err != nil {
+				problem.Write(w, http.StatusUnauthorized, problem.CodeUnauthorized, "auth cookie is missing")
+				return
+			}
+			userID, err := auth.Verify(cookie.Value, secret)
+			if err != nil {
+				problem.Write(w, http.StatusUnauthorized, problem.CodeUnauthorized, "auth cookie is invalid")
+				return
+			}
+			next.ServeHTTP(w, r.WithContext(auth.WithUserID(r.Context(), userID)))
+		})
+	}
+}
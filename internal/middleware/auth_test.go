@@ -0,0 +1,55 @@
+package middleware
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/radiophysiker/shortener_link/internal/auth"
+)
+
+func TestAuthenticateIssuesCookie(t *testing.T) {
+	tests := []struct {
+		name   string
+		secure bool
+	}{
+		{name: "http", secure: false},
+		{name: "https", secure: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var userID string
+			handler := Authenticate("secret", tt.secure)(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				userID = auth.UserIDFromContext(r.Context())
+			}))
+			rec := httptest.NewRecorder()
+			handler.ServeHTTP(rec, httptest.NewRequest(http.MethodPost, "/", nil))
+
+			cookies := rec.Result().Cookies()
+			require.Len(t, cookies, 1)
+			cookie := cookies[0]
+			assert.Equal(t, AuthCookieName, cookie.Name)
+			assert.True(t, cookie.HttpOnly)
+			assert.Equal(t, http.SameSiteLaxMode, cookie.SameSite)
+			assert.Equal(t, tt.secure, cookie.Secure)
+			verified, err := auth.Verify(cookie.Value, "secret")
+			require.NoError(t, err)
+			assert.Equal(t, userID, verified, "the issued user ID should be in the request context")
+		})
+	}
+}
+
+func TestAuthenticateKeepsValidCookie(t *testing.T) {
+	handler := Authenticate("secret", false)(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		userID := auth.UserIDFromContext(r.Context())
+		assert.Equal(t, "user", userID)
+	}))
+	req := httptest.NewRequest(http.MethodPost, "/", nil)
+	req.AddCookie(&http.Cookie{Name: AuthCookieName, Value: auth.Sign("user", "secret")})
+	rec := httptest.NewRecorder()
+	handler.ServeHTTP(rec, req)
+	assert.Empty(t, rec.Result().Cookies())
+}
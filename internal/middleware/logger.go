@@ -7,18 +7,25 @@ import (
 	"go.uber.org/zap"
 )
 
+// responseWriter records the status and size of a response for logging and metrics.
 type responseWriter struct {
 	http.ResponseWriter
-	status int
-	size   int
+	status      int
+	size        int
+	wroteHeader bool
 }
 
+// WriteHeader records only the first status, since that is the one actually sent to the client.
 func (rw *responseWriter) WriteHeader(status int) {
-	rw.status = status
+	if !rw.wroteHeader {
+		rw.status = status
+		rw.wroteHeader = true
+	}
 	rw.ResponseWriter.WriteHeader(status)
 }
 
 func (rw *responseWriter) Write(b []byte) (int, error) {
+	rw.wroteHeader = true
 	size, err := rw.ResponseWriter.Write(b)
 	rw.size += size
 	return size, err
@@ -31,10 +38,9 @@ func RequestLogger() func(next http.Handler) http.Handler {
 
 			wrapped := &responseWriter{
 				ResponseWriter: w,
-				status:        http.StatusOK,
+				status:         http.StatusOK,
 			}
 
-
 			next.ServeHTTP(wrapped, r)
 
 			zap.L().Info("HTTP Request",
@@ -46,4 +52,4 @@ func RequestLogger() func(next http.Handler) http.Handler {
 			)
 		})
 	}
-}
\ No newline at end of file
+}
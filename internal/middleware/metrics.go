@@ -0,0 +1,38 @@
+package middleware
+
+import (
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/go-chi/chi"
+
+	"github.com/radiophysiker/shortener_link/internal/metrics"
+)
+
+// unmatchedRoute labels requests that did not match any route, to keep the label cardinality bounded.
+const unmatchedRoute = "unmatched"
+
+// Metrics records request count and latency by route pattern, method and status.
+func Metrics() func(next http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			start := time.Now()
+
+			wrapped := &responseWriter{
+				ResponseWriter: w,
+				status:         http.StatusOK,
+			}
+
+			next.ServeHTTP(wrapped, r)
+
+			route := unmatchedRoute
+			if rctx := chi.RouteContext(r.Context()); rctx != nil && rctx.RoutePattern() != "" {
+				route = rctx.RoutePattern()
+			}
+			status := strconv.Itoa(wrapped.status)
+			metrics.HTTPRequestsTotal.WithLabelValues(route, r.Method, status).Inc()
+			metrics.HTTPRequestDuration.WithLabelValues(route, r.Method, status).Observe(time.Since(start).Seconds())
+		})
+	}
+}
@@ -0,0 +1,35 @@
+package middleware
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/go-chi/chi"
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	"github.com/stretchr/testify/assert"
+
+	"github.com/radiophysiker/shortener_link/internal/metrics"
+)
+
+func TestMetricsLabelsByRoutePattern(t *testing.T) {
+	r := chi.NewRouter()
+	r.Use(Metrics())
+	r.Get("/{id}", func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusTemporaryRedirect)
+		// A second WriteHeader is ignored by net/http and must not change the recorded status.
+		w.WriteHeader(http.StatusInternalServerError)
+	})
+
+	counter := metrics.HTTPRequestsTotal.WithLabelValues("/{id}", http.MethodGet, "307")
+	before := testutil.ToFloat64(counter)
+	for _, path := range []string{"/abc", "/def"} {
+		r.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest(http.MethodGet, path, nil))
+	}
+	assert.Equal(t, before+2, testutil.ToFloat64(counter))
+
+	unmatched := metrics.HTTPRequestsTotal.WithLabelValues(unmatchedRoute, http.MethodGet, "404")
+	before = testutil.ToFloat64(unmatched)
+	r.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest(http.MethodGet, "/a/b/c", nil))
+	assert.Equal(t, before+1, testutil.ToFloat64(unmatched))
+}
@@ -0,0 +1,130 @@
+// Package problem writes API errors as RFC 7807 problem details with stable machine-readable codes.
+package problem
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+
+	"go.uber.org/zap"
+
+	"github.com/radiophysiker/shortener_link/internal/usecases"
+)
+
+const ContentType = "application/problem+json"
+
+// Code identifies the kind of an error. Codes are part of the API: clients branch on them,
+// so an existing code must never be renamed or reused for another error.
+type Code string
+
+const (
+	CodeEmptyBody          Code = "empty_body"
+	CodeInvalidJSON        Code = "invalid_json"
+	CodeEmptyBatch         Code = "empty_batch"
+	CodeBatchTooLarge      Code = "batch_too_large"
+	CodeEmptyURL           Code = "empty_url"
+	CodeInvalidURL         Code = "invalid_url"
+	CodeEmptyCorrelationID Code = "empty_correlation_id"
+	CodeEmptyShortURL      Code = "empty_short_url"
+	CodeInvalidAlias       Code = "invalid_alias"
+	CodeAliasReserved      Code = "alias_reserved"
+	CodeAliasTaken         Code = "alias_taken"
+	CodeInvalidExpiry      Code = "invalid_expiry"
+	CodeInvalidRedirect    Code = "invalid_redirect_type"
+	CodeInvalidPassthrough Code = "invalid_passthrough"
+	CodeInvalidPassword    Code = "invalid_password"
+	CodeInvalidMaxClicks   Code = "invalid_max_clicks"
+	CodeURLConflict        Code = "url_conflict"
+	CodeURLNotFound        Code = "url_not_found"
+	CodeURLDeleted         Code = "url_deleted"
+	CodeURLExpired         Code = "url_expired"
+	CodeURLExhausted       Code = "url_exhausted"
+	CodeUnauthorized       Code = "unauthorized"
+	CodeNotURLOwner        Code = "not_url_owner"
+	CodeGenerationFailed   Code = "short_url_generation_failed"
+	CodeDeleteQueueFull    Code = "delete_queue_full"
+	CodeInternal           Code = "internal_error"
+)
+
+// Details is an RFC 7807 problem details object extended with Code.
+type Details struct {
+	// Type is about:blank, since Code already identifies the problem.
+	Type   string `json:"type"`
+	Title  string `json:"title"`
+	Status int    `json:"status"`
+	Detail string `json:"detail,omitempty"`
+	Code   Code   `json:"code"`
+}
+
+// errorMappings maps the usecases errors to the status and code they are reported with.
+// The first matching entry wins, so more specific errors go first.
+var errorMappings = []struct {
+	err    error
+	status int
+	code   Code
+}{
+	{usecases.ErrEmptyBatch, http.StatusBadRequest, CodeEmptyBatch},
+	{usecases.ErrBatchTooLarge, http.StatusRequestEntityTooLarge, CodeBatchTooLarge},
+	{usecases.ErrEmptyFullURL, http.StatusBadRequest, CodeEmptyURL},
+	{usecases.ErrEmptyShortURL, http.StatusBadRequest, CodeEmptyShortURL},
+	{usecases.ErrInvalidAlias, http.StatusBadRequest, CodeInvalidAlias},
+	{usecases.ErrAliasReserved, http.StatusBadRequest, CodeAliasReserved},
+	{usecases.ErrInvalidExpiry, http.StatusBadRequest, CodeInvalidExpiry},
+	{usecases.ErrInvalidRedirectType, http.StatusBadRequest, CodeInvalidRedirect},
+	{usecases.ErrInvalidPassthrough, http.StatusBadRequest, CodeInvalidPassthrough},
+	{usecases.ErrInvalidPassword, http.StatusBadRequest, CodeInvalidPassword},
+	{usecases.ErrInvalidMaxClicks, http.StatusBadRequest, CodeInvalidMaxClicks},
+	{usecases.ErrAliasTaken, http.StatusConflict, CodeAliasTaken},
+	{usecases.ErrURLConflict, http.StatusConflict, CodeURLConflict},
+	{usecases.ErrURLNotFound, http.StatusNotFound, CodeURLNotFound},
+	{usecases.ErrURLDeleted, http.StatusGone, CodeURLDeleted},
+	{usecases.ErrURLExpired, http.StatusGone, CodeURLExpired},
+	{usecases.ErrURLExhausted, http.StatusGone, CodeURLExhausted},
+	{usecases.ErrEmptyUserID, http.StatusUnauthorized, CodeUnauthorized},
+	{usecases.ErrNotURLOwner, http.StatusForbidden, CodeNotURLOwner},
+	{usecases.ErrFailedToGenerateShortURL, http.StatusInternalServerError, CodeGenerationFailed},
+	{usecases.ErrDeleteQueueFull, http.StatusServiceUnavailable, CodeDeleteQueueFull},
+}
+
+// FromError returns the status and code err is reported with.
+// Unknown errors are internal errors.
+func FromError(err error) (int, Code) {
+	for _, m := range errorMappings {
+		if errors.Is(err, m.err) {
+			return m.status, m.code
+		}
+	}
+	return http.StatusInternalServerError, CodeInternal
+}
+
+// Write writes a problem with the given status, code and human-readable detail.
+func Write(w http.ResponseWriter, status int, code Code, detail string) {
+	body, err := json.Marshal(Details{
+		Type:   "about:blank",
+		Title:  http.StatusText(status),
+		Status: status,
+		Detail: detail,
+		Code:   code,
+	})
+	if err != nil {
+		zap.L().Error("cannot marshal problem details", zap.Error(err))
+		w.WriteHeader(http.StatusInternalServerError)
+		return
+	}
+	w.Header().Set("Content-Type", ContentType)
+	w.WriteHeader(status)
+	if _, err := w.Write(body); err != nil {
+		zap.L().Error("cannot write response", zap.Error(err))
+	}
+}
+
+// WriteError writes err as a problem. The text of internal errors is only logged, never sent to the client.
+func WriteError(w http.ResponseWriter, err error) {
+	status, code := FromError(err)
+	if code == CodeInternal || code == CodeGenerationFailed {
+		zap.L().Error("internal error", zap.Error(err))
+		Write(w, status, code, "")
+		return
+	}
+	Write(w, status, code, err.Error())
+}
@@ -0,0 +1,66 @@
+package problem
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/radiophysiker/shortener_link/internal/usecases"
+)
+
+func TestFromError(t *testing.T) {
+	tests := []struct {
+		err    error
+		status int
+		code   Code
+	}{
+		{fmt.Errorf("%w for: abc", usecases.ErrURLNotFound), http.StatusNotFound, CodeURLNotFound},
+		{&usecases.ConflictError{ExistingShortURL: "abc"}, http.StatusConflict, CodeURLConflict},
+		{fmt.Errorf("%w: abc", usecases.ErrAliasTaken), http.StatusConflict, CodeAliasTaken},
+		{usecases.ErrURLExpired, http.StatusGone, CodeURLExpired},
+		{fmt.Errorf("%w: too many URLs", usecases.ErrBatchTooLarge), http.StatusRequestEntityTooLarge, CodeBatchTooLarge},
+		{usecases.ErrDeleteQueueFull, http.StatusServiceUnavailable, CodeDeleteQueueFull},
+		{errors.New("connection refused"), http.StatusInternalServerError, CodeInternal},
+	}
+	for _, tt := range tests {
+		t.Run(tt.err.Error(), func(t *testing.T) {
+			status, code := FromError(tt.err)
+			assert.Equal(t, tt.status, status)
+			assert.Equal(t, tt.code, code)
+		})
+	}
+}
+
+func TestWriteError(t *testing.T) {
+	w := httptest.NewRecorder()
+	WriteError(w, fmt.Errorf("%w: abc", usecases.ErrAliasTaken))
+
+	assert.Equal(t, http.StatusConflict, w.Code)
+	assert.Equal(t, ContentType, w.Header().Get("Content-Type"))
+	var details Details
+	require.NoError(t, json.Unmarshal(w.Body.Bytes(), &details))
+	assert.Equal(t, Details{
+		Type:   "about:blank",
+		Title:  "Conflict",
+		Status: http.StatusConflict,
+		Detail: "alias is already taken: abc",
+		Code:   CodeAliasTaken,
+	}, details)
+}
+
+func TestWriteErrorHidesInternalErrors(t *testing.T) {
+	w := httptest.NewRecorder()
+	WriteError(w, errors.New("password authentication failed for user postgres"))
+
+	assert.Equal(t, http.StatusInternalServerError, w.Code)
+	var details Details
+	require.NoError(t, json.Unmarshal(w.Body.Bytes(), &details))
+	assert.Equal(t, CodeInternal, details.Code)
+	assert.Empty(t, details.Detail, "internal error text should not be sent to the client")
+}
@@ -0,0 +1,202 @@
+package repository
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"golang.org/x/sync/singleflight"
+
+	"github.com/radiophysiker/shortener_link/internal/cache"
+	"github.com/radiophysiker/shortener_link/internal/entity"
+	"github.com/radiophysiker/shortener_link/internal/metrics"
+	"github.com/radiophysiker/shortener_link/internal/usecases"
+)
+
+type CacheOptions struct {
+	// Size is the maximum number of cached short URLs. Zero disables the cache.
+	Size int
+	// TTL is how long a URL is cached. It bounds how stale a redirect can get when the short URL
+	// is changed elsewhere, e.g. by another replica. A URL is never cached past its expiry.
+	TTL time.Duration
+	// NegativeTTL is how long an unknown, deleted, expired or exhausted short URL is remembered.
+	NegativeTTL time.Duration
+}
+
+// cachedLookup is the cached result of ResolveURL: either a URL or an error saying there is none.
+type cachedLookup struct {
+	url entity.URL
+	err error
+}
+
+// CachedStorage is a read-through cache of ResolveURL in front of a Storage.
+// Concurrent misses of the same short URL are collapsed into a single storage lookup.
+// Saves, edits, deletions and purges going through it invalidate the affected short URLs.
+type CachedStorage struct {
+	Storage
+	opts  CacheOptions
+	lru   *cache.LRU[ShortURL, cachedLookup]
+	group singleflight.Group
+
+	// mu guards generation, which is incremented by every invalidation, so that a lookup that was in flight
+	// meanwhile does not put a stale result into the cache.
+	mu         sync.Mutex
+	generation uint64
+
+	hits   atomic.Int64
+	misses atomic.Int64
+}
+
+func NewCachedStorage(storage Storage, opts CacheOptions) *CachedStorage {
+	return &CachedStorage{
+		Storage: storage,
+		opts:    opts,
+		lru:     cache.NewLRU[ShortURL, cachedLookup](opts.Size),
+	}
+}
+
+func (s *CachedStorage) GetFullURL(ctx context.Context, shortURL ShortURL) (FullURL, error) {
+	url, err := s.ResolveURL(ctx, shortURL)
+	return url.FullURL, err
+}
+
+func (s *CachedStorage) ResolveURL(ctx context.Context, shortURL ShortURL) (entity.URL, error) {
+	if shortURL == "" {
+		return entity.URL{}, usecases.ErrEmptyShortURL
+	}
+	if lookup, ok := s.lru.Get(shortURL); ok {
+		s.hits.Add(1)
+		metrics.CacheLookupsTotal.WithLabelValues("hit").Inc()
+		return lookup.url, lookup.err
+	}
+	s.misses.Add(1)
+	metrics.CacheLookupsTotal.WithLabelValues("miss").Inc()
+
+	// The shared lookup must not fail for everyone when the request that started it goes away.
+	lookupCtx := context.WithoutCancel(ctx)
+	ch := s.group.DoChan(shortURL, func() (any, error) {
+		s.mu.Lock()
+		generation := s.generation
+		s.mu.Unlock()
+		url, err := s.Storage.ResolveURL(lookupCtx, shortURL)
+
+		s.mu.Lock()
+		defer s.mu.Unlock()
+		if s.generation != generation {
+			// Invalidated meanwhile, the result may already be stale.
+			return url, err
+		}
+		switch {
+		case err == nil:
+			// The URL must not outlive its expiry in the cache.
+			ttl := s.opts.TTL
+			if !url.ExpiresAt.IsZero() {
+				ttl = min(ttl, time.Until(url.ExpiresAt))
+			}
+			s.lru.Set(shortURL, cachedLookup{url: url}, ttl)
+		case errors.Is(err, usecases.ErrURLNotFound), errors.Is(err, usecases.ErrURLDeleted),
+			errors.Is(err, usecases.ErrURLExpired), errors.Is(err, usecases.ErrURLExhausted):
+			s.lru.Set(shortURL, cachedLookup{err: err}, s.opts.NegativeTTL)
+		}
+		return url, err
+	})
+	select {
+	case result := <-ch:
+		if result.Err != nil {
+			return entity.URL{}, result.Err
+		}
+		return result.Val.(entity.URL), nil
+	case <-ctx.Done():
+		return entity.URL{}, ctx.Err()
+	}
+}
+
+func (s *CachedStorage) Save(ctx context.Context, url entity.URL) error {
+	err := s.Storage.Save(ctx, url)
+	if err == nil {
+		// The short URL may have been remembered as unknown.
+		s.Invalidate(url.ShortURL)
+	}
+	return err
+}
+
+func (s *CachedStorage) SaveBatch(ctx context.Context, urls []entity.URL) ([]usecases.SaveResult, error) {
+	results, err := s.Storage.SaveBatch(ctx, urls)
+	var created []ShortURL
+	for _, result := range results {
+		if result.Status == usecases.SaveCreated {
+			created = append(created, result.ShortURL)
+		}
+	}
+	if len(created) > 0 {
+		s.Invalidate(created...)
+	}
+	return results, err
+}
+
+func (s *CachedStorage) UpdateFullURL(ctx context.Context, url entity.URL) error {
+	err := s.Storage.UpdateFullURL(ctx, url)
+	if err == nil {
+		s.Invalidate(url.ShortURL)
+	}
+	return err
+}
+
+// ConsumeClick always goes to the storage, which keeps the count. The cached URL is dropped, as its count is stale
+// then, so that the lookup following the last click reports the URL as exhausted.
+func (s *CachedStorage) ConsumeClick(ctx context.Context, shortURL ShortURL) (int64, error) {
+	left, err := s.Storage.ConsumeClick(ctx, shortURL)
+	s.Invalidate(shortURL)
+	return left, err
+}
+
+func (s *CachedStorage) DeleteURLs(ctx context.Context, urls []entity.URL) error {
+	err := s.Storage.DeleteURLs(ctx, urls)
+	// Even a failed batch may have deleted some of the URLs.
+	for _, url := range urls {
+		s.Invalidate(url.ShortURL)
+	}
+	return err
+}
+
+// PurgeExpiredURLs drops the expired URLs from the cache along with the purged ones,
+// so that a purged short URL is no longer reported as expired.
+func (s *CachedStorage) PurgeExpiredURLs(ctx context.Context, before time.Time) (int64, error) {
+	purged, err := s.Storage.PurgeExpiredURLs(ctx, before)
+	if purged > 0 {
+		s.mu.Lock()
+		defer s.mu.Unlock()
+		s.generation++
+		s.lru.DeleteFunc(func(shortURL ShortURL, lookup cachedLookup) bool {
+			if errors.Is(lookup.err, usecases.ErrURLExpired) || (lookup.err == nil && lookup.url.IsExpired(before)) {
+				s.group.Forget(shortURL)
+				return true
+			}
+			return false
+		})
+	}
+	return purged, err
+}
+
+// Invalidate drops the short URLs from the cache, so that the next lookup goes to the storage.
+// It has to be called whenever a short URL is changed bypassing CachedStorage.
+func (s *CachedStorage) Invalidate(shortURLs ...ShortURL) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.generation++
+	for _, shortURL := range shortURLs {
+		s.group.Forget(shortURL)
+		s.lru.Delete(shortURL)
+	}
+}
+
+// HitRatio returns the share of ResolveURL calls answered from the cache.
+func (s *CachedStorage) HitRatio() float64 {
+	hits, misses := s.hits.Load(), s.misses.Load()
+	if hits+misses == 0 {
+		return 0
+	}
+	return float64(hits) / float64(hits+misses)
+}
@@ -0,0 +1,176 @@
+package repository
+
+import (
+	"context"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/radiophysiker/shortener_link/internal/entity"
+	"github.com/radiophysiker/shortener_link/internal/usecases"
+)
+
+// countingStorage counts ResolveURL calls and, if release is set, blocks them until it is closed.
+type countingStorage struct {
+	Storage
+	calls   atomic.Int64
+	release chan struct{}
+}
+
+func (s *countingStorage) ResolveURL(ctx context.Context, shortURL ShortURL) (entity.URL, error) {
+	s.calls.Add(1)
+	if s.release != nil {
+		<-s.release
+	}
+	return s.Storage.ResolveURL(ctx, shortURL)
+}
+
+func newCountingStorage(t *testing.T) *countingStorage {
+	t.Helper()
+	storage, err := NewGenericStorage("")
+	require.NoError(t, err)
+	return &countingStorage{Storage: storage}
+}
+
+var testCacheOptions = CacheOptions{Size: 10, TTL: time.Minute, NegativeTTL: time.Minute}
+
+func TestCachedStorageHit(t *testing.T) {
+	ctx := context.Background()
+	storage := newCountingStorage(t)
+	cached := NewCachedStorage(storage, testCacheOptions)
+	require.NoError(t, cached.Save(ctx, entity.URL{ShortURL: "short", FullURL: "full"}))
+
+	for range 3 {
+		fullURL, err := cached.GetFullURL(ctx, "short")
+		require.NoError(t, err)
+		assert.Equal(t, "full", fullURL)
+	}
+	assert.Equal(t, int64(1), storage.calls.Load(), "only the first lookup should reach the storage")
+	assert.InDelta(t, 2.0/3.0, cached.HitRatio(), 0.001)
+}
+
+func TestCachedStorageNegativeCaching(t *testing.T) {
+	ctx := context.Background()
+	storage := newCountingStorage(t)
+	cached := NewCachedStorage(storage, testCacheOptions)
+
+	for range 2 {
+		_, err := cached.GetFullURL(ctx, "short")
+		assert.ErrorIs(t, err, usecases.ErrURLNotFound)
+	}
+	assert.Equal(t, int64(1), storage.calls.Load(), "an unknown short URL should be remembered")
+
+	require.NoError(t, cached.Save(ctx, entity.URL{ShortURL: "short", FullURL: "full", UserID: "user"}))
+	fullURL, err := cached.GetFullURL(ctx, "short")
+	require.NoError(t, err, "saving should invalidate the negative entry")
+	assert.Equal(t, "full", fullURL)
+
+	require.NoError(t, cached.UpdateFullURL(ctx, entity.URL{ShortURL: "short", FullURL: "edited", UserID: "user"}))
+	fullURL, err = cached.GetFullURL(ctx, "short")
+	require.NoError(t, err)
+	assert.Equal(t, "edited", fullURL, "editing should invalidate the cached full URL")
+
+	require.NoError(t, cached.DeleteURLs(ctx, []entity.URL{{ShortURL: "short", UserID: "user"}}))
+	_, err = cached.GetFullURL(ctx, "short")
+	assert.ErrorIs(t, err, usecases.ErrURLDeleted, "deleting should invalidate the cached full URL")
+}
+
+func TestCachedStorageInvalidate(t *testing.T) {
+	ctx := context.Background()
+	storage := newCountingStorage(t)
+	cached := NewCachedStorage(storage, testCacheOptions)
+	require.NoError(t, storage.Save(ctx, entity.URL{ShortURL: "short", FullURL: "full", UserID: "user"}))
+	_, err := cached.GetFullURL(ctx, "short")
+	require.NoError(t, err)
+
+	// Deleted bypassing the cache.
+	require.NoError(t, storage.DeleteURLs(ctx, []entity.URL{{ShortURL: "short", UserID: "user"}}))
+	_, err = cached.GetFullURL(ctx, "short")
+	assert.NoError(t, err, "the cached full URL should be served until it is invalidated")
+	cached.Invalidate("short")
+	_, err = cached.GetFullURL(ctx, "short")
+	assert.ErrorIs(t, err, usecases.ErrURLDeleted)
+}
+
+func TestCachedStorageConsumeClick(t *testing.T) {
+	ctx := context.Background()
+	storage := newCountingStorage(t)
+	cached := NewCachedStorage(storage, testCacheOptions)
+	require.NoError(t, cached.Save(ctx, entity.URL{ShortURL: "short", FullURL: "full", MaxClicks: 1}))
+	_, err := cached.ResolveURL(ctx, "short")
+	require.NoError(t, err)
+
+	left, err := cached.ConsumeClick(ctx, "short")
+	require.NoError(t, err)
+	assert.Zero(t, left)
+	for range 2 {
+		_, err = cached.ResolveURL(ctx, "short")
+		assert.ErrorIs(t, err, usecases.ErrURLExhausted, "the last click should invalidate the cached URL")
+	}
+	assert.Equal(t, int64(2), storage.calls.Load(), "an exhausted short URL should be remembered")
+}
+
+func TestCachedStorageExpiringURL(t *testing.T) {
+	ctx := context.Background()
+	storage := newCountingStorage(t)
+	cached := NewCachedStorage(storage, testCacheOptions)
+	require.NoError(t, cached.Save(ctx, entity.URL{ShortURL: "short", FullURL: "full", ExpiresAt: time.Now().Add(50 * time.Millisecond)}))
+
+	_, err := cached.GetFullURL(ctx, "short")
+	require.NoError(t, err)
+	time.Sleep(60 * time.Millisecond)
+	_, err = cached.GetFullURL(ctx, "short")
+	assert.ErrorIs(t, err, usecases.ErrURLExpired, "the URL should not be cached past its expiry")
+	assert.Equal(t, int64(2), storage.calls.Load())
+
+	purged, err := cached.PurgeExpiredURLs(ctx, time.Now())
+	require.NoError(t, err)
+	assert.Equal(t, int64(1), purged)
+	_, err = cached.GetFullURL(ctx, "short")
+	assert.ErrorIs(t, err, usecases.ErrURLNotFound, "a purge should drop the expired URL from the cache")
+}
+
+func TestCachedStorageCollapsesConcurrentMisses(t *testing.T) {
+	ctx := context.Background()
+	storage := newCountingStorage(t)
+	require.NoError(t, storage.Save(ctx, entity.URL{ShortURL: "short", FullURL: "full"}))
+	storage.release = make(chan struct{})
+	cached := NewCachedStorage(storage, testCacheOptions)
+
+	const readers = 10
+	var wg sync.WaitGroup
+	for range readers {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			fullURL, err := cached.GetFullURL(ctx, "short")
+			assert.NoError(t, err)
+			assert.Equal(t, "full", fullURL)
+		}()
+	}
+	assert.Eventually(t, func() bool {
+		return storage.calls.Load() == 1
+	}, time.Second, time.Millisecond)
+	// Let the other readers join the lookup in flight.
+	time.Sleep(10 * time.Millisecond)
+	close(storage.release)
+	wg.Wait()
+	assert.Equal(t, int64(1), storage.calls.Load(), "concurrent misses should share one storage lookup")
+}
+
+func TestCachedStorageDisabled(t *testing.T) {
+	ctx := context.Background()
+	storage := newCountingStorage(t)
+	cached := NewCachedStorage(storage, CacheOptions{})
+	require.NoError(t, cached.Save(ctx, entity.URL{ShortURL: "short", FullURL: "full"}))
+
+	for range 2 {
+		_, err := cached.GetFullURL(ctx, "short")
+		require.NoError(t, err)
+	}
+	assert.Equal(t, int64(2), storage.calls.Load(), "a disabled cache should pass every lookup through")
+}
@@ -0,0 +1,793 @@
+package repository
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"sync"
+	"testing"
+	"time"
+
+	embeddedpostgres "github.com/fergusstrange/embedded-postgres"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/radiophysiker/shortener_link/internal/entity"
+	"github.com/radiophysiker/shortener_link/internal/usecases"
+)
+
+// storageHarness plugs a Storage implementation into the conformance suite.
+type storageHarness struct {
+	// prepare returns a function opening an empty storage. Storages opened by the same function share the data,
+	// so that the suite can close a storage and open it again.
+	prepare func(t *testing.T) (open func() Storage)
+	// persistent reports whether the data survives reopening.
+	persistent bool
+}
+
+func TestGenericStorageConformance(t *testing.T) {
+	runStorageConformance(t, storageHarness{
+		prepare: func(t *testing.T) func() Storage {
+			return func() Storage {
+				storage, err := NewGenericStorage("")
+				require.NoError(t, err)
+				return storage
+			}
+		},
+	})
+}
+
+func TestFileStorageConformance(t *testing.T) {
+	runStorageConformance(t, storageHarness{
+		prepare: func(t *testing.T) func() Storage {
+			filePath := filepath.Join(t.TempDir(), "storage.json")
+			return func() Storage {
+				storage, err := NewGenericStorage(filePath)
+				require.NoError(t, err)
+				return storage
+			}
+		},
+		persistent: true,
+	})
+}
+
+func TestSQLiteStorageConformance(t *testing.T) {
+	runStorageConformance(t, storageHarness{
+		prepare: func(t *testing.T) func() Storage {
+			path := filepath.Join(t.TempDir(), "storage.db")
+			return func() Storage {
+				storage, err := NewSQLiteStorage(path)
+				require.NoError(t, err)
+				return storage
+			}
+		},
+		persistent: true,
+	})
+}
+
+// TestPostgresStorageConformance runs the suite against the database from TEST_DATABASE_DSN,
+// or against an embedded PostgreSQL if TEST_EMBEDDED_POSTGRES is set. Otherwise it is skipped.
+// The suite truncates the tables, never point it to a database with data you need.
+func TestPostgresStorageConformance(t *testing.T) {
+	dsn := postgresDSN(t)
+	runStorageConformance(t, storageHarness{
+		prepare: func(t *testing.T) func() Storage {
+			open := func() Storage {
+				storage, err := NewPostgresStorage(dsn)
+				require.NoError(t, err)
+				return storage
+			}
+			storage := open().(*PostgresStorage)
+			defer storage.Close()
+			_, err := storage.pool.Exec(context.Background(), `
+			TRUNCATE shortened_urls, url_revisions, clicks, settings RESTART IDENTITY CASCADE;
+			ALTER SEQUENCE short_url_seq RESTART;
+			`)
+			require.NoError(t, err, "failed to clean the database")
+			return open
+		},
+		persistent: true,
+	})
+}
+
+var (
+	embeddedPostgres     *embeddedpostgres.EmbeddedPostgres
+	embeddedPostgresOnce sync.Once
+	embeddedPostgresDSN  string
+	embeddedPostgresErr  error
+)
+
+func TestMain(m *testing.M) {
+	code := m.Run()
+	if embeddedPostgres != nil {
+		if err := embeddedPostgres.Stop(); err != nil {
+			fmt.Fprintln(os.Stderr, "cannot stop embedded PostgreSQL:", err)
+		}
+	}
+	os.Exit(code)
+}
+
+// postgresDSN returns the DSN of the database to test PostgresStorage on, or skips the test.
+func postgresDSN(t *testing.T) string {
+	t.Helper()
+	if dsn := os.Getenv("TEST_DATABASE_DSN"); dsn != "" {
+		return dsn
+	}
+	if os.Getenv("TEST_EMBEDDED_POSTGRES") == "" {
+		t.Skip("set TEST_DATABASE_DSN or TEST_EMBEDDED_POSTGRES=1 to test PostgresStorage")
+	}
+	embeddedPostgresOnce.Do(func() {
+		dir, err := os.MkdirTemp("", "shortener-postgres")
+		if err != nil {
+			embeddedPostgresErr = err
+			return
+		}
+		config := embeddedpostgres.DefaultConfig().
+			Port(15432).
+			RuntimePath(dir).
+			Logger(io.Discard)
+		database := embeddedpostgres.NewDatabase(config)
+		if err := database.Start(); err != nil {
+			embeddedPostgresErr = err
+			return
+		}
+		embeddedPostgres = database
+		embeddedPostgresDSN = config.GetConnectionURL() + "?sslmode=disable"
+	})
+	if embeddedPostgresErr != nil {
+		t.Skipf("embedded PostgreSQL is not available: %v", embeddedPostgresErr)
+	}
+	return embeddedPostgresDSN
+}
+
+// runStorageConformance checks that the storage behaves the way the use cases expect from every Storage.
+func runStorageConformance(t *testing.T, h storageHarness) {
+	// open opens an empty storage that is closed when the test ends.
+	open := func(t *testing.T) Storage {
+		storage := h.prepare(t)()
+		t.Cleanup(func() {
+			assert.NoError(t, storage.Close())
+		})
+		return storage
+	}
+	ctx := context.Background()
+
+	t.Run("save and lookup", func(t *testing.T) {
+		storage := open(t)
+		require.NoError(t, storage.Save(ctx, entity.URL{ShortURL: "short", FullURL: "full", UserID: "user"}))
+
+		fullURL, err := storage.GetFullURL(ctx, "short")
+		require.NoError(t, err)
+		assert.Equal(t, "full", fullURL)
+	})
+
+	t.Run("empty values", func(t *testing.T) {
+		storage := open(t)
+		assert.ErrorIs(t, storage.Save(ctx, entity.URL{ShortURL: "short"}), usecases.ErrEmptyFullURL)
+		_, err := storage.GetFullURL(ctx, "")
+		assert.ErrorIs(t, err, usecases.ErrEmptyShortURL)
+		_, err = storage.SaveBatch(ctx, nil)
+		assert.ErrorIs(t, err, usecases.ErrEmptyBatch)
+		_, err = storage.GetURLsByUserID(ctx, "")
+		assert.ErrorIs(t, err, usecases.ErrEmptyUserID)
+		_, err = storage.GetClickStats(ctx, "")
+		assert.ErrorIs(t, err, usecases.ErrEmptyShortURL)
+	})
+
+	t.Run("not found", func(t *testing.T) {
+		storage := open(t)
+		_, err := storage.GetFullURL(ctx, "unknown")
+		assert.ErrorIs(t, err, usecases.ErrURLNotFound)
+		_, err = storage.GetClickStats(ctx, "unknown")
+		assert.ErrorIs(t, err, usecases.ErrURLNotFound)
+		urls, err := storage.GetURLsByUserID(ctx, "unknown")
+		require.NoError(t, err)
+		assert.Empty(t, urls)
+	})
+
+	t.Run("conflicts", func(t *testing.T) {
+		storage := open(t)
+		require.NoError(t, storage.Save(ctx, entity.URL{ShortURL: "short", FullURL: "full"}))
+
+		err := storage.Save(ctx, entity.URL{ShortURL: "short", FullURL: "another_full"})
+		assert.ErrorIs(t, err, usecases.ErrURLGeneratedBefore, "a taken short URL should be reported as generated before")
+
+		err = storage.Save(ctx, entity.URL{ShortURL: "another_short", FullURL: "full"})
+		require.ErrorIs(t, err, usecases.ErrURLConflict, "a shortened full URL should be reported as a conflict")
+		var conflict *usecases.ConflictError
+		require.ErrorAs(t, err, &conflict)
+		assert.Equal(t, "short", conflict.ExistingShortURL, "the conflict should carry the existing short URL")
+
+		err = storage.Save(ctx, entity.URL{ShortURL: "short", FullURL: "full"})
+		require.ErrorAs(t, err, &conflict, "a full URL conflict should win over a taken short URL")
+		assert.Equal(t, "short", conflict.ExistingShortURL)
+	})
+
+	t.Run("conflicts are per user", func(t *testing.T) {
+		storage := open(t)
+		require.NoError(t, storage.Save(ctx, entity.URL{ShortURL: "alice", FullURL: "full", UserID: "alice"}))
+
+		require.NoError(t, storage.Save(ctx, entity.URL{ShortURL: "bob", FullURL: "full", UserID: "bob"}),
+			"a full URL shortened by another user should not conflict")
+		results, err := storage.SaveBatch(ctx, []entity.URL{
+			{ShortURL: "carol", FullURL: "full", UserID: "carol"},
+			{ShortURL: "bob2", FullURL: "full", UserID: "bob"},
+		})
+		require.NoError(t, err)
+		assert.Equal(t, []usecases.SaveResult{
+			{Status: usecases.SaveCreated, ShortURL: "carol"},
+			{Status: usecases.SaveExisting, ShortURL: "bob"},
+		}, results, "a batch should only be deduplicated against the URLs of the same user")
+
+		require.NoError(t, storage.UpdateFullURL(ctx, entity.URL{ShortURL: "alice", FullURL: "evil", UserID: "alice"}))
+		for shortURL, want := range map[string]string{"alice": "evil", "bob": "full", "carol": "full"} {
+			fullURL, err := storage.GetFullURL(ctx, shortURL)
+			require.NoError(t, err)
+			assert.Equal(t, want, fullURL, "editing a URL should not affect the URLs of other users")
+		}
+	})
+
+	t.Run("save batch", func(t *testing.T) {
+		storage := open(t)
+		urls := []entity.URL{
+			{ShortURL: "short1", FullURL: "full1"},
+			{ShortURL: "short2", FullURL: "full2"},
+		}
+		results, err := storage.SaveBatch(ctx, urls)
+		require.NoError(t, err)
+		assert.Equal(t, []usecases.SaveResult{
+			{Status: usecases.SaveCreated, ShortURL: "short1"},
+			{Status: usecases.SaveCreated, ShortURL: "short2"},
+		}, results)
+		for _, url := range urls {
+			fullURL, err := storage.GetFullURL(ctx, url.ShortURL)
+			require.NoError(t, err)
+			assert.Equal(t, url.FullURL, fullURL)
+		}
+
+		results, err = storage.SaveBatch(ctx, []entity.URL{
+			{ShortURL: "short3", FullURL: "full3"},
+			{ShortURL: "short4", FullURL: "full1"},
+			{ShortURL: "short1", FullURL: "full5"},
+			{ShortURL: "short6", FullURL: "full3"},
+			{ShortURL: "short7", FullURL: "full7"},
+		})
+		require.NoError(t, err, "conflicts should be reported per URL")
+		assert.Equal(t, []usecases.SaveResult{
+			{Status: usecases.SaveCreated, ShortURL: "short3"},
+			{Status: usecases.SaveExisting, ShortURL: "short1"},
+			{Status: usecases.SaveShortURLTaken},
+			{Status: usecases.SaveExisting, ShortURL: "short3"},
+			{Status: usecases.SaveCreated, ShortURL: "short7"},
+		}, results)
+		for shortURL, want := range map[string]string{"short3": "full3", "short7": "full7", "short1": "full1"} {
+			fullURL, err := storage.GetFullURL(ctx, shortURL)
+			require.NoError(t, err)
+			assert.Equal(t, want, fullURL, "URLs without conflicts should be saved")
+		}
+		for _, shortURL := range []string{"short4", "short6"} {
+			_, err := storage.GetFullURL(ctx, shortURL)
+			assert.ErrorIs(t, err, usecases.ErrURLNotFound, "conflicting URLs should not be saved")
+		}
+	})
+
+	t.Run("URLs with options are not deduplicated", func(t *testing.T) {
+		storage := open(t)
+		require.NoError(t, storage.Save(ctx, entity.URL{ShortURL: "plain", FullURL: "full", UserID: "user"}))
+
+		withOptions := []entity.URL{
+			{ShortURL: "password", FullURL: "full", UserID: "user", PasswordHash: "hash"},
+			{ShortURL: "limited", FullURL: "full", UserID: "user", MaxClicks: 1},
+			{ShortURL: "expiring", FullURL: "full", UserID: "user", ExpiresAt: time.Now().Add(time.Hour)},
+			{ShortURL: "permanent", FullURL: "full", UserID: "user", RedirectStatus: 308},
+			{ShortURL: "passthrough", FullURL: "full", UserID: "user", Passthrough: entity.PassthroughQuery},
+		}
+		for _, url := range withOptions {
+			require.NoError(t, storage.Save(ctx, url), "%s should not be deduplicated", url.ShortURL)
+		}
+		results, err := storage.SaveBatch(ctx, []entity.URL{
+			{ShortURL: "password2", FullURL: "full", UserID: "user", PasswordHash: "hash"},
+			{ShortURL: "plain2", FullURL: "full", UserID: "user"},
+			{ShortURL: "password", FullURL: "another_full", UserID: "user", PasswordHash: "hash"},
+		})
+		require.NoError(t, err)
+		assert.Equal(t, []usecases.SaveResult{
+			{Status: usecases.SaveCreated, ShortURL: "password2"},
+			{Status: usecases.SaveExisting, ShortURL: "plain"},
+			{Status: usecases.SaveShortURLTaken},
+		}, results, "only URLs without options should be deduplicated in a batch")
+
+		require.NoError(t, storage.DeleteURLs(ctx, []entity.URL{{ShortURL: "plain", UserID: "user"}}))
+		require.NoError(t, storage.Save(ctx, entity.URL{ShortURL: "plain3", FullURL: "full", UserID: "user"}),
+			"a URL without options should not be deduplicated against URLs with options")
+	})
+
+	t.Run("user URLs and deletion", func(t *testing.T) {
+		storage := open(t)
+		urls := []entity.URL{
+			{ShortURL: "short1", FullURL: "full1", UserID: "user1"},
+			{ShortURL: "short2", FullURL: "full2", UserID: "user2"},
+			{ShortURL: "short3", FullURL: "full3", UserID: "user1"},
+		}
+		_, err := storage.SaveBatch(ctx, urls)
+		require.NoError(t, err)
+
+		userURLs, err := storage.GetURLsByUserID(ctx, "user1")
+		require.NoError(t, err)
+		assert.ElementsMatch(t, []entity.URL{urls[0], urls[2]}, userURLs)
+
+		require.NoError(t, storage.DeleteURLs(ctx, []entity.URL{
+			{ShortURL: "short1", UserID: "user1"},
+			{ShortURL: "short2", UserID: "user1"},
+			{ShortURL: "unknown", UserID: "user1"},
+		}))
+		_, err = storage.GetFullURL(ctx, "short1")
+		assert.ErrorIs(t, err, usecases.ErrURLDeleted, "the owner's URL should be deleted")
+		_, err = storage.GetFullURL(ctx, "short2")
+		assert.NoError(t, err, "another user's URL should not be deleted")
+		userURLs, err = storage.GetURLsByUserID(ctx, "user1")
+		require.NoError(t, err)
+		assert.Equal(t, []entity.URL{urls[2]}, userURLs, "deleted URLs should not be listed")
+	})
+
+	t.Run("user URLs in creation order", func(t *testing.T) {
+		reopen := h.prepare(t)
+		storage := reopen()
+		require.NoError(t, storage.Save(ctx, entity.URL{ShortURL: "zz", FullURL: "full1", UserID: "user"}))
+		_, err := storage.SaveBatch(ctx, []entity.URL{
+			{ShortURL: "mm", FullURL: "full2", UserID: "user"},
+			{ShortURL: "aa", FullURL: "full3", UserID: "user"},
+		})
+		require.NoError(t, err)
+		require.NoError(t, storage.Save(ctx, entity.URL{ShortURL: "ss", FullURL: "full4", UserID: "user", MaxClicks: 5}))
+		require.NoError(t, storage.UpdateFullURL(ctx, entity.URL{ShortURL: "zz", FullURL: "full1_v2", UserID: "user"}))
+		_, err = storage.ConsumeClick(ctx, "ss")
+		require.NoError(t, err)
+
+		want := []string{"zz", "mm", "aa", "ss"}
+		assert.Equal(t, want, userShortURLs(t, storage, "user"), "edits and clicks should not reorder the URLs")
+		require.NoError(t, storage.Close())
+		if !h.persistent {
+			return
+		}
+		storage = reopen()
+		defer storage.Close()
+		assert.Equal(t, want, userShortURLs(t, storage, "user"), "the order should survive a restart")
+	})
+
+	t.Run("shorten deleted full URL again", func(t *testing.T) {
+		storage := open(t)
+		require.NoError(t, storage.Save(ctx, entity.URL{ShortURL: "short1", FullURL: "full1", UserID: "user"}))
+		require.NoError(t, storage.Save(ctx, entity.URL{ShortURL: "short2", FullURL: "full2", UserID: "user"}))
+		require.NoError(t, storage.DeleteURLs(ctx, []entity.URL{
+			{ShortURL: "short1", UserID: "user"},
+			{ShortURL: "short2", UserID: "user"},
+		}))
+
+		require.NoError(t, storage.Save(ctx, entity.URL{ShortURL: "short3", FullURL: "full1", UserID: "user"}),
+			"a deleted full URL should be free")
+		results, err := storage.SaveBatch(ctx, []entity.URL{{ShortURL: "short4", FullURL: "full2", UserID: "user"}})
+		require.NoError(t, err)
+		assert.Equal(t, []usecases.SaveResult{{Status: usecases.SaveCreated, ShortURL: "short4"}}, results,
+			"a deleted full URL should be free in a batch")
+		for shortURL, want := range map[string]string{"short3": "full1", "short4": "full2"} {
+			fullURL, err := storage.GetFullURL(ctx, shortURL)
+			require.NoError(t, err)
+			assert.Equal(t, want, fullURL)
+		}
+		_, err = storage.GetFullURL(ctx, "short1")
+		assert.ErrorIs(t, err, usecases.ErrURLDeleted, "the deleted URL should stay deleted")
+
+		err = storage.Save(ctx, entity.URL{ShortURL: "short5", FullURL: "full1", UserID: "user"})
+		var conflict *usecases.ConflictError
+		require.ErrorAs(t, err, &conflict)
+		assert.Equal(t, "short3", conflict.ExistingShortURL, "the conflict should point to the live URL")
+	})
+
+	t.Run("edit full URL", func(t *testing.T) {
+		storage := open(t)
+		require.NoError(t, storage.Save(ctx, entity.URL{ShortURL: "short1", FullURL: "full1", UserID: "user"}))
+		require.NoError(t, storage.Save(ctx, entity.URL{ShortURL: "short2", FullURL: "full2", UserID: "user"}))
+
+		err := storage.UpdateFullURL(ctx, entity.URL{ShortURL: "unknown", FullURL: "full", UserID: "user"})
+		assert.ErrorIs(t, err, usecases.ErrURLNotFound)
+		err = storage.UpdateFullURL(ctx, entity.URL{ShortURL: "short1", FullURL: "full", UserID: "another_user"})
+		assert.ErrorIs(t, err, usecases.ErrNotURLOwner, "only the owner should be able to edit a URL")
+
+		require.NoError(t, storage.UpdateFullURL(ctx, entity.URL{ShortURL: "short1", FullURL: "full1_v2", UserID: "user"}))
+		require.NoError(t, storage.UpdateFullURL(ctx, entity.URL{ShortURL: "short1", FullURL: "full2", UserID: "user"}),
+			"an edited URL may share its full URL with another URL")
+		require.NoError(t, storage.UpdateFullURL(ctx, entity.URL{ShortURL: "short1", FullURL: "full2", UserID: "user"}),
+			"an edit that changes nothing should succeed")
+		fullURL, err := storage.GetFullURL(ctx, "short1")
+		require.NoError(t, err)
+		assert.Equal(t, "full2", fullURL)
+
+		revisions, err := storage.GetURLRevisions(ctx, "short1")
+		require.NoError(t, err)
+		require.Len(t, revisions, 2, "an edit that changes nothing should not be recorded")
+		assert.Equal(t, "full1", revisions[0].PreviousFullURL)
+		assert.Equal(t, "full1_v2", revisions[0].FullURL)
+		assert.Equal(t, "full1_v2", revisions[1].PreviousFullURL)
+		assert.Equal(t, "full2", revisions[1].FullURL)
+		assert.False(t, revisions[1].ChangedAt.Before(revisions[0].ChangedAt))
+		revisions, err = storage.GetURLRevisions(ctx, "short2")
+		require.NoError(t, err)
+		assert.Empty(t, revisions)
+
+		url, err := storage.GetURL(ctx, "short1")
+		require.NoError(t, err)
+		assert.Equal(t, entity.URL{ShortURL: "short1", FullURL: "full2", UserID: "user"}, url)
+
+		err = storage.Save(ctx, entity.URL{ShortURL: "short3", FullURL: "full2", UserID: "user"})
+		var conflict *usecases.ConflictError
+		require.ErrorAs(t, err, &conflict, "a URL that has never been edited should still be deduplicated")
+		assert.Equal(t, "short2", conflict.ExistingShortURL)
+		require.NoError(t, storage.Save(ctx, entity.URL{ShortURL: "short4", FullURL: "full1", UserID: "user"}),
+			"the previous full URL of an edited URL should be free")
+		require.NoError(t, storage.Save(ctx, entity.URL{ShortURL: "short5", FullURL: "full1_v3", UserID: "user"}))
+		require.NoError(t, storage.UpdateFullURL(ctx, entity.URL{ShortURL: "short2", FullURL: "full_moved", UserID: "user"}))
+		require.NoError(t, storage.Save(ctx, entity.URL{ShortURL: "short6", FullURL: "full2", UserID: "user"}),
+			"the full URL should be free once no URL that has never been edited uses it")
+
+		require.NoError(t, storage.DeleteURLs(ctx, []entity.URL{{ShortURL: "short1", UserID: "user"}}))
+		err = storage.UpdateFullURL(ctx, entity.URL{ShortURL: "short1", FullURL: "full", UserID: "user"})
+		assert.ErrorIs(t, err, usecases.ErrURLDeleted)
+	})
+
+	t.Run("redirect status", func(t *testing.T) {
+		storage := open(t)
+		require.NoError(t, storage.Save(ctx, entity.URL{ShortURL: "short1", FullURL: "full1", UserID: "user", RedirectStatus: 301}))
+		_, err := storage.SaveBatch(ctx, []entity.URL{
+			{ShortURL: "short2", FullURL: "full2", RedirectStatus: 308},
+			{ShortURL: "short3", FullURL: "full3"},
+		})
+		require.NoError(t, err)
+
+		for shortURL, want := range map[string]int{"short1": 301, "short2": 308, "short3": 0} {
+			url, err := storage.ResolveURL(ctx, shortURL)
+			require.NoError(t, err)
+			assert.Equal(t, want, url.RedirectStatus, "the redirect status should be stored with %s", shortURL)
+		}
+		require.NoError(t, storage.DeleteURLs(ctx, []entity.URL{{ShortURL: "short1", UserID: "user"}}))
+		_, err = storage.ResolveURL(ctx, "short1")
+		assert.ErrorIs(t, err, usecases.ErrURLDeleted)
+	})
+
+	t.Run("passthrough", func(t *testing.T) {
+		storage := open(t)
+		require.NoError(t, storage.Save(ctx, entity.URL{ShortURL: "short1", FullURL: "full1", Passthrough: entity.PassthroughQuery}))
+		_, err := storage.SaveBatch(ctx, []entity.URL{
+			{ShortURL: "short2", FullURL: "full2", Passthrough: entity.PassthroughPathAndQuery},
+			{ShortURL: "short3", FullURL: "full3"},
+		})
+		require.NoError(t, err)
+
+		want := map[string]entity.PassthroughMode{
+			"short1": entity.PassthroughQuery,
+			"short2": entity.PassthroughPathAndQuery,
+			"short3": "",
+		}
+		for shortURL, mode := range want {
+			url, err := storage.ResolveURL(ctx, shortURL)
+			require.NoError(t, err)
+			assert.Equal(t, mode, url.Passthrough, "the passthrough mode should be stored with %s", shortURL)
+		}
+	})
+
+	t.Run("max clicks", func(t *testing.T) {
+		storage := open(t)
+		require.NoError(t, storage.Save(ctx, entity.URL{ShortURL: "limited", FullURL: "full1", MaxClicks: 2}))
+		_, err := storage.SaveBatch(ctx, []entity.URL{{ShortURL: "unlimited", FullURL: "full2"}})
+		require.NoError(t, err)
+
+		for _, want := range []int64{1, 0} {
+			left, err := storage.ConsumeClick(ctx, "limited")
+			require.NoError(t, err)
+			assert.Equal(t, want, left)
+		}
+		_, err = storage.ConsumeClick(ctx, "limited")
+		assert.ErrorIs(t, err, usecases.ErrURLExhausted, "no clicks should be left")
+		_, err = storage.ResolveURL(ctx, "limited")
+		assert.ErrorIs(t, err, usecases.ErrURLExhausted)
+		url, err := storage.GetURL(ctx, "limited")
+		require.NoError(t, err, "an exhausted URL should still be stored")
+		assert.Equal(t, int64(2), url.Redirects)
+
+		left, err := storage.ConsumeClick(ctx, "unlimited")
+		require.NoError(t, err)
+		assert.Zero(t, left, "URLs without a limit should not be counted")
+		_, err = storage.ResolveURL(ctx, "unlimited")
+		assert.NoError(t, err)
+		_, err = storage.ConsumeClick(ctx, "unknown")
+		assert.ErrorIs(t, err, usecases.ErrURLNotFound)
+	})
+
+	t.Run("concurrent clicks", func(t *testing.T) {
+		storage := open(t)
+		const maxClicks, clients = 5, 20
+		require.NoError(t, storage.Save(ctx, entity.URL{ShortURL: "limited", FullURL: "full", MaxClicks: maxClicks}))
+		var (
+			wg        sync.WaitGroup
+			mu        sync.Mutex
+			succeeded int
+		)
+		for range clients {
+			wg.Add(1)
+			go func() {
+				defer wg.Done()
+				_, err := storage.ConsumeClick(ctx, "limited")
+				if err != nil {
+					assert.ErrorIs(t, err, usecases.ErrURLExhausted)
+					return
+				}
+				mu.Lock()
+				defer mu.Unlock()
+				succeeded++
+			}()
+		}
+		wg.Wait()
+		assert.Equal(t, maxClicks, succeeded, "exactly max clicks redirects should succeed")
+	})
+
+	t.Run("expiry", func(t *testing.T) {
+		storage := open(t)
+		require.NoError(t, storage.Save(ctx, entity.URL{ShortURL: "expired", FullURL: "full1", ExpiresAt: time.Now().Add(-time.Hour)}))
+		require.NoError(t, storage.Save(ctx, entity.URL{ShortURL: "alive", FullURL: "full2", ExpiresAt: time.Now().Add(time.Hour)}))
+
+		_, err := storage.GetFullURL(ctx, "expired")
+		assert.ErrorIs(t, err, usecases.ErrURLExpired)
+		purged, err := storage.PurgeExpiredURLs(ctx, time.Now())
+		require.NoError(t, err)
+		assert.Equal(t, int64(1), purged)
+		_, err = storage.GetFullURL(ctx, "expired")
+		assert.ErrorIs(t, err, usecases.ErrURLNotFound, "purged URLs should not be found")
+		_, err = storage.GetFullURL(ctx, "alive")
+		assert.NoError(t, err)
+	})
+
+	t.Run("purge drops clicks", func(t *testing.T) {
+		reopen := h.prepare(t)
+		storage := reopen()
+		require.NoError(t, storage.Save(ctx, entity.URL{ShortURL: "sale", FullURL: "full1", UserID: "alice",
+			ExpiresAt: time.Now().Add(-time.Hour)}))
+		require.NoError(t, storage.Save(ctx, entity.URL{ShortURL: "alive", FullURL: "full2", UserID: "alice"}))
+		require.NoError(t, storage.SaveClicks(ctx, []entity.Click{
+			{ShortURL: "sale", ClickedAt: time.Now().Add(-2 * time.Hour)},
+			{ShortURL: "sale", ClickedAt: time.Now().Add(-2 * time.Hour)},
+			{ShortURL: "alive", ClickedAt: time.Now()},
+		}))
+		purged, err := storage.PurgeExpiredURLs(ctx, time.Now())
+		require.NoError(t, err)
+		require.Equal(t, int64(1), purged)
+
+		require.NoError(t, storage.Save(ctx, entity.URL{ShortURL: "sale", FullURL: "full3", UserID: "bob"}),
+			"the short URL of a purged URL should be free")
+		stats, err := storage.GetClickStats(ctx, "sale")
+		require.NoError(t, err)
+		assert.Zero(t, stats.Total, "a reused short URL should not inherit the clicks of the purged URL")
+		stats, err = storage.GetClickStats(ctx, "alive")
+		require.NoError(t, err)
+		assert.Equal(t, int64(1), stats.Total, "the clicks of other URLs should be kept")
+
+		require.NoError(t, storage.Close())
+		if !h.persistent {
+			return
+		}
+		storage = reopen()
+		defer storage.Close()
+		stats, err = storage.GetClickStats(ctx, "sale")
+		require.NoError(t, err)
+		assert.Zero(t, stats.Total, "the clicks of the purged URL should not come back after a restart")
+		stats, err = storage.GetClickStats(ctx, "alive")
+		require.NoError(t, err)
+		assert.Equal(t, int64(1), stats.Total)
+	})
+
+	t.Run("shorten expired full URL again", func(t *testing.T) {
+		storage := open(t)
+		expiresAt := time.Now().Add(-time.Hour)
+		require.NoError(t, storage.Save(ctx, entity.URL{ShortURL: "short1", FullURL: "full1", ExpiresAt: expiresAt}))
+		require.NoError(t, storage.Save(ctx, entity.URL{ShortURL: "short2", FullURL: "full2", ExpiresAt: expiresAt}))
+
+		require.NoError(t, storage.Save(ctx, entity.URL{ShortURL: "short3", FullURL: "full1"}),
+			"an expired full URL should be free before it is purged")
+		results, err := storage.SaveBatch(ctx, []entity.URL{{ShortURL: "short4", FullURL: "full2"}})
+		require.NoError(t, err)
+		assert.Equal(t, []usecases.SaveResult{{Status: usecases.SaveCreated, ShortURL: "short4"}}, results,
+			"an expired full URL should be free in a batch")
+		for shortURL, want := range map[string]string{"short3": "full1", "short4": "full2"} {
+			fullURL, err := storage.GetFullURL(ctx, shortURL)
+			require.NoError(t, err)
+			assert.Equal(t, want, fullURL)
+		}
+		_, err = storage.GetFullURL(ctx, "short1")
+		assert.Error(t, err, "the expired URL should not redirect")
+
+		err = storage.Save(ctx, entity.URL{ShortURL: "short5", FullURL: "full1"})
+		var conflict *usecases.ConflictError
+		require.ErrorAs(t, err, &conflict)
+		assert.Equal(t, "short3", conflict.ExistingShortURL, "the conflict should point to the live URL")
+	})
+
+	t.Run("click stats", func(t *testing.T) {
+		storage := open(t)
+		require.NoError(t, storage.Save(ctx, entity.URL{ShortURL: "short", FullURL: "full"}))
+		stats, err := storage.GetClickStats(ctx, "short")
+		require.NoError(t, err)
+		assert.Zero(t, stats.Total, "a URL without clicks should have no stats")
+
+		day1 := time.Date(2025, 3, 1, 23, 59, 0, 0, time.UTC)
+		day2 := time.Date(2025, 3, 2, 0, 1, 0, 0, time.UTC)
+		require.NoError(t, storage.SaveClicks(ctx, []entity.Click{
+			{ShortURL: "short", ClickedAt: day1, Referrer: "https://example.com", UserAgent: "test", IPHash: "hash"},
+			{ShortURL: "short", ClickedAt: day2},
+			{ShortURL: "short", ClickedAt: day2.Add(time.Hour)},
+		}))
+		stats, err = storage.GetClickStats(ctx, "short")
+		require.NoError(t, err)
+		assertClickStats(t, stats)
+	})
+
+	t.Run("sequence and code length", func(t *testing.T) {
+		storage := open(t)
+		first, err := storage.NextSequenceValue(ctx)
+		require.NoError(t, err)
+		second, err := storage.NextSequenceValue(ctx)
+		require.NoError(t, err)
+		assert.Greater(t, second, first)
+
+		length, err := storage.GetCodeLength(ctx)
+		require.NoError(t, err)
+		assert.Zero(t, length, "no code length should be stored initially")
+		require.NoError(t, storage.SetCodeLength(ctx, 8))
+		require.NoError(t, storage.SetCodeLength(ctx, 7), "a smaller length should be ignored")
+		length, err = storage.GetCodeLength(ctx)
+		require.NoError(t, err)
+		assert.Equal(t, 8, length)
+	})
+
+	t.Run("concurrent access", func(t *testing.T) {
+		storage := open(t)
+		const writers, urlsPerWriter = 8, 20
+		var (
+			wg        sync.WaitGroup
+			mu        sync.Mutex
+			winners   int
+			sequences = make(map[int64]struct{})
+		)
+		for w := range writers {
+			wg.Add(1)
+			go func() {
+				defer wg.Done()
+				for i := range urlsPerWriter {
+					url := entity.URL{ShortURL: fmt.Sprintf("short-%d-%d", w, i), FullURL: fmt.Sprintf("full-%d-%d", w, i), UserID: "user"}
+					assert.NoError(t, storage.Save(ctx, url))
+					fullURL, err := storage.GetFullURL(ctx, url.ShortURL)
+					assert.NoError(t, err)
+					assert.Equal(t, url.FullURL, fullURL)
+				}
+				// All writers race for the same full URL, exactly one of them has to win.
+				err := storage.Save(ctx, entity.URL{ShortURL: fmt.Sprintf("shared-%d", w), FullURL: "shared"})
+				if err != nil {
+					assert.ErrorIs(t, err, usecases.ErrURLConflict)
+				}
+				seq, seqErr := storage.NextSequenceValue(ctx)
+				assert.NoError(t, seqErr)
+
+				mu.Lock()
+				defer mu.Unlock()
+				if err == nil {
+					winners++
+				}
+				sequences[seq] = struct{}{}
+			}()
+		}
+		wg.Wait()
+
+		assert.Equal(t, 1, winners, "exactly one writer should save the shared full URL")
+		assert.Len(t, sequences, writers, "sequence values should be unique")
+		userURLs, err := storage.GetURLsByUserID(ctx, "user")
+		require.NoError(t, err)
+		assert.Len(t, userURLs, writers*urlsPerWriter)
+	})
+
+	t.Run("restart persistence", func(t *testing.T) {
+		if !h.persistent {
+			t.Skip("the storage does not persist data")
+		}
+		reopen := h.prepare(t)
+		storage := reopen()
+		expiresAt := time.Now().Add(time.Hour).Truncate(time.Millisecond)
+		require.NoError(t, storage.Save(ctx, entity.URL{
+			ShortURL: "short1", FullURL: "full1", UserID: "user", ExpiresAt: expiresAt, RedirectStatus: 308, PasswordHash: "hash",
+			MaxClicks: 3,
+		}))
+		_, err := storage.ConsumeClick(ctx, "short1")
+		require.NoError(t, err)
+		_, err = storage.SaveBatch(ctx, []entity.URL{
+			{ShortURL: "short2", FullURL: "full2", UserID: "user"},
+			{ShortURL: "short3", FullURL: "full3", UserID: "user"},
+			{ShortURL: "short6", FullURL: "full6", UserID: "user"},
+		})
+		require.NoError(t, err)
+		require.NoError(t, storage.DeleteURLs(ctx, []entity.URL{{ShortURL: "short2", UserID: "user"}}))
+		require.NoError(t, storage.UpdateFullURL(ctx, entity.URL{ShortURL: "short3", FullURL: "full3_v2", UserID: "user"}))
+		require.NoError(t, storage.SaveClicks(ctx, []entity.Click{
+			{ShortURL: "short1", ClickedAt: time.Date(2025, 3, 1, 23, 59, 0, 0, time.UTC)},
+			{ShortURL: "short1", ClickedAt: time.Date(2025, 3, 2, 0, 1, 0, 0, time.UTC)},
+			{ShortURL: "short1", ClickedAt: time.Date(2025, 3, 2, 1, 1, 0, 0, time.UTC)},
+		}))
+		seq, err := storage.NextSequenceValue(ctx)
+		require.NoError(t, err)
+		require.NoError(t, storage.SetCodeLength(ctx, 8))
+		require.NoError(t, storage.Close())
+
+		storage = reopen()
+		defer storage.Close()
+		userURLs, err := storage.GetURLsByUserID(ctx, "user")
+		require.NoError(t, err)
+		require.Len(t, userURLs, 3)
+		byShortURL := make(map[string]entity.URL)
+		for _, url := range userURLs {
+			byShortURL[url.ShortURL] = url
+		}
+		assert.Equal(t, "full1", byShortURL["short1"].FullURL)
+		assert.True(t, expiresAt.Equal(byShortURL["short1"].ExpiresAt), "the expiry should survive a restart")
+		url, err := storage.ResolveURL(ctx, "short1")
+		require.NoError(t, err)
+		assert.Equal(t, 308, url.RedirectStatus, "the redirect status should survive a restart")
+		assert.Equal(t, "hash", url.PasswordHash, "the password hash should survive a restart")
+		assert.Equal(t, int64(3), url.MaxClicks, "the click limit should survive a restart")
+		assert.Equal(t, int64(1), url.Redirects, "the consumed clicks should survive a restart")
+		assert.Equal(t, "full3_v2", byShortURL["short3"].FullURL, "the edit should survive a restart")
+		revisions, err := storage.GetURLRevisions(ctx, "short3")
+		require.NoError(t, err)
+		require.Len(t, revisions, 1)
+		assert.Equal(t, "full3", revisions[0].PreviousFullURL)
+		require.NoError(t, storage.Save(ctx, entity.URL{ShortURL: "short5", FullURL: "full3_v2", UserID: "user"}),
+			"an edited URL should stay out of conflict checks after a restart")
+		_, err = storage.GetFullURL(ctx, "short2")
+		assert.ErrorIs(t, err, usecases.ErrURLDeleted, "the deletion should survive a restart")
+		assert.ErrorIs(t, storage.Save(ctx, entity.URL{ShortURL: "short4", FullURL: "full6", UserID: "user"}), usecases.ErrURLConflict,
+			"full URLs should stay unique after a restart")
+
+		stats, err := storage.GetClickStats(ctx, "short1")
+		require.NoError(t, err)
+		assertClickStats(t, stats)
+		next, err := storage.NextSequenceValue(ctx)
+		require.NoError(t, err)
+		assert.Greater(t, next, seq, "sequence values should not be reused after a restart")
+		length, err := storage.GetCodeLength(ctx)
+		require.NoError(t, err)
+		assert.Equal(t, 8, length)
+	})
+}
+
+// assertClickStats checks the stats of one click on 2025-03-01 and two clicks on 2025-03-02.
+func assertClickStats(t *testing.T, stats entity.ClickStats) {
+	t.Helper()
+	assert.Equal(t, int64(3), stats.Total)
+	require.Len(t, stats.Daily, 2)
+	for i, want := range []entity.DailyClicks{
+		{Date: time.Date(2025, 3, 1, 0, 0, 0, 0, time.UTC), Clicks: 1},
+		{Date: time.Date(2025, 3, 2, 0, 0, 0, 0, time.UTC), Clicks: 2},
+	} {
+		assert.True(t, want.Date.Equal(stats.Daily[i].Date), "day %d: want %s, got %s", i, want.Date, stats.Daily[i].Date)
+		assert.Equal(t, want.Clicks, stats.Daily[i].Clicks)
+	}
+}
+
+// userShortURLs returns the short URLs of the user in the order the storage lists them.
+func userShortURLs(t *testing.T, storage Storage, userID string) []string {
+	t.Helper()
+	urls, err := storage.GetURLsByUserID(context.Background(), userID)
+	require.NoError(t, err)
+	shortURLs := make([]string, 0, len(urls))
+	for _, url := range urls {
+		shortURLs = append(shortURLs, url.ShortURL)
+	}
+	return shortURLs
+}
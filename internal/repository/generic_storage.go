@@ -1,11 +1,17 @@
 package repository
 
 import (
-	"bufio"
+	"cmp"
 	"context"
 	"encoding/json"
+	"errors"
 	"fmt"
 	"os"
+	"slices"
+	"sync"
+	"time"
+
+	"go.uber.org/zap"
 
 	"github.com/radiophysiker/shortener_link/internal/entity"
 	"github.com/radiophysiker/shortener_link/internal/usecases"
@@ -16,35 +22,173 @@ type (
 	FullURL  = string
 )
 
+// fullURLKey identifies the URLs that conflict with each other: full URLs are only deduplicated per user,
+// since a user may edit or delete their URLs and must never be handed a URL of another user.
+// URLs with options are not deduplicated at all.
+type fullURLKey struct {
+	userID  string
+	fullURL FullURL
+}
+
+func newFullURLKey(url entity.URL) fullURLKey {
+	return fullURLKey{userID: url.UserID, fullURL: url.FullURL}
+}
+
+// GenericStorage keeps URLs in memory and, if a file path is given, appends them to a JSON-lines file.
+// It is safe for concurrent use.
 type GenericStorage struct {
 	filePath string
-	urls     map[ShortURL]FullURL
-	count    int64
-	file     *os.File
+	opts     GenericStorageOptions
+
+	// mu guards urls, byFullURL, created, revisions, count, records, unsynced and writes to file.
+	mu        sync.RWMutex
+	urls      map[ShortURL]entity.URL
+	byFullURL map[fullURLKey]ShortURL
+	// created holds the creation sequence numbers of the short URLs, so that they can be listed
+	// in the order they were created in, like the database backends do. createdCount is the last one issued.
+	created      map[ShortURL]int64
+	createdCount int64
+	// revisions holds the edits of the full URLs, oldest first. revisionCount is their total number.
+	revisions     map[ShortURL][]entity.URLRevision
+	revisionCount int64
+	count         int64
+	file          *os.File
+	// records is the number of records in file, live or not.
+	records  int64
+	unsynced bool
+
+	// clicks holds the number of clicks per short URL per UTC day.
+	clicksMu       sync.Mutex
+	clicks         map[ShortURL]map[time.Time]int64
+	clicksFile     *os.File
+	clicksUnsynced bool
+
+	stateMu sync.Mutex
+	state   StorageState
+	seq     int64
+
+	compactCh  chan struct{}
+	stop       chan struct{}
+	stopOnce   sync.Once
+	maintained chan struct{}
 }
 
+const (
+	// urlFileFormat identifies a URL file in its header line.
+	urlFileFormat = "shortener-urls"
+	// urlFileVersion is the version of the URL file written by this build.
+	// Version 1 files have no header line, their records have the same fields as FileRecord.
+	// Version 2 files have no revisions. Version 3 files have no redirect statuses.
+	// Version 4 files have no passthrough modes. Version 5 files have no password hashes.
+	// Version 6 files have no click limits.
+	urlFileVersion = 7
+)
+
+var ErrUnsupportedFileVersion = errors.New("unsupported storage file version")
+
+// FileHeader is the first line of a URL file.
+type FileHeader struct {
+	Format  string `json:"format"`
+	Version int    `json:"version"`
+}
+
+// FileRecord is a line of a URL file. A later record for the same short URL replaces the earlier ones.
 type FileRecord struct {
-	UUID        int64  `json:"uuid"`
-	ShortURL    string `json:"short_url"`
-	OriginalURL string `json:"original_url"`
+	UUID        int64      `json:"uuid"`
+	ShortURL    string     `json:"short_url"`
+	OriginalURL string     `json:"original_url"`
+	UserID      string     `json:"user_id,omitempty"`
+	IsDeleted   bool       `json:"is_deleted,omitempty"`
+	ExpiresAt   *time.Time `json:"expires_at,omitempty"`
+	// RedirectStatus is zero if the URL redirects with the configured default status.
+	RedirectStatus int    `json:"redirect_status,omitempty"`
+	Passthrough    string `json:"passthrough,omitempty"`
+	PasswordHash   string `json:"password_hash,omitempty"`
+	MaxClicks      int64  `json:"max_clicks,omitempty"`
+	Redirects      int64  `json:"redirects,omitempty"`
+	// Revision is set if the record was written by an edit that changed the full URL to OriginalURL.
+	Revision *FileRevision `json:"revision,omitempty"`
+}
+
+// FileRevision describes the edit that produced a FileRecord.
+type FileRevision struct {
+	PreviousURL string    `json:"previous_url"`
+	ChangedAt   time.Time `json:"changed_at"`
+}
+
+func newFileRecord(uuid int64, url entity.URL) FileRecord {
+	record := FileRecord{
+		UUID:           uuid,
+		ShortURL:       url.ShortURL,
+		OriginalURL:    url.FullURL,
+		UserID:         url.UserID,
+		IsDeleted:      url.IsDeleted,
+		RedirectStatus: url.RedirectStatus,
+		Passthrough:    string(url.Passthrough),
+		PasswordHash:   url.PasswordHash,
+		MaxClicks:      url.MaxClicks,
+		Redirects:      url.Redirects,
+	}
+	if !url.ExpiresAt.IsZero() {
+		record.ExpiresAt = &url.ExpiresAt
+	}
+	return record
+}
+
+func (r FileRecord) URL() entity.URL {
+	url := entity.URL{
+		ShortURL:       r.ShortURL,
+		FullURL:        r.OriginalURL,
+		UserID:         r.UserID,
+		IsDeleted:      r.IsDeleted,
+		RedirectStatus: r.RedirectStatus,
+		Passthrough:    entity.PassthroughMode(r.Passthrough),
+		PasswordHash:   r.PasswordHash,
+		MaxClicks:      r.MaxClicks,
+		Redirects:      r.Redirects,
+	}
+	if r.ExpiresAt != nil {
+		url.ExpiresAt = *r.ExpiresAt
+	}
+	return url
 }
 
 func NewGenericStorage(filePath string) (*GenericStorage, error) {
+	return NewGenericStorageWithOptions(filePath, DefaultGenericStorageOptions())
+}
+
+func NewGenericStorageWithOptions(filePath string, opts GenericStorageOptions) (*GenericStorage, error) {
+	if err := opts.validate(); err != nil {
+		return nil, err
+	}
 	fs := &GenericStorage{
-		urls:     make(map[ShortURL]FullURL),
-		filePath: filePath,
-		count:    0,
+		urls:      make(map[ShortURL]entity.URL),
+		byFullURL: make(map[fullURLKey]ShortURL),
+		created:   make(map[ShortURL]int64),
+		revisions: make(map[ShortURL][]entity.URLRevision),
+		clicks:    make(map[ShortURL]map[time.Time]int64),
+		filePath:  filePath,
+		opts:      opts,
+		count:     0,
+		compactCh: make(chan struct{}, 1),
+		stop:      make(chan struct{}),
 	}
 	if filePath != "" {
 		err := fs.init()
 		if err != nil {
-			return nil, err
+			return nil, errors.Join(err, fs.Close())
+		}
+		fs.maintained = make(chan struct{})
+		go fs.maintain()
+		if fs.needsCompaction() {
+			fs.requestCompaction()
 		}
 	}
 	return fs, nil
 }
 
 // init initializes the GenericStorage by loading URL mapping data from the specified file.
+// Files of older versions are upgraded in place, corrupt lines are moved to quarantine.
 func (fs *GenericStorage) init() error {
 	file, err := os.OpenFile(fs.filePath, os.O_RDWR|os.O_CREATE|os.O_APPEND, 0644)
 	if err != nil {
@@ -52,129 +196,374 @@ func (fs *GenericStorage) init() error {
 	}
 	fs.file = file
 
-	scanner := bufio.NewScanner(file)
-	for scanner.Scan() {
-		var record entity.URL
-		if err := json.Unmarshal(scanner.Bytes(), &record); err != nil {
+	version, records, scan, err := readURLFile(file)
+	if err != nil {
+		return err
+	}
+	for _, record := range records {
+		fs.replay(record)
+		fs.count = max(fs.count, record.UUID)
+	}
+	fs.records = int64(len(records))
+	if err := quarantine(fs.filePath, scan.corrupt); err != nil {
+		return err
+	}
+	if version < urlFileVersion || scan.dirty {
+		if version > 0 && version < urlFileVersion {
+			zap.L().Info("upgrading storage file",
+				zap.String("path", fs.filePath), zap.Int("from", version), zap.Int("to", urlFileVersion))
+		}
+		if err := fs.rewrite(records); err != nil {
 			return err
 		}
-		fs.urls[record.ShortURL] = record.FullURL
-		fs.count++
 	}
 
-	if err := scanner.Err(); err != nil {
-		return fmt.Errorf("failed to scan file: %w", err)
+	if err := fs.loadState(); err != nil {
+		return err
 	}
-
-	return nil
+	return fs.initClicks()
 }
 
-func (fs *GenericStorage) checkURLExists(url entity.URL, isGeneratedShortURL bool) error {
-	if isGeneratedShortURL {
-		_, exists := fs.urls[url.ShortURL]
-		if exists {
-			return usecases.ErrURLGeneratedBefore
+// readURLFile reads the records of a URL file. The returned version is 0 for an empty file.
+func readURLFile(file *os.File) (int, []FileRecord, logScan, error) {
+	var (
+		version int
+		records []FileRecord
+	)
+	scan, err := scanLog(file, func(line []byte) error {
+		if version == 0 {
+			var header FileHeader
+			if err := json.Unmarshal(line, &header); err == nil && header.Format == urlFileFormat {
+				version = header.Version
+				return nil
+			}
+			version = 1
 		}
+		var record FileRecord
+		if err := json.Unmarshal(line, &record); err != nil {
+			return err
+		}
+		if record.ShortURL == "" || record.OriginalURL == "" {
+			return errors.New("incomplete record")
+		}
+		records = append(records, record)
+		return nil
+	})
+	if err != nil {
+		return 0, nil, scan, err
 	}
-	_, exists := fs.urls[url.ShortURL]
-	if exists {
-		return usecases.ErrURLConflict
+	if version > urlFileVersion {
+		return 0, nil, scan, fmt.Errorf("%w: %d", ErrUnsupportedFileVersion, version)
 	}
-	for _, fullURL := range fs.urls {
-		if fullURL == url.FullURL {
-			return usecases.ErrURLConflict
+	return version, records, scan, nil
+}
+
+// rewrite replaces the URL file with a file of the current version holding the given records.
+// The caller must hold fs.mu.
+func (fs *GenericStorage) rewrite(records []FileRecord) error {
+	header, err := json.Marshal(FileHeader{Format: urlFileFormat, Version: urlFileVersion})
+	if err != nil {
+		return fmt.Errorf("failed to marshal header: %w", err)
+	}
+	lines := make([][]byte, 0, len(records)+1)
+	lines = append(lines, header)
+	for _, record := range records {
+		data, err := json.Marshal(record)
+		if err != nil {
+			return fmt.Errorf("failed to marshal record: %w", err)
 		}
+		lines = append(lines, data)
 	}
+	file, err := replaceFile(fs.filePath, fs.file, lines)
+	if file != nil {
+		fs.file = file
+	}
+	if err != nil {
+		return err
+	}
+	fs.records = int64(len(records))
 	return nil
 }
 
+// checkURLExists reports whether the user has already shortened the full URL or the short URL is taken.
+// The checks go in the same order as in PostgresStorage.Save. The caller must hold fs.mu.
+func (fs *GenericStorage) checkURLExists(url entity.URL) error {
+	if existingShortURL, exists := fs.findByFullURL(url); exists {
+		return &usecases.ConflictError{ExistingShortURL: existingShortURL}
+	}
+	if _, exists := fs.urls[url.ShortURL]; exists {
+		return usecases.ErrURLGeneratedBefore
+	}
+	return nil
+}
+
+// findByFullURL returns the short URL the user of url has shortened its full URL to without options.
+// Nothing is returned for a URL with options, since it is never deduplicated. The caller must hold fs.mu.
+func (fs *GenericStorage) findByFullURL(url entity.URL) (ShortURL, bool) {
+	if url.HasOptions() {
+		return "", false
+	}
+	shortURL, exists := fs.byFullURL[newFullURLKey(url)]
+	return shortURL, exists
+}
+
+// put stores the URL in both indexes and gives a new short URL the next creation sequence number.
+// Edited and deleted URLs and URLs with options are left out of the full URL index, so that they
+// do not take part in full URL conflict checks. URLs that can expire have an option, so an expired URL
+// never holds its full URL. The caller must hold fs.mu.
+func (fs *GenericStorage) put(url entity.URL) {
+	fs.urls[url.ShortURL] = url
+	if _, exists := fs.created[url.ShortURL]; !exists {
+		fs.createdCount++
+		fs.created[url.ShortURL] = fs.createdCount
+	}
+	if len(fs.revisions[url.ShortURL]) == 0 && !url.IsDeleted && !url.HasOptions() {
+		fs.byFullURL[newFullURLKey(url)] = url.ShortURL
+	}
+}
+
+// remove removes the URL from both indexes. It keeps the creation sequence number for the URL put back
+// in its place, see purge. The caller must hold fs.mu.
+func (fs *GenericStorage) remove(url entity.URL) {
+	delete(fs.urls, url.ShortURL)
+	if key := newFullURLKey(url); fs.byFullURL[key] == url.ShortURL {
+		delete(fs.byFullURL, key)
+	}
+}
+
+// purge removes the URL for good, along with its revisions and creation sequence number,
+// so that a short URL reused later starts afresh. The caller must hold fs.mu.
+func (fs *GenericStorage) purge(url entity.URL) {
+	fs.remove(url)
+	fs.dropRevisions(url.ShortURL)
+	delete(fs.created, url.ShortURL)
+}
+
 func (fs *GenericStorage) getCount() int64 {
 	fs.count++
 	return fs.count
 }
 
+// writeRecord appends the URL to the storage file, if the storage is file-backed.
+// The caller must hold fs.mu.
+func (fs *GenericStorage) writeRecord(url entity.URL) error {
+	return fs.writeRevisionRecord(url, nil)
+}
+
+// writeRevisionRecord is writeRecord for a URL produced by the given edit, if it is not nil.
+// The caller must hold fs.mu.
+func (fs *GenericStorage) writeRevisionRecord(url entity.URL, revision *FileRevision) error {
+	if fs.filePath == "" {
+		return nil
+	}
+	record := newFileRecord(fs.getCount(), url)
+	record.Revision = revision
+	data, err := json.Marshal(record)
+	if err != nil {
+		return fmt.Errorf("failed to marshal record: %w", err)
+	}
+
+	if _, err := fs.file.Write(append(data, '\n')); err != nil {
+		return fmt.Errorf("failed to write to file: %w", err)
+	}
+	return fs.afterWrite()
+}
+
 func (fs *GenericStorage) Save(ctx context.Context, url entity.URL) error {
 	if url.FullURL == "" {
 		return usecases.ErrEmptyFullURL
 	}
-	err := fs.checkURLExists(url, true)
+	fs.mu.Lock()
+	defer fs.mu.Unlock()
+	err := fs.checkURLExists(url)
 	if err != nil {
 		return err
 	}
 
-	if fs.filePath != "" {
-		uuid := fs.getCount()
-		record := FileRecord{
-			UUID:        uuid,
-			ShortURL:    url.ShortURL,
-			OriginalURL: url.FullURL,
-		}
-		data, err := json.Marshal(record)
-		if err != nil {
-			return fmt.Errorf("failed to marshal record: %w", err)
-		}
-
-		if _, err := fs.file.Write(append(data, '\n')); err != nil {
-			return fmt.Errorf("failed to write to file: %w", err)
-		}
+	if err := fs.writeRecord(url); err != nil {
+		return err
 	}
 
-	fs.urls[url.ShortURL] = url.FullURL
+	fs.put(url)
 	return nil
 }
 
 func (fs *GenericStorage) GetFullURL(ctx context.Context, shortURL ShortURL) (FullURL, error) {
+	url, err := fs.ResolveURL(ctx, shortURL)
+	return url.FullURL, err
+}
+
+func (fs *GenericStorage) ResolveURL(ctx context.Context, shortURL ShortURL) (entity.URL, error) {
+	url, err := fs.GetURL(ctx, shortURL)
+	if err != nil {
+		return entity.URL{}, err
+	}
+	return resolveURL(url, time.Now())
+}
+
+// ConsumeClick counts the redirect and appends a record with the new count to the storage file,
+// so that a restart does not give the URL its clicks back.
+func (fs *GenericStorage) ConsumeClick(ctx context.Context, shortURL ShortURL) (int64, error) {
 	if shortURL == "" {
-		return "", usecases.ErrEmptyShortURL
+		return 0, usecases.ErrEmptyShortURL
 	}
-	fullURL, exists := fs.urls[shortURL]
+	fs.mu.Lock()
+	defer fs.mu.Unlock()
+	url, exists := fs.urls[shortURL]
 	if !exists {
-		return "", fmt.Errorf("%w for: %s", usecases.ErrURLNotFound, shortURL)
+		return 0, fmt.Errorf("%w for: %s", usecases.ErrURLNotFound, shortURL)
 	}
-	return fullURL, nil
+	url, err := resolveURL(url, time.Now())
+	if err != nil {
+		return 0, err
+	}
+	if url.MaxClicks == 0 {
+		return 0, nil
+	}
+	url.Redirects++
+	if err := fs.writeRecord(url); err != nil {
+		return 0, err
+	}
+	fs.put(url)
+	return url.MaxClicks - url.Redirects, nil
 }
 
+func (fs *GenericStorage) GetURL(ctx context.Context, shortURL ShortURL) (entity.URL, error) {
+	if shortURL == "" {
+		return entity.URL{}, usecases.ErrEmptyShortURL
+	}
+	fs.mu.RLock()
+	url, exists := fs.urls[shortURL]
+	fs.mu.RUnlock()
+	if !exists {
+		return entity.URL{}, fmt.Errorf("%w for: %s", usecases.ErrURLNotFound, shortURL)
+	}
+	return url, nil
+}
+
+func (fs *GenericStorage) GetURLsByUserID(ctx context.Context, userID string) ([]entity.URL, error) {
+	if userID == "" {
+		return nil, usecases.ErrEmptyUserID
+	}
+	fs.mu.RLock()
+	defer fs.mu.RUnlock()
+	var urls []entity.URL
+	for _, url := range fs.urls {
+		if url.UserID == userID && !url.IsDeleted {
+			urls = append(urls, url)
+		}
+	}
+	slices.SortFunc(urls, func(a, b entity.URL) int {
+		return cmp.Compare(fs.created[a.ShortURL], fs.created[b.ShortURL])
+	})
+	return urls, nil
+}
+
+// Close stops background maintenance, flushes the storage files to disk and closes them.
 func (fs *GenericStorage) Close() error {
-	if fs.file != nil {
-		return fs.file.Close()
+	fs.stopOnce.Do(func() { close(fs.stop) })
+	if fs.maintained != nil {
+		<-fs.maintained
 	}
-	return nil
+	fs.mu.Lock()
+	defer fs.mu.Unlock()
+	fs.clicksMu.Lock()
+	defer fs.clicksMu.Unlock()
+	var errs []error
+	for _, file := range []*os.File{fs.clicksFile, fs.file} {
+		if file == nil {
+			continue
+		}
+		if err := file.Sync(); err != nil {
+			errs = append(errs, fmt.Errorf("failed to sync %s: %w", file.Name(), err))
+		}
+		if err := file.Close(); err != nil {
+			errs = append(errs, fmt.Errorf("failed to close %s: %w", file.Name(), err))
+		}
+	}
+	return errors.Join(errs...)
 }
 
-func (fs *GenericStorage) SaveBatch(ctx context.Context, urls []entity.URL) error {
+// SaveBatch saves the URLs one by one. A URL whose full URL its user has already stored is reported as existing
+// and a URL whose short URL is taken is skipped, neither of them stops the rest of the batch.
+func (fs *GenericStorage) SaveBatch(ctx context.Context, urls []entity.URL) ([]usecases.SaveResult, error) {
 	if len(urls) == 0 {
-		return nil
+		return nil, usecases.ErrEmptyBatch
 	}
-
 	for _, url := range urls {
 		if url.FullURL == "" {
-			return usecases.ErrEmptyFullURL
+			return nil, usecases.ErrEmptyFullURL
 		}
 		if url.ShortURL == "" {
-			return usecases.ErrEmptyShortURL
+			return nil, usecases.ErrEmptyShortURL
 		}
-		err := fs.checkURLExists(url, false)
-		if err != nil {
-			return fmt.Errorf("failed to check if URL exists: %w", err)
-		}
-		if fs.filePath == "" {
-			uuid := fs.getCount()
-			record := FileRecord{
-				UUID:        uuid,
-				ShortURL:    url.ShortURL,
-				OriginalURL: url.FullURL,
-			}
-			data, err := json.Marshal(record)
-			if err != nil {
-				return fmt.Errorf("failed to marshal record: %w", err)
-			}
+	}
 
-			if _, err := fs.file.Write(append(data, '\n')); err != nil {
-				return fmt.Errorf("failed to write to file: %w", err)
-			}
+	fs.mu.Lock()
+	defer fs.mu.Unlock()
+	results := make([]usecases.SaveResult, 0, len(urls))
+	for _, url := range urls {
+		if existingShortURL, exists := fs.findByFullURL(url); exists {
+			results = append(results, usecases.SaveResult{Status: usecases.SaveExisting, ShortURL: existingShortURL})
+			continue
+		}
+		if _, exists := fs.urls[url.ShortURL]; exists {
+			results = append(results, usecases.SaveResult{Status: usecases.SaveShortURLTaken})
+			continue
 		}
-		fs.urls[url.ShortURL] = url.FullURL
+		if err := fs.writeRecord(url); err != nil {
+			// The URLs before this one have been saved.
+			return results, err
+		}
+		fs.put(url)
+		results = append(results, usecases.SaveResult{Status: usecases.SaveCreated, ShortURL: url.ShortURL})
 	}
+	return results, nil
+}
 
+// DeleteURLs marks the URLs as deleted. A URL is only deleted if it belongs to the given user,
+// other URLs are silently skipped.
+func (fs *GenericStorage) DeleteURLs(ctx context.Context, urls []entity.URL) error {
+	fs.mu.Lock()
+	defer fs.mu.Unlock()
+	for _, url := range urls {
+		existing, exists := fs.urls[url.ShortURL]
+		if !exists || existing.IsDeleted || existing.UserID != url.UserID {
+			continue
+		}
+		deleted := existing
+		deleted.IsDeleted = true
+		if err := fs.writeRecord(deleted); err != nil {
+			return err
+		}
+		fs.remove(existing)
+		fs.put(deleted)
+	}
 	return nil
 }
+
+// PurgeExpiredURLs removes URLs that expired before the given moment and returns how many were removed.
+// Their clicks go along with them, so that a reused short URL does not inherit them.
+// The URL file is compacted right away if anything was removed, so that the purged URLs do not come back
+// on the next start and the file does not keep their records.
+func (fs *GenericStorage) PurgeExpiredURLs(ctx context.Context, before time.Time) (int64, error) {
+	fs.mu.Lock()
+	defer fs.mu.Unlock()
+	purged := make(map[ShortURL]struct{})
+	for _, url := range fs.urls {
+		if !url.ExpiresAt.IsZero() && url.ExpiresAt.Before(before) {
+			fs.purge(url)
+			purged[url.ShortURL] = struct{}{}
+		}
+	}
+	if len(purged) == 0 {
+		return 0, nil
+	}
+	if err := fs.dropClicks(purged); err != nil {
+		return int64(len(purged)), err
+	}
+	if err := fs.compact(); err != nil {
+		return int64(len(purged)), err
+	}
+	return int64(len(purged)), nil
+}
@@ -0,0 +1,180 @@
+package repository
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"sort"
+	"time"
+
+	"github.com/radiophysiker/shortener_link/internal/entity"
+	"github.com/radiophysiker/shortener_link/internal/usecases"
+)
+
+// clicksFileSuffix is appended to the storage file path to get the path of the append-only clicks log.
+const clicksFileSuffix = ".clicks"
+
+type ClickRecord struct {
+	ShortURL  string    `json:"short_url"`
+	ClickedAt time.Time `json:"clicked_at"`
+	Referrer  string    `json:"referrer,omitempty"`
+	UserAgent string    `json:"user_agent,omitempty"`
+	IPHash    string    `json:"ip_hash,omitempty"`
+}
+
+// initClicks loads click counters from the clicks log beside the storage file.
+// Corrupt lines are moved to quarantine.
+func (fs *GenericStorage) initClicks() error {
+	file, err := os.OpenFile(fs.filePath+clicksFileSuffix, os.O_RDWR|os.O_CREATE|os.O_APPEND, 0644)
+	if err != nil {
+		return err
+	}
+	fs.clicksFile = file
+
+	scan, err := scanLog(file, func(line []byte) error {
+		var record ClickRecord
+		if err := json.Unmarshal(line, &record); err != nil {
+			return err
+		}
+		if record.ShortURL == "" {
+			return errors.New("incomplete click")
+		}
+		fs.countClick(record.ShortURL, record.ClickedAt)
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+	if err := quarantine(file.Name(), scan.corrupt); err != nil {
+		return err
+	}
+	if scan.dirty {
+		replaced, err := replaceFile(file.Name(), file, scan.lines)
+		if replaced != nil {
+			fs.clicksFile = replaced
+		}
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// countClick increments the click counter. The caller must hold fs.clicksMu.
+func (fs *GenericStorage) countClick(shortURL ShortURL, clickedAt time.Time) {
+	daily, ok := fs.clicks[shortURL]
+	if !ok {
+		daily = make(map[time.Time]int64)
+		fs.clicks[shortURL] = daily
+	}
+	daily[truncateToDay(clickedAt)]++
+}
+
+func (fs *GenericStorage) SaveClicks(ctx context.Context, clicks []entity.Click) error {
+	fs.clicksMu.Lock()
+	defer fs.clicksMu.Unlock()
+	if fs.clicksFile != nil {
+		var buf []byte
+		for _, click := range clicks {
+			data, err := json.Marshal(ClickRecord(click))
+			if err != nil {
+				return fmt.Errorf("failed to marshal click: %w", err)
+			}
+			buf = append(buf, data...)
+			buf = append(buf, '\n')
+		}
+		if _, err := fs.clicksFile.Write(buf); err != nil {
+			return fmt.Errorf("failed to write clicks: %w", err)
+		}
+		if fs.opts.SyncPolicy == SyncAlways {
+			if err := fs.clicksFile.Sync(); err != nil {
+				return fmt.Errorf("failed to sync clicks file: %w", err)
+			}
+		} else {
+			fs.clicksUnsynced = true
+		}
+	}
+	for _, click := range clicks {
+		fs.countClick(click.ShortURL, click.ClickedAt)
+	}
+	return nil
+}
+
+// dropClicks removes the clicks of the short URLs, so that a reused short URL does not inherit them.
+// The clicks log is rewritten without their records.
+func (fs *GenericStorage) dropClicks(shortURLs map[ShortURL]struct{}) error {
+	fs.clicksMu.Lock()
+	defer fs.clicksMu.Unlock()
+	var clicked bool
+	for shortURL := range shortURLs {
+		if _, ok := fs.clicks[shortURL]; ok {
+			delete(fs.clicks, shortURL)
+			clicked = true
+		}
+	}
+	if fs.clicksFile == nil || !clicked {
+		return nil
+	}
+
+	if _, err := fs.clicksFile.Seek(0, io.SeekStart); err != nil {
+		return fmt.Errorf("failed to read clicks file: %w", err)
+	}
+	var kept [][]byte
+	scan, err := scanLog(fs.clicksFile, func(line []byte) error {
+		var record ClickRecord
+		if err := json.Unmarshal(line, &record); err != nil {
+			return err
+		}
+		if _, drop := shortURLs[record.ShortURL]; !drop {
+			kept = append(kept, line)
+		}
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+	if err := quarantine(fs.clicksFile.Name(), scan.corrupt); err != nil {
+		return err
+	}
+	file, err := replaceFile(fs.clicksFile.Name(), fs.clicksFile, kept)
+	if file != nil {
+		fs.clicksFile = file
+		fs.clicksUnsynced = false
+	}
+	if err != nil {
+		return err
+	}
+	return nil
+}
+
+func (fs *GenericStorage) GetClickStats(ctx context.Context, shortURL ShortURL) (entity.ClickStats, error) {
+	if shortURL == "" {
+		return entity.ClickStats{}, usecases.ErrEmptyShortURL
+	}
+	fs.mu.RLock()
+	_, exists := fs.urls[shortURL]
+	fs.mu.RUnlock()
+	if !exists {
+		return entity.ClickStats{}, fmt.Errorf("%w for: %s", usecases.ErrURLNotFound, shortURL)
+	}
+
+	fs.clicksMu.Lock()
+	defer fs.clicksMu.Unlock()
+	stats := entity.ClickStats{ShortURL: shortURL}
+	for day, clicks := range fs.clicks[shortURL] {
+		stats.Total += clicks
+		stats.Daily = append(stats.Daily, entity.DailyClicks{Date: day, Clicks: clicks})
+	}
+	sort.Slice(stats.Daily, func(i, j int) bool {
+		return stats.Daily[i].Date.Before(stats.Daily[j].Date)
+	})
+	return stats, nil
+}
+
+func truncateToDay(t time.Time) time.Time {
+	t = t.UTC()
+	return time.Date(t.Year(), t.Month(), t.Day(), 0, 0, 0, 0, time.UTC)
+}
@@ -0,0 +1,198 @@
+package repository
+
+import (
+	"cmp"
+	"errors"
+	"fmt"
+	"maps"
+	"slices"
+	"time"
+
+	"go.uber.org/zap"
+)
+
+// SyncPolicy defines when GenericStorage writes are flushed to disk with fsync.
+type SyncPolicy string
+
+const (
+	// SyncAlways flushes every write before it is acknowledged.
+	SyncAlways SyncPolicy = "always"
+	// SyncInterval flushes writes in the background every GenericStorageOptions.SyncInterval.
+	SyncInterval SyncPolicy = "interval"
+	// SyncNever leaves flushing to the operating system. Files are still flushed on Close.
+	SyncNever SyncPolicy = "never"
+)
+
+// minCompactionRecords is the file size in records below which the size ratio never triggers compaction.
+const minCompactionRecords = 1000
+
+var ErrUnknownSyncPolicy = errors.New("unknown sync policy")
+
+type GenericStorageOptions struct {
+	SyncPolicy   SyncPolicy
+	SyncInterval time.Duration
+	// CompactionRatio triggers compaction once the URL file holds this many times more records than there are
+	// live URLs. Zero disables it.
+	CompactionRatio float64
+	// CompactionInterval is how often the URL file is compacted regardless of its size. Zero disables it.
+	CompactionInterval time.Duration
+}
+
+func DefaultGenericStorageOptions() GenericStorageOptions {
+	return GenericStorageOptions{
+		SyncPolicy:      SyncInterval,
+		SyncInterval:    time.Second,
+		CompactionRatio: 2,
+	}
+}
+
+func (o GenericStorageOptions) validate() error {
+	switch o.SyncPolicy {
+	case SyncAlways, SyncNever:
+	case SyncInterval:
+		if o.SyncInterval <= 0 {
+			return fmt.Errorf("%w: sync interval must be positive", ErrUnknownSyncPolicy)
+		}
+	default:
+		return fmt.Errorf("%w: %q", ErrUnknownSyncPolicy, o.SyncPolicy)
+	}
+	return nil
+}
+
+// Compact replaces the URL file with a snapshot of the live records: superseded records and purged URLs are dropped.
+// The snapshot is written to a temporary file that atomically replaces the URL file, writes wait until it is done.
+func (fs *GenericStorage) Compact() error {
+	fs.mu.Lock()
+	defer fs.mu.Unlock()
+	return fs.compact()
+}
+
+// compact is Compact for a caller that holds fs.mu.
+func (fs *GenericStorage) compact() error {
+	if fs.filePath == "" {
+		return nil
+	}
+	before := fs.records
+	var uuid int64
+	nextUUID := func() int64 {
+		uuid++
+		return uuid
+	}
+	records := make([]FileRecord, 0, fs.liveRecords())
+	// The URLs are written in the order they were created in, so that they are put in the same order on the next start.
+	shortURLs := slices.SortedFunc(maps.Keys(fs.urls), func(a, b ShortURL) int {
+		return cmp.Compare(fs.created[a], fs.created[b])
+	})
+	for _, shortURL := range shortURLs {
+		records = append(records, fs.fileRecords(fs.urls[shortURL], nextUUID)...)
+	}
+	if err := fs.rewrite(records); err != nil {
+		return fmt.Errorf("failed to compact storage file: %w", err)
+	}
+	fs.count = uuid
+	fs.unsynced = false
+	zap.L().Info("compacted storage file",
+		zap.String("path", fs.filePath), zap.Int64("records_before", before), zap.Int64("records_after", fs.records))
+	return nil
+}
+
+// needsCompaction reports whether the URL file has grown past the compaction ratio. The caller must hold fs.mu.
+func (fs *GenericStorage) needsCompaction() bool {
+	if fs.opts.CompactionRatio <= 0 || fs.records < minCompactionRecords {
+		return false
+	}
+	return float64(fs.records) >= fs.opts.CompactionRatio*float64(max(fs.liveRecords(), 1))
+}
+
+// liveRecords returns about how many records a compacted URL file holds. The caller must hold fs.mu.
+func (fs *GenericStorage) liveRecords() int64 {
+	return int64(len(fs.urls)) + fs.revisionCount
+}
+
+// requestCompaction asks the maintenance goroutine to compact the URL file, unless it has already been asked.
+func (fs *GenericStorage) requestCompaction() {
+	select {
+	case fs.compactCh <- struct{}{}:
+	default:
+	}
+}
+
+// afterWrite applies the sync policy to the URL file and checks if it needs compaction. The caller must hold fs.mu.
+func (fs *GenericStorage) afterWrite() error {
+	fs.records++
+	if fs.opts.SyncPolicy == SyncAlways {
+		if err := fs.file.Sync(); err != nil {
+			return fmt.Errorf("failed to sync storage file: %w", err)
+		}
+	} else {
+		fs.unsynced = true
+	}
+	if fs.needsCompaction() {
+		fs.requestCompaction()
+	}
+	return nil
+}
+
+// maintain syncs and compacts the storage files in the background until the storage is closed.
+func (fs *GenericStorage) maintain() {
+	defer close(fs.maintained)
+	var syncTicks, compactionTicks <-chan time.Time
+	if fs.opts.SyncPolicy == SyncInterval {
+		ticker := time.NewTicker(fs.opts.SyncInterval)
+		defer ticker.Stop()
+		syncTicks = ticker.C
+	}
+	if fs.opts.CompactionInterval > 0 {
+		ticker := time.NewTicker(fs.opts.CompactionInterval)
+		defer ticker.Stop()
+		compactionTicks = ticker.C
+	}
+	for {
+		select {
+		case <-fs.stop:
+			return
+		case <-syncTicks:
+			if err := fs.sync(); err != nil {
+				zap.L().Error("cannot sync storage files", zap.Error(err))
+			}
+		case <-compactionTicks:
+			if err := fs.Compact(); err != nil {
+				zap.L().Error("cannot compact storage file", zap.Error(err))
+			}
+		case <-fs.compactCh:
+			fs.mu.Lock()
+			var err error
+			if fs.needsCompaction() {
+				err = fs.compact()
+			}
+			fs.mu.Unlock()
+			if err != nil {
+				zap.L().Error("cannot compact storage file", zap.Error(err))
+			}
+		}
+	}
+}
+
+// sync flushes the storage files written since the last sync.
+func (fs *GenericStorage) sync() error {
+	var errs []error
+	fs.mu.Lock()
+	if fs.unsynced {
+		if err := fs.file.Sync(); err != nil {
+			errs = append(errs, fmt.Errorf("failed to sync storage file: %w", err))
+		} else {
+			fs.unsynced = false
+		}
+	}
+	fs.mu.Unlock()
+	fs.clicksMu.Lock()
+	if fs.clicksUnsynced {
+		if err := fs.clicksFile.Sync(); err != nil {
+			errs = append(errs, fmt.Errorf("failed to sync clicks file: %w", err))
+		} else {
+			fs.clicksUnsynced = false
+		}
+	}
+	fs.clicksMu.Unlock()
+	return errors.Join(errs...)
+}
@@ -0,0 +1,139 @@
+package repository
+
+import (
+	"bufio"
+	"bytes"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+
+	"go.uber.org/zap"
+)
+
+// quarantineFileSuffix is appended to a storage file path to get the path where its corrupt lines are moved.
+const quarantineFileSuffix = ".corrupt"
+
+// logScan is the result of reading a JSON-lines storage file.
+type logScan struct {
+	// lines are the lines that were decoded successfully, in file order.
+	lines [][]byte
+	// corrupt are the lines that could not be decoded.
+	corrupt [][]byte
+	// dirty reports that the file has to be rewritten before appending to it:
+	// it has corrupt lines, or its last line is not terminated.
+	dirty bool
+}
+
+// scanLog reads the file line by line and passes every non-blank line to decode.
+// Lines decode fails on are collected as corrupt instead of failing the whole read,
+// so that a torn write or a damaged line does not prevent the storage from starting.
+func scanLog(file *os.File, decode func(line []byte) error) (logScan, error) {
+	var scan logScan
+	reader := bufio.NewReader(file)
+	for {
+		line, err := reader.ReadBytes('\n')
+		if len(line) > 0 {
+			if line[len(line)-1] == '\n' {
+				line = line[:len(line)-1]
+			} else {
+				scan.dirty = true
+			}
+			if len(bytes.TrimSpace(line)) > 0 {
+				if decodeErr := decode(line); decodeErr != nil {
+					scan.corrupt = append(scan.corrupt, line)
+					scan.dirty = true
+				} else {
+					scan.lines = append(scan.lines, line)
+				}
+			}
+		}
+		if errors.Is(err, io.EOF) {
+			return scan, nil
+		}
+		if err != nil {
+			return scan, fmt.Errorf("failed to read %s: %w", file.Name(), err)
+		}
+	}
+}
+
+// quarantine appends the corrupt lines to the quarantine file beside path, so that they can be inspected later.
+func quarantine(path string, corrupt [][]byte) error {
+	if len(corrupt) == 0 {
+		return nil
+	}
+	quarantinePath := path + quarantineFileSuffix
+	file, err := os.OpenFile(quarantinePath, os.O_WRONLY|os.O_CREATE|os.O_APPEND, 0644)
+	if err != nil {
+		return fmt.Errorf("failed to open quarantine file: %w", err)
+	}
+	defer file.Close()
+	for _, line := range corrupt {
+		if _, err := file.Write(append(line, '\n')); err != nil {
+			return fmt.Errorf("failed to write to quarantine file: %w", err)
+		}
+	}
+	zap.L().Warn("moved corrupt storage records to quarantine",
+		zap.String("path", path), zap.String("quarantine", quarantinePath), zap.Int("count", len(corrupt)))
+	return file.Sync()
+}
+
+// replaceFile atomically replaces the file at path with the given lines, closes the old handle
+// and returns a new handle opened for appending. The directory is synced as well, so that the replacement
+// survives a crash. If only that fails, the new handle is returned along with the error, as the file
+// has been replaced already.
+func replaceFile(path string, old *os.File, lines [][]byte) (*os.File, error) {
+	tmpPath := path + ".tmp"
+	tmp, err := os.OpenFile(tmpPath, os.O_WRONLY|os.O_CREATE|os.O_TRUNC, 0644)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create %s: %w", tmpPath, err)
+	}
+	writer := bufio.NewWriter(tmp)
+	for _, line := range lines {
+		if _, err := writer.Write(append(line, '\n')); err != nil {
+			tmp.Close()
+			return nil, fmt.Errorf("failed to write %s: %w", tmpPath, err)
+		}
+	}
+	if err := writer.Flush(); err != nil {
+		tmp.Close()
+		return nil, fmt.Errorf("failed to write %s: %w", tmpPath, err)
+	}
+	if err := tmp.Sync(); err != nil {
+		tmp.Close()
+		return nil, fmt.Errorf("failed to sync %s: %w", tmpPath, err)
+	}
+	if err := tmp.Close(); err != nil {
+		return nil, fmt.Errorf("failed to close %s: %w", tmpPath, err)
+	}
+	if err := os.Rename(tmpPath, path); err != nil {
+		return nil, fmt.Errorf("failed to replace %s: %w", path, err)
+	}
+	if old != nil {
+		if err := old.Close(); err != nil {
+			return nil, fmt.Errorf("failed to close %s: %w", path, err)
+		}
+	}
+	file, err := os.OpenFile(path, os.O_RDWR|os.O_CREATE|os.O_APPEND, 0644)
+	if err != nil {
+		return nil, fmt.Errorf("failed to reopen %s: %w", path, err)
+	}
+	if err := syncDir(filepath.Dir(path)); err != nil {
+		return file, fmt.Errorf("failed to replace %s: %w", path, err)
+	}
+	return file, nil
+}
+
+// syncDir flushes the entries of the directory to disk, e.g. a file renamed into it.
+func syncDir(path string) error {
+	dir, err := os.Open(path)
+	if err != nil {
+		return fmt.Errorf("failed to open directory %s: %w", path, err)
+	}
+	defer dir.Close()
+	if err := dir.Sync(); err != nil {
+		return fmt.Errorf("failed to sync directory %s: %w", path, err)
+	}
+	return nil
+}
@@ -0,0 +1,104 @@
+package repository
+
+import (
+	"context"
+	"fmt"
+	"slices"
+	"time"
+
+	"github.com/radiophysiker/shortener_link/internal/entity"
+	"github.com/radiophysiker/shortener_link/internal/usecases"
+)
+
+// UpdateFullURL changes the full URL and appends a record carrying the revision to the storage file.
+func (fs *GenericStorage) UpdateFullURL(ctx context.Context, url entity.URL) error {
+	if url.ShortURL == "" {
+		return usecases.ErrEmptyShortURL
+	}
+	if url.FullURL == "" {
+		return usecases.ErrEmptyFullURL
+	}
+	fs.mu.Lock()
+	defer fs.mu.Unlock()
+	current, exists := fs.urls[url.ShortURL]
+	if !exists {
+		return fmt.Errorf("%w for: %s", usecases.ErrURLNotFound, url.ShortURL)
+	}
+	if current.UserID != url.UserID {
+		return fmt.Errorf("%w: %s", usecases.ErrNotURLOwner, url.ShortURL)
+	}
+	if current.IsDeleted {
+		return fmt.Errorf("%w: %s", usecases.ErrURLDeleted, url.ShortURL)
+	}
+	if current.FullURL == url.FullURL {
+		return nil
+	}
+
+	updated := current
+	updated.FullURL = url.FullURL
+	revision := FileRevision{PreviousURL: current.FullURL, ChangedAt: time.Now().UTC()}
+	if err := fs.writeRevisionRecord(updated, &revision); err != nil {
+		return err
+	}
+	fs.remove(current)
+	fs.addRevision(updated, revision)
+	fs.put(updated)
+	return nil
+}
+
+func (fs *GenericStorage) GetURLRevisions(ctx context.Context, shortURL ShortURL) ([]entity.URLRevision, error) {
+	if shortURL == "" {
+		return nil, usecases.ErrEmptyShortURL
+	}
+	fs.mu.RLock()
+	defer fs.mu.RUnlock()
+	return slices.Clone(fs.revisions[shortURL]), nil
+}
+
+// replay applies a record read from the storage file on top of the earlier ones. The caller must hold fs.mu.
+func (fs *GenericStorage) replay(record FileRecord) {
+	url := record.URL()
+	if previous, exists := fs.urls[url.ShortURL]; exists {
+		fs.remove(previous)
+	}
+	if record.Revision != nil {
+		fs.addRevision(url, *record.Revision)
+	}
+	fs.put(url)
+}
+
+// addRevision records the edit that changed the full URL to url.FullURL. The caller must hold fs.mu.
+func (fs *GenericStorage) addRevision(url entity.URL, revision FileRevision) {
+	fs.revisions[url.ShortURL] = append(fs.revisions[url.ShortURL], entity.URLRevision{
+		ShortURL:        url.ShortURL,
+		PreviousFullURL: revision.PreviousURL,
+		FullURL:         url.FullURL,
+		ChangedAt:       revision.ChangedAt,
+	})
+	fs.revisionCount++
+}
+
+// dropRevisions forgets the revisions of a removed short URL. The caller must hold fs.mu.
+func (fs *GenericStorage) dropRevisions(shortURL ShortURL) {
+	fs.revisionCount -= int64(len(fs.revisions[shortURL]))
+	delete(fs.revisions, shortURL)
+}
+
+// fileRecords returns the records a compacted storage file holds for the URL: a record per revision,
+// each with the full URL set by its revision, or a single record if the URL has never been edited.
+// The caller must hold fs.mu.
+func (fs *GenericStorage) fileRecords(url entity.URL, nextUUID func() int64) []FileRecord {
+	revisions := fs.revisions[url.ShortURL]
+	if len(revisions) == 0 {
+		return []FileRecord{newFileRecord(nextUUID(), url)}
+	}
+	records := make([]FileRecord, 0, len(revisions))
+	for _, revision := range revisions {
+		revised := url
+		revised.FullURL = revision.FullURL
+		record := newFileRecord(nextUUID(), revised)
+		record.Revision = &FileRevision{PreviousURL: revision.PreviousFullURL, ChangedAt: revision.ChangedAt}
+		records = append(records, record)
+	}
+	return records
+}
@@ -0,0 +1,99 @@
+package repository
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+)
+
+const (
+	// stateFileSuffix is appended to the storage file path to get the path of the state file.
+	stateFileSuffix = ".state"
+	// sequenceBlockSize is how many sequence values are reserved in the state file at once,
+	// so that the file is rewritten once per block rather than on every value.
+	sequenceBlockSize = 1000
+)
+
+// StorageState is the GenericStorage state that is not derived from the URL records.
+type StorageState struct {
+	// SequenceReserved is the upper bound of the reserved sequence values.
+	// After a restart the sequence continues from it, so values are never reused.
+	SequenceReserved int64 `json:"sequence_reserved"`
+	// CodeLength is the length of generated codes the storage has grown to.
+	CodeLength int `json:"code_length,omitempty"`
+}
+
+// loadState reads the state file beside the storage file, if there is one.
+func (fs *GenericStorage) loadState() error {
+	data, err := os.ReadFile(fs.filePath + stateFileSuffix)
+	if errors.Is(err, os.ErrNotExist) {
+		return nil
+	}
+	if err != nil {
+		return fmt.Errorf("failed to read state file: %w", err)
+	}
+	if err := json.Unmarshal(data, &fs.state); err != nil {
+		return fmt.Errorf("failed to unmarshal state: %w", err)
+	}
+	fs.seq = fs.state.SequenceReserved
+	return nil
+}
+
+// saveState atomically replaces the state file. The caller must hold fs.stateMu.
+func (fs *GenericStorage) saveState() error {
+	if fs.filePath == "" {
+		return nil
+	}
+	data, err := json.Marshal(fs.state)
+	if err != nil {
+		return fmt.Errorf("failed to marshal state: %w", err)
+	}
+	path := fs.filePath + stateFileSuffix
+	tmpPath := path + ".tmp"
+	if err := os.WriteFile(tmpPath, data, 0644); err != nil {
+		return fmt.Errorf("failed to write state file: %w", err)
+	}
+	if err := os.Rename(tmpPath, path); err != nil {
+		return fmt.Errorf("failed to replace state file: %w", err)
+	}
+	return nil
+}
+
+// NextSequenceValue returns the next value of the storage sequence.
+func (fs *GenericStorage) NextSequenceValue(ctx context.Context) (int64, error) {
+	fs.stateMu.Lock()
+	defer fs.stateMu.Unlock()
+	fs.seq++
+	if fs.seq > fs.state.SequenceReserved {
+		fs.state.SequenceReserved = fs.seq + sequenceBlockSize - 1
+		if err := fs.saveState(); err != nil {
+			fs.seq--
+			fs.state.SequenceReserved = fs.seq
+			return 0, err
+		}
+	}
+	return fs.seq, nil
+}
+
+func (fs *GenericStorage) GetCodeLength(ctx context.Context) (int, error) {
+	fs.stateMu.Lock()
+	defer fs.stateMu.Unlock()
+	return fs.state.CodeLength, nil
+}
+
+func (fs *GenericStorage) SetCodeLength(ctx context.Context, length int) error {
+	fs.stateMu.Lock()
+	defer fs.stateMu.Unlock()
+	if length <= fs.state.CodeLength {
+		return nil
+	}
+	previous := fs.state.CodeLength
+	fs.state.CodeLength = length
+	if err := fs.saveState(); err != nil {
+		fs.state.CodeLength = previous
+		return err
+	}
+	return nil
+}
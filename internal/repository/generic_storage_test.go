@@ -2,11 +2,18 @@ package repository
 
 import (
 	"context"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
 	"testing"
+	"time"
 
 	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/require"
 
+	"github.com/radiophysiker/shortener_link/internal/auth"
 	"github.com/radiophysiker/shortener_link/internal/entity"
 	"github.com/radiophysiker/shortener_link/internal/usecases"
 )
@@ -104,6 +111,180 @@ func TestSaveExistingURL(t *testing.T) {
 	assert.ErrorIs(t, err, usecases.ErrURLGeneratedBefore, "Save should return ErrURLGeneratedBefore for duplicate shortURL")
 }
 
+func TestSaveExistingFullURL(t *testing.T) {
+	urlStorage, err := NewGenericStorage("")
+	require.NoError(t, err, "NewGenericStorage should not return an error")
+
+	require.NoError(t, urlStorage.Save(context.Background(), entity.URL{ShortURL: "short", FullURL: "full"}))
+
+	err = urlStorage.Save(context.Background(), entity.URL{ShortURL: "another_short", FullURL: "full"})
+	require.ErrorIs(t, err, usecases.ErrURLConflict, "Save should return ErrURLConflict for duplicate fullURL")
+	assert.EqualError(t, err, usecases.ErrURLConflict.Error()+": short", "the conflict should carry the existing shortURL")
+}
+
+func TestSaveBatchDuplicateFullURLs(t *testing.T) {
+	urlStorage, err := NewGenericStorage("")
+	require.NoError(t, err, "NewGenericStorage should not return an error")
+
+	results, err := urlStorage.SaveBatch(context.Background(), []entity.URL{
+		{ShortURL: "short1", FullURL: "full"},
+		{ShortURL: "short2", FullURL: "full"},
+	})
+	require.NoError(t, err, "SaveBatch should not return an error")
+	assert.Equal(t, []usecases.SaveResult{
+		{Status: usecases.SaveCreated, ShortURL: "short1"},
+		{Status: usecases.SaveExisting, ShortURL: "short1"},
+	}, results, "a repeated fullURL should resolve to the short URL saved first")
+
+	_, err = urlStorage.GetFullURL(context.Background(), "short2")
+	assert.ErrorIs(t, err, usecases.ErrURLNotFound, "a repeated fullURL should not be saved")
+}
+
+func TestPurgedFullURLCanBeShortenedAgain(t *testing.T) {
+	urlStorage, err := NewGenericStorage("")
+	require.NoError(t, err, "NewGenericStorage should not return an error")
+
+	require.NoError(t, urlStorage.Save(context.Background(), entity.URL{ShortURL: "short", FullURL: "full", ExpiresAt: time.Now().Add(-time.Hour)}))
+	_, err = urlStorage.PurgeExpiredURLs(context.Background(), time.Now())
+	require.NoError(t, err)
+
+	assert.NoError(t, urlStorage.Save(context.Background(), entity.URL{ShortURL: "another_short", FullURL: "full"}))
+}
+
+func TestExpiredFullURLStaysFreeAfterCompaction(t *testing.T) {
+	filePath := filepath.Join(t.TempDir(), "storage.json")
+	urlStorage, err := NewGenericStorage(filePath)
+	require.NoError(t, err, "NewGenericStorage should not return an error")
+
+	// The compacted file holds the records sorted by short URL, so the expired record comes last.
+	require.NoError(t, urlStorage.Save(context.Background(), entity.URL{ShortURL: "z_expired", FullURL: "full", ExpiresAt: time.Now().Add(-time.Hour)}))
+	require.NoError(t, urlStorage.Save(context.Background(), entity.URL{ShortURL: "a_alive", FullURL: "full"}))
+	require.NoError(t, urlStorage.Compact())
+	require.NoError(t, urlStorage.Close())
+
+	urlStorage, err = NewGenericStorage(filePath)
+	require.NoError(t, err, "NewGenericStorage should reopen the compacted file")
+	defer urlStorage.Close()
+	err = urlStorage.Save(context.Background(), entity.URL{ShortURL: "another_short", FullURL: "full"})
+	assert.EqualError(t, err, usecases.ErrURLConflict.Error()+": a_alive", "the conflict should point to the live URL")
+}
+
+func TestConcurrentSave(t *testing.T) {
+	urlStorage, err := NewGenericStorage(filepath.Join(t.TempDir(), "storage.json"))
+	require.NoError(t, err, "NewGenericStorage should not return an error")
+	defer urlStorage.Close()
+
+	const writers, urlsPerWriter = 8, 50
+	var wg sync.WaitGroup
+	for w := range writers {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for i := range urlsPerWriter {
+				url := entity.URL{
+					ShortURL: fmt.Sprintf("short-%d-%d", w, i),
+					FullURL:  fmt.Sprintf("full-%d-%d", w, i),
+					UserID:   "user",
+				}
+				assert.NoError(t, urlStorage.Save(context.Background(), url))
+				_, err := urlStorage.GetFullURL(context.Background(), url.ShortURL)
+				assert.NoError(t, err)
+			}
+		}()
+	}
+	wg.Wait()
+
+	userURLs, err := urlStorage.GetURLsByUserID(context.Background(), "user")
+	require.NoError(t, err)
+	assert.Len(t, userURLs, writers*urlsPerWriter)
+}
+
+func TestConcurrentSaveSameFullURL(t *testing.T) {
+	urlStorage, err := NewGenericStorage("")
+	require.NoError(t, err, "NewGenericStorage should not return an error")
+
+	const writers = 16
+	var (
+		wg        sync.WaitGroup
+		mu        sync.Mutex
+		saved     int
+		conflicts int
+	)
+	for w := range writers {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			err := urlStorage.Save(context.Background(), entity.URL{ShortURL: fmt.Sprintf("short-%d", w), FullURL: "full"})
+			mu.Lock()
+			defer mu.Unlock()
+			switch {
+			case err == nil:
+				saved++
+			case assert.ErrorIs(t, err, usecases.ErrURLConflict):
+				conflicts++
+			}
+		}()
+	}
+	wg.Wait()
+
+	assert.Equal(t, 1, saved, "exactly one writer should win")
+	assert.Equal(t, writers-1, conflicts)
+}
+
+// TestBackgroundWritersWithConcurrentReads runs the deletion worker and the janitor against request reads,
+// so that the race detector catches storage writes made from the background without fs.mu.
+func TestBackgroundWritersWithConcurrentReads(t *testing.T) {
+	urlStorage, err := NewGenericStorage(filepath.Join(t.TempDir(), "storage.json"))
+	require.NoError(t, err, "NewGenericStorage should not return an error")
+	defer urlStorage.Close()
+
+	const urlCount = 100
+	ctx := auth.WithUserID(context.Background(), "user")
+	shortURLs := make([]string, 0, urlCount)
+	for i := range urlCount {
+		url := entity.URL{ShortURL: fmt.Sprintf("short-%d", i), FullURL: fmt.Sprintf("full-%d", i), UserID: "user"}
+		if i%2 == 0 {
+			url.ExpiresAt = time.Now().Add(-time.Hour)
+		}
+		require.NoError(t, urlStorage.Save(ctx, url))
+		shortURLs = append(shortURLs, url.ShortURL)
+	}
+
+	janitorCtx, stopJanitor := context.WithCancel(context.Background())
+	janitorDone := make(chan struct{})
+	go func() {
+		defer close(janitorDone)
+		usecases.NewExpiredURLJanitor(urlStorage, time.Millisecond, 0).Run(janitorCtx)
+	}()
+	deleter := usecases.NewURLDeleter(urlStorage)
+	for _, shortURL := range shortURLs {
+		require.NoError(t, deleter.DeleteUserURLs(ctx, []string{shortURL}))
+	}
+
+	var wg sync.WaitGroup
+	for r := range 4 {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for i := range urlCount {
+				_, _ = urlStorage.GetFullURL(ctx, shortURLs[i])
+				_, err := urlStorage.GetURLsByUserID(ctx, "user")
+				assert.NoError(t, err)
+				assert.NoError(t, urlStorage.Save(ctx, entity.URL{ShortURL: fmt.Sprintf("new-%d-%d", r, i), FullURL: fmt.Sprintf("new-%d-%d", r, i)}))
+			}
+		}()
+	}
+	wg.Wait()
+	require.NoError(t, deleter.Close())
+	stopJanitor()
+	<-janitorDone
+
+	for _, shortURL := range shortURLs {
+		_, err := urlStorage.GetFullURL(ctx, shortURL)
+		assert.Error(t, err, "%s should be deleted or purged", shortURL)
+	}
+}
+
 func TestIsShortURLExists(t *testing.T) {
 	urlStorage, err := NewGenericStorage("")
 	require.NoError(t, err, "NewGenericStorage should not return an error")
@@ -114,13 +295,13 @@ func TestIsShortURLExists(t *testing.T) {
 	}
 
 	// Test non-existent URL
-	err = urlStorage.checkURLExists(url, true)
+	err = urlStorage.checkURLExists(url)
 	if err != nil {
 		require.NoError(t, err)
 	}
 
 	// Save URL and test again
-	err = urlStorage.checkURLExists(url, true)
+	err = urlStorage.checkURLExists(url)
 	require.NoError(t, err)
 }
 
@@ -155,3 +336,409 @@ func TestClose(t *testing.T) {
 	err = urlStorage.Close()
 	assert.NoError(t, err, "Close should not return an error")
 }
+
+func TestSaveBatchInMemory(t *testing.T) {
+	urlStorage, err := NewGenericStorage("")
+	require.NoError(t, err, "NewGenericStorage should not return an error")
+
+	urls := []entity.URL{
+		{ShortURL: "short1", FullURL: "full1", UserID: "user"},
+		{ShortURL: "short2", FullURL: "full2", UserID: "user"},
+	}
+	_, err = urlStorage.SaveBatch(context.Background(), urls)
+	require.NoError(t, err, "SaveBatch should not return an error for in-memory storage")
+
+	for _, url := range urls {
+		fullURL, err := urlStorage.GetFullURL(context.Background(), url.ShortURL)
+		require.NoError(t, err)
+		assert.Equal(t, url.FullURL, fullURL)
+	}
+}
+
+func TestGetURLsByUserID(t *testing.T) {
+	urlStorage, err := NewGenericStorage("")
+	require.NoError(t, err, "NewGenericStorage should not return an error")
+
+	urls := []entity.URL{
+		{ShortURL: "short1", FullURL: "full1", UserID: "user1"},
+		{ShortURL: "short2", FullURL: "full2", UserID: "user2"},
+		{ShortURL: "short3", FullURL: "full3", UserID: "user1"},
+	}
+	for _, url := range urls {
+		require.NoError(t, urlStorage.Save(context.Background(), url))
+	}
+
+	userURLs, err := urlStorage.GetURLsByUserID(context.Background(), "user1")
+	require.NoError(t, err, "GetURLsByUserID should not return an error")
+	assert.ElementsMatch(t, []entity.URL{urls[0], urls[2]}, userURLs)
+
+	userURLs, err = urlStorage.GetURLsByUserID(context.Background(), "unknown")
+	require.NoError(t, err, "GetURLsByUserID should not return an error for unknown user")
+	assert.Empty(t, userURLs)
+
+	_, err = urlStorage.GetURLsByUserID(context.Background(), "")
+	assert.ErrorIs(t, err, usecases.ErrEmptyUserID)
+}
+
+func TestDeleteURLs(t *testing.T) {
+	urlStorage, err := NewGenericStorage("")
+	require.NoError(t, err, "NewGenericStorage should not return an error")
+
+	require.NoError(t, urlStorage.Save(context.Background(), entity.URL{ShortURL: "short1", FullURL: "full1", UserID: "user1"}))
+	require.NoError(t, urlStorage.Save(context.Background(), entity.URL{ShortURL: "short2", FullURL: "full2", UserID: "user2"}))
+
+	err = urlStorage.DeleteURLs(context.Background(), []entity.URL{
+		{ShortURL: "short1", UserID: "user1"},
+		{ShortURL: "short2", UserID: "user1"},
+		{ShortURL: "unknown", UserID: "user1"},
+	})
+	require.NoError(t, err, "DeleteURLs should not return an error")
+
+	_, err = urlStorage.GetFullURL(context.Background(), "short1")
+	assert.ErrorIs(t, err, usecases.ErrURLDeleted, "owner's URL should be deleted")
+
+	fullURL, err := urlStorage.GetFullURL(context.Background(), "short2")
+	require.NoError(t, err, "another user's URL should not be deleted")
+	assert.Equal(t, "full2", fullURL)
+}
+
+func TestExpiredURLs(t *testing.T) {
+	urlStorage, err := NewGenericStorage("")
+	require.NoError(t, err, "NewGenericStorage should not return an error")
+
+	expiredAt := time.Now().Add(-time.Hour)
+	require.NoError(t, urlStorage.Save(context.Background(), entity.URL{ShortURL: "expired", FullURL: "full1", ExpiresAt: expiredAt}))
+	require.NoError(t, urlStorage.Save(context.Background(), entity.URL{ShortURL: "alive", FullURL: "full2", ExpiresAt: time.Now().Add(time.Hour)}))
+
+	_, err = urlStorage.GetFullURL(context.Background(), "expired")
+	assert.ErrorIs(t, err, usecases.ErrURLExpired, "GetFullURL should return ErrURLExpired for expired URL")
+
+	purged, err := urlStorage.PurgeExpiredURLs(context.Background(), time.Now())
+	require.NoError(t, err, "PurgeExpiredURLs should not return an error")
+	assert.Equal(t, int64(1), purged)
+
+	_, err = urlStorage.GetFullURL(context.Background(), "expired")
+	assert.ErrorIs(t, err, usecases.ErrURLNotFound, "purged URL should not be found")
+	fullURL, err := urlStorage.GetFullURL(context.Background(), "alive")
+	require.NoError(t, err)
+	assert.Equal(t, "full2", fullURL)
+}
+
+func TestPurgedURLsAreGoneAfterRestart(t *testing.T) {
+	filePath := filepath.Join(t.TempDir(), "storage.json")
+	urlStorage, err := NewGenericStorage(filePath)
+	require.NoError(t, err, "NewGenericStorage should not return an error")
+
+	require.NoError(t, urlStorage.Save(context.Background(), entity.URL{ShortURL: "expired", FullURL: "full1", ExpiresAt: time.Now().Add(-time.Hour)}))
+	require.NoError(t, urlStorage.Save(context.Background(), entity.URL{ShortURL: "alive", FullURL: "full2"}))
+	purged, err := urlStorage.PurgeExpiredURLs(context.Background(), time.Now())
+	require.NoError(t, err)
+	assert.Equal(t, int64(1), purged)
+	assert.Equal(t, 2, countLines(t, filePath), "the purged record should be dropped from the file")
+	require.NoError(t, urlStorage.Close())
+
+	urlStorage, err = NewGenericStorage(filePath)
+	require.NoError(t, err, "NewGenericStorage should reopen the file")
+	defer urlStorage.Close()
+	_, err = urlStorage.GetURL(context.Background(), "expired")
+	assert.ErrorIs(t, err, usecases.ErrURLNotFound, "a purged URL should not come back after a restart")
+	_, err = urlStorage.GetFullURL(context.Background(), "alive")
+	assert.NoError(t, err)
+}
+
+func TestClickStatsPersistence(t *testing.T) {
+	filePath := filepath.Join(t.TempDir(), "storage.json")
+	urlStorage, err := NewGenericStorage(filePath)
+	require.NoError(t, err, "NewGenericStorage should not return an error")
+	require.NoError(t, urlStorage.Save(context.Background(), entity.URL{ShortURL: "short", FullURL: "full"}))
+
+	day1 := time.Date(2025, 3, 1, 23, 59, 0, 0, time.UTC)
+	day2 := time.Date(2025, 3, 2, 0, 1, 0, 0, time.UTC)
+	err = urlStorage.SaveClicks(context.Background(), []entity.Click{
+		{ShortURL: "short", ClickedAt: day1},
+		{ShortURL: "short", ClickedAt: day2},
+		{ShortURL: "short", ClickedAt: day2.Add(time.Hour)},
+	})
+	require.NoError(t, err, "SaveClicks should not return an error")
+	require.NoError(t, urlStorage.Close())
+
+	urlStorage, err = NewGenericStorage(filePath)
+	require.NoError(t, err, "NewGenericStorage should reopen the storage")
+	defer urlStorage.Close()
+
+	stats, err := urlStorage.GetClickStats(context.Background(), "short")
+	require.NoError(t, err, "GetClickStats should not return an error")
+	assert.Equal(t, int64(3), stats.Total)
+	assert.Equal(t, []entity.DailyClicks{
+		{Date: time.Date(2025, 3, 1, 0, 0, 0, 0, time.UTC), Clicks: 1},
+		{Date: time.Date(2025, 3, 2, 0, 0, 0, 0, time.UTC), Clicks: 2},
+	}, stats.Daily)
+
+	_, err = urlStorage.GetClickStats(context.Background(), "unknown")
+	assert.ErrorIs(t, err, usecases.ErrURLNotFound)
+}
+
+func TestNextSequenceValueSurvivesRestart(t *testing.T) {
+	filePath := filepath.Join(t.TempDir(), "storage.json")
+	urlStorage, err := NewGenericStorage(filePath)
+	require.NoError(t, err, "NewGenericStorage should not return an error")
+
+	first, err := urlStorage.NextSequenceValue(context.Background())
+	require.NoError(t, err)
+	second, err := urlStorage.NextSequenceValue(context.Background())
+	require.NoError(t, err)
+	assert.Equal(t, first+1, second)
+	require.NoError(t, urlStorage.Close())
+
+	urlStorage, err = NewGenericStorage(filePath)
+	require.NoError(t, err, "NewGenericStorage should reopen the storage")
+	defer urlStorage.Close()
+	afterRestart, err := urlStorage.NextSequenceValue(context.Background())
+	require.NoError(t, err)
+	assert.Greater(t, afterRestart, second, "sequence values should not be reused after a restart")
+}
+
+func TestCodeLengthSurvivesRestart(t *testing.T) {
+	filePath := filepath.Join(t.TempDir(), "storage.json")
+	urlStorage, err := NewGenericStorage(filePath)
+	require.NoError(t, err, "NewGenericStorage should not return an error")
+
+	length, err := urlStorage.GetCodeLength(context.Background())
+	require.NoError(t, err)
+	assert.Zero(t, length, "no code length should be stored initially")
+	require.NoError(t, urlStorage.SetCodeLength(context.Background(), 8))
+	require.NoError(t, urlStorage.SetCodeLength(context.Background(), 7), "a smaller length should be ignored")
+	require.NoError(t, urlStorage.Close())
+
+	urlStorage, err = NewGenericStorage(filePath)
+	require.NoError(t, err, "NewGenericStorage should reopen the storage")
+	defer urlStorage.Close()
+	length, err = urlStorage.GetCodeLength(context.Background())
+	require.NoError(t, err)
+	assert.Equal(t, 8, length)
+}
+
+func TestURLsSurviveRestart(t *testing.T) {
+	filePath := filepath.Join(t.TempDir(), "storage.json")
+	urlStorage, err := NewGenericStorage(filePath)
+	require.NoError(t, err, "NewGenericStorage should not return an error")
+
+	expiresAt := time.Date(2100, 1, 1, 0, 0, 0, 0, time.UTC)
+	require.NoError(t, urlStorage.Save(context.Background(), entity.URL{ShortURL: "short1", FullURL: "full1", UserID: "user", ExpiresAt: expiresAt}))
+	require.NoError(t, urlStorage.Save(context.Background(), entity.URL{ShortURL: "short2", FullURL: "full2", UserID: "user"}))
+	require.NoError(t, urlStorage.DeleteURLs(context.Background(), []entity.URL{{ShortURL: "short2", UserID: "user"}}))
+	require.NoError(t, urlStorage.Save(context.Background(), entity.URL{ShortURL: "short3", FullURL: "full3", UserID: "another_user"}))
+	require.NoError(t, urlStorage.Close())
+
+	urlStorage, err = NewGenericStorage(filePath)
+	require.NoError(t, err, "NewGenericStorage should reopen the storage")
+	defer urlStorage.Close()
+
+	userURLs, err := urlStorage.GetURLsByUserID(context.Background(), "user")
+	require.NoError(t, err)
+	assert.Equal(t, []entity.URL{{ShortURL: "short1", FullURL: "full1", UserID: "user", ExpiresAt: expiresAt}}, userURLs)
+	_, err = urlStorage.GetFullURL(context.Background(), "short2")
+	assert.ErrorIs(t, err, usecases.ErrURLDeleted, "deletion should survive a restart")
+	assert.ErrorIs(t, urlStorage.Save(context.Background(), entity.URL{ShortURL: "short4", FullURL: "full3", UserID: "another_user"}),
+		usecases.ErrURLConflict,
+		"the full URL index should be rebuilt")
+}
+
+func TestUpgradeVersion1File(t *testing.T) {
+	filePath := filepath.Join(t.TempDir(), "storage.json")
+	legacy := `{"uuid":1,"short_url":"short1","original_url":"full1"}
+{"uuid":2,"short_url":"short2","original_url":"full2","user_id":"user"}
+`
+	require.NoError(t, os.WriteFile(filePath, []byte(legacy), 0644))
+
+	urlStorage, err := NewGenericStorage(filePath)
+	require.NoError(t, err, "NewGenericStorage should load a version 1 file")
+	require.NoError(t, urlStorage.Save(context.Background(), entity.URL{ShortURL: "short3", FullURL: "full3"}))
+	require.NoError(t, urlStorage.Close())
+
+	data, err := os.ReadFile(filePath)
+	require.NoError(t, err)
+	lines := strings.Split(strings.TrimSpace(string(data)), "\n")
+	require.Len(t, lines, 4)
+	assert.JSONEq(t, `{"format":"shortener-urls","version":7}`, lines[0], "the file should be upgraded in place")
+	assert.JSONEq(t, `{"uuid":3,"short_url":"short3","original_url":"full3"}`, lines[3], "record IDs should continue")
+
+	urlStorage, err = NewGenericStorage(filePath)
+	require.NoError(t, err, "NewGenericStorage should reopen the upgraded file")
+	defer urlStorage.Close()
+	for shortURL, want := range map[string]string{"short1": "full1", "short2": "full2", "short3": "full3"} {
+		fullURL, err := urlStorage.GetFullURL(context.Background(), shortURL)
+		require.NoError(t, err)
+		assert.Equal(t, want, fullURL)
+	}
+}
+
+func TestCorruptLinesAreQuarantined(t *testing.T) {
+	filePath := filepath.Join(t.TempDir(), "storage.json")
+	content := `{"format":"shortener-urls","version":2}
+{"uuid":1,"short_url":"short1","original_url":"full1"}
+not json
+{"uuid":2,"short_url":"","original_url":"full2"}
+{"uuid":3,"short_url":"short3","original_url":"full3"}
+{"uuid":4,"short_url":"torn`
+	require.NoError(t, os.WriteFile(filePath, []byte(content), 0644))
+
+	urlStorage, err := NewGenericStorage(filePath)
+	require.NoError(t, err, "NewGenericStorage should start despite corrupt lines")
+	require.NoError(t, urlStorage.Save(context.Background(), entity.URL{ShortURL: "short4", FullURL: "full4"}))
+	require.NoError(t, urlStorage.Close())
+
+	quarantined, err := os.ReadFile(filePath + quarantineFileSuffix)
+	require.NoError(t, err, "corrupt lines should be moved to the quarantine file")
+	assert.Equal(t, "not json\n"+`{"uuid":2,"short_url":"","original_url":"full2"}`+"\n"+`{"uuid":4,"short_url":"torn`+"\n", string(quarantined))
+
+	urlStorage, err = NewGenericStorage(filePath)
+	require.NoError(t, err, "NewGenericStorage should reopen the cleaned file")
+	defer urlStorage.Close()
+	for _, shortURL := range []string{"short1", "short3", "short4"} {
+		_, err := urlStorage.GetFullURL(context.Background(), shortURL)
+		assert.NoError(t, err, shortURL)
+	}
+	quarantinedAgain, err := os.ReadFile(filePath + quarantineFileSuffix)
+	require.NoError(t, err)
+	assert.Equal(t, quarantined, quarantinedAgain, "corrupt lines should be removed from the storage file")
+}
+
+func TestUnsupportedFileVersion(t *testing.T) {
+	filePath := filepath.Join(t.TempDir(), "storage.json")
+	require.NoError(t, os.WriteFile(filePath, []byte(`{"format":"shortener-urls","version":99}`+"\n"), 0644))
+
+	_, err := NewGenericStorage(filePath)
+	assert.ErrorIs(t, err, ErrUnsupportedFileVersion)
+}
+
+func countLines(t *testing.T, path string) int {
+	t.Helper()
+	data, err := os.ReadFile(path)
+	require.NoError(t, err)
+	return strings.Count(string(data), "\n")
+}
+
+func TestCompact(t *testing.T) {
+	filePath := filepath.Join(t.TempDir(), "storage.json")
+	urlStorage, err := NewGenericStorage(filePath)
+	require.NoError(t, err, "NewGenericStorage should not return an error")
+
+	require.NoError(t, urlStorage.Save(context.Background(), entity.URL{ShortURL: "short1", FullURL: "full1", UserID: "user"}))
+	require.NoError(t, urlStorage.Save(context.Background(), entity.URL{ShortURL: "short2", FullURL: "full2", UserID: "user"}))
+	require.NoError(t, urlStorage.Save(context.Background(), entity.URL{ShortURL: "limited", FullURL: "full3", MaxClicks: 5}))
+	require.NoError(t, urlStorage.DeleteURLs(context.Background(), []entity.URL{{ShortURL: "short2", UserID: "user"}}))
+	_, err = urlStorage.ConsumeClick(context.Background(), "limited")
+	require.NoError(t, err)
+	assert.Equal(t, 6, countLines(t, filePath), "header and five records should be written before compaction")
+
+	require.NoError(t, urlStorage.Compact(), "Compact should not return an error")
+	assert.Equal(t, 4, countLines(t, filePath), "only the header and the latest records should be kept")
+	require.NoError(t, urlStorage.Save(context.Background(), entity.URL{ShortURL: "short4", FullURL: "full4"}))
+	require.NoError(t, urlStorage.Close())
+
+	urlStorage, err = NewGenericStorage(filePath)
+	require.NoError(t, err, "NewGenericStorage should reopen the compacted file")
+	defer urlStorage.Close()
+	fullURL, err := urlStorage.GetFullURL(context.Background(), "short1")
+	require.NoError(t, err)
+	assert.Equal(t, "full1", fullURL)
+	_, err = urlStorage.GetFullURL(context.Background(), "short2")
+	assert.ErrorIs(t, err, usecases.ErrURLDeleted, "deleted URLs should survive compaction")
+	url, err := urlStorage.GetURL(context.Background(), "limited")
+	require.NoError(t, err)
+	assert.Equal(t, int64(1), url.Redirects, "the latest record should survive compaction")
+	_, err = urlStorage.GetFullURL(context.Background(), "short4")
+	assert.NoError(t, err, "writes after compaction should be appended to the new file")
+}
+
+func TestCompactKeepsRevisions(t *testing.T) {
+	filePath := filepath.Join(t.TempDir(), "storage.json")
+	urlStorage, err := NewGenericStorage(filePath)
+	require.NoError(t, err, "NewGenericStorage should not return an error")
+
+	require.NoError(t, urlStorage.Save(context.Background(), entity.URL{ShortURL: "short1", FullURL: "full1", UserID: "user"}))
+	for _, fullURL := range []string{"full1_v2", "full1_v3"} {
+		require.NoError(t, urlStorage.UpdateFullURL(context.Background(), entity.URL{ShortURL: "short1", FullURL: fullURL, UserID: "user"}))
+	}
+	require.NoError(t, urlStorage.Save(context.Background(), entity.URL{ShortURL: "short2", FullURL: "full2", UserID: "user"}))
+	require.NoError(t, urlStorage.Compact(), "Compact should not return an error")
+	assert.Equal(t, 4, countLines(t, filePath), "an edited URL should keep a record per revision")
+	require.NoError(t, urlStorage.Close())
+
+	urlStorage, err = NewGenericStorage(filePath)
+	require.NoError(t, err, "NewGenericStorage should reopen the compacted file")
+	defer urlStorage.Close()
+	fullURL, err := urlStorage.GetFullURL(context.Background(), "short1")
+	require.NoError(t, err)
+	assert.Equal(t, "full1_v3", fullURL)
+	revisions, err := urlStorage.GetURLRevisions(context.Background(), "short1")
+	require.NoError(t, err)
+	require.Len(t, revisions, 2, "revisions should survive compaction")
+	assert.Equal(t, "full1", revisions[0].PreviousFullURL)
+	assert.Equal(t, "full1_v2", revisions[1].PreviousFullURL)
+	assert.NoError(t, urlStorage.Save(context.Background(), entity.URL{ShortURL: "short3", FullURL: "full1"}),
+		"the original full URL of an edited URL should be free after compaction")
+}
+
+func TestCompactKeepsCreationOrder(t *testing.T) {
+	filePath := filepath.Join(t.TempDir(), "storage.json")
+	urlStorage, err := NewGenericStorage(filePath)
+	require.NoError(t, err, "NewGenericStorage should not return an error")
+
+	for i, shortURL := range []string{"zz", "aa", "mm"} {
+		require.NoError(t, urlStorage.Save(context.Background(), entity.URL{ShortURL: shortURL, FullURL: fmt.Sprintf("full%d", i), UserID: "user"}))
+	}
+	require.NoError(t, urlStorage.UpdateFullURL(context.Background(), entity.URL{ShortURL: "zz", FullURL: "full0_v2", UserID: "user"}))
+	require.NoError(t, urlStorage.Compact(), "Compact should not return an error")
+	require.NoError(t, urlStorage.Close())
+
+	urlStorage, err = NewGenericStorage(filePath)
+	require.NoError(t, err, "NewGenericStorage should reopen the compacted file")
+	defer urlStorage.Close()
+	assert.Equal(t, []string{"zz", "aa", "mm"}, userShortURLs(t, urlStorage, "user"),
+		"the URLs should be listed in the order they were created in after compaction")
+}
+
+func TestCompactionTriggeredBySizeRatio(t *testing.T) {
+	filePath := filepath.Join(t.TempDir(), "storage.json")
+	urlStorage, err := NewGenericStorageWithOptions(filePath, GenericStorageOptions{
+		SyncPolicy:      SyncNever,
+		CompactionRatio: 2,
+	})
+	require.NoError(t, err, "NewGenericStorageWithOptions should not return an error")
+	defer urlStorage.Close()
+
+	const live = minCompactionRecords / 2
+	urls := make([]entity.URL, 0, live)
+	for i := range live {
+		urls = append(urls, entity.URL{ShortURL: fmt.Sprintf("short%d", i), FullURL: fmt.Sprintf("full%d", i), UserID: "user"})
+	}
+	_, err = urlStorage.SaveBatch(context.Background(), urls)
+	require.NoError(t, err)
+	require.NoError(t, urlStorage.DeleteURLs(context.Background(), urls))
+
+	assert.Eventually(t, func() bool {
+		return countLines(t, filePath) == live+1
+	}, time.Second, 10*time.Millisecond, "the file should be compacted once it holds twice as many records as live URLs")
+}
+
+func TestSyncPolicies(t *testing.T) {
+	for _, policy := range []SyncPolicy{SyncAlways, SyncInterval, SyncNever} {
+		t.Run(string(policy), func(t *testing.T) {
+			filePath := filepath.Join(t.TempDir(), "storage.json")
+			urlStorage, err := NewGenericStorageWithOptions(filePath, GenericStorageOptions{
+				SyncPolicy:   policy,
+				SyncInterval: 10 * time.Millisecond,
+			})
+			require.NoError(t, err, "NewGenericStorageWithOptions should not return an error")
+			require.NoError(t, urlStorage.Save(context.Background(), entity.URL{ShortURL: "short", FullURL: "full"}))
+			require.NoError(t, urlStorage.SaveClicks(context.Background(), []entity.Click{{ShortURL: "short", ClickedAt: time.Now()}}))
+			require.NoError(t, urlStorage.Close())
+			assert.Equal(t, 2, countLines(t, filePath))
+		})
+	}
+
+	_, err := NewGenericStorageWithOptions("", GenericStorageOptions{SyncPolicy: "sometimes"})
+	assert.ErrorIs(t, err, ErrUnknownSyncPolicy)
+}
@@ -0,0 +1,55 @@
+package repository
+
+import (
+	"context"
+	"time"
+
+	"github.com/radiophysiker/shortener_link/internal/entity"
+	"github.com/radiophysiker/shortener_link/internal/metrics"
+	"github.com/radiophysiker/shortener_link/internal/usecases"
+)
+
+// InstrumentedStorage records the duration of storage operations on the hot paths.
+type InstrumentedStorage struct {
+	Storage
+}
+
+func NewInstrumentedStorage(storage Storage) *InstrumentedStorage {
+	return &InstrumentedStorage{Storage: storage}
+}
+
+func (s *InstrumentedStorage) Save(ctx context.Context, url entity.URL) error {
+	start := time.Now()
+	err := s.Storage.Save(ctx, url)
+	observeStorageOperation("save", start, err)
+	return err
+}
+
+func (s *InstrumentedStorage) SaveBatch(ctx context.Context, urls []entity.URL) ([]usecases.SaveResult, error) {
+	start := time.Now()
+	results, err := s.Storage.SaveBatch(ctx, urls)
+	observeStorageOperation("save_batch", start, err)
+	return results, err
+}
+
+func (s *InstrumentedStorage) ConsumeClick(ctx context.Context, shortURL ShortURL) (int64, error) {
+	start := time.Now()
+	left, err := s.Storage.ConsumeClick(ctx, shortURL)
+	observeStorageOperation("consume_click", start, err)
+	return left, err
+}
+
+func (s *InstrumentedStorage) ResolveURL(ctx context.Context, shortURL ShortURL) (entity.URL, error) {
+	start := time.Now()
+	url, err := s.Storage.ResolveURL(ctx, shortURL)
+	observeStorageOperation("resolve_url", start, err)
+	return url, err
+}
+
+func observeStorageOperation(operation string, start time.Time, err error) {
+	result := "ok"
+	if err != nil {
+		result = "error"
+	}
+	metrics.StorageOperationDuration.WithLabelValues(operation, result).Observe(time.Since(start).Seconds())
+}
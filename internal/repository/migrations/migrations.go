@@ -0,0 +1,93 @@
+// Package migrations applies the versioned PostgreSQL schema migrations embedded into the binary.
+package migrations
+
+import (
+	"cmp"
+	"embed"
+	"errors"
+	"fmt"
+	"io/fs"
+	"path"
+	"regexp"
+	"slices"
+	"strconv"
+)
+
+//go:embed sql/*.sql
+var files embed.FS
+
+var (
+	ErrInvalidMigration = errors.New("invalid migration")
+	ErrIrreversible     = errors.New("migration cannot be reverted")
+	ErrUnknownMigration = errors.New("unknown migration")
+)
+
+// fileNamePattern matches migration file names like 0001_create_shortened_urls.up.sql.
+var fileNamePattern = regexp.MustCompile(`^(\d+)_(\w+)\.(up|down)\.sql$`)
+
+type Migration struct {
+	Version int64
+	Name    string
+	// Up is the SQL applying the migration.
+	Up string
+	// Down is the SQL reverting the migration. The migration is irreversible if it is empty.
+	Down string
+}
+
+// Load returns the embedded migrations ordered by version.
+func Load() ([]Migration, error) {
+	return parse(files, "sql")
+}
+
+// parse reads the migrations from the directory of fsys.
+// Every migration must have an up file, a down file is optional.
+func parse(fsys fs.FS, dir string) ([]Migration, error) {
+	entries, err := fs.ReadDir(fsys, dir)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read migrations: %w", err)
+	}
+	byVersion := make(map[int64]*Migration)
+	for _, entry := range entries {
+		if entry.IsDir() {
+			continue
+		}
+		match := fileNamePattern.FindStringSubmatch(entry.Name())
+		if match == nil {
+			return nil, fmt.Errorf("%w: unexpected file name %s", ErrInvalidMigration, entry.Name())
+		}
+		version, err := strconv.ParseInt(match[1], 10, 64)
+		if err != nil || version <= 0 {
+			return nil, fmt.Errorf("%w: bad version in %s", ErrInvalidMigration, entry.Name())
+		}
+		data, err := fs.ReadFile(fsys, path.Join(dir, entry.Name()))
+		if err != nil {
+			return nil, fmt.Errorf("failed to read migration %s: %w", entry.Name(), err)
+		}
+
+		migration, ok := byVersion[version]
+		if !ok {
+			migration = &Migration{Version: version, Name: match[2]}
+			byVersion[version] = migration
+		}
+		if migration.Name != match[2] {
+			return nil, fmt.Errorf("%w: version %d is used by %s and %s", ErrInvalidMigration, version, migration.Name, match[2])
+		}
+		if match[3] == "up" {
+			migration.Up = string(data)
+		} else {
+			migration.Down = string(data)
+		}
+	}
+
+	migrations := make([]Migration, 0, len(byVersion))
+	for _, migration := range byVersion {
+		if migration.Up == "" {
+			return nil, fmt.Errorf("%w: %d_%s has no up file", ErrInvalidMigration, migration.Version, migration.Name)
+		}
+		migrations = append(migrations, *migration)
+	}
+	slices.SortFunc(migrations, func(a, b Migration) int {
+		return cmp.Compare(a.Version, b.Version)
+	})
+	return migrations, nil
+}
@@ -0,0 +1,69 @@
+package migrations
+
+import (
+	"testing"
+	"testing/fstest"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestLoad(t *testing.T) {
+	migrations, err := Load()
+	require.NoError(t, err, "embedded migrations should be valid")
+	require.NotEmpty(t, migrations)
+	for i, migration := range migrations {
+		assert.Equal(t, int64(i+1), migration.Version, "versions should be contiguous")
+		assert.NotEmpty(t, migration.Up, migration.Name)
+		assert.NotEmpty(t, migration.Down, migration.Name)
+	}
+}
+
+func TestParse(t *testing.T) {
+	fsys := fstest.MapFS{
+		"sql/0002_second.up.sql":  {Data: []byte("CREATE TABLE b ();")},
+		"sql/0010_tenth.up.sql":   {Data: []byte("CREATE TABLE c ();")},
+		"sql/0001_first.up.sql":   {Data: []byte("CREATE TABLE a ();")},
+		"sql/0001_first.down.sql": {Data: []byte("DROP TABLE a;")},
+	}
+	migrations, err := parse(fsys, "sql")
+	require.NoError(t, err)
+	assert.Equal(t, []Migration{
+		{Version: 1, Name: "first", Up: "CREATE TABLE a ();", Down: "DROP TABLE a;"},
+		{Version: 2, Name: "second", Up: "CREATE TABLE b ();"},
+		{Version: 10, Name: "tenth", Up: "CREATE TABLE c ();"},
+	}, migrations)
+}
+
+func TestParseInvalid(t *testing.T) {
+	tests := []struct {
+		name string
+		fsys fstest.MapFS
+	}{
+		{
+			name: "unexpected file name",
+			fsys: fstest.MapFS{"sql/first.sql": {Data: []byte("SELECT 1;")}},
+		},
+		{
+			name: "zero version",
+			fsys: fstest.MapFS{"sql/0000_zero.up.sql": {Data: []byte("SELECT 1;")}},
+		},
+		{
+			name: "duplicate version",
+			fsys: fstest.MapFS{
+				"sql/0001_first.up.sql": {Data: []byte("SELECT 1;")},
+				"sql/0001_other.up.sql": {Data: []byte("SELECT 2;")},
+			},
+		},
+		{
+			name: "missing up file",
+			fsys: fstest.MapFS{"sql/0001_first.down.sql": {Data: []byte("SELECT 1;")}},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := parse(tt.fsys, "sql")
+			assert.ErrorIs(t, err, ErrInvalidMigration)
+		})
+	}
+}
@@ -0,0 +1,174 @@
+package migrations
+
+import (
+	"context"
+	"fmt"
+	"slices"
+
+	"github.com/jackc/pgx/v4"
+	"github.com/jackc/pgx/v4/pgxpool"
+	"go.uber.org/zap"
+)
+
+// lockID is the key of the PostgreSQL advisory lock held while migrating,
+// so that replicas starting at once apply every migration exactly once.
+const lockID int64 = 7_344_215_901
+
+const createMigrationsTable = `
+CREATE TABLE IF NOT EXISTS schema_migrations (
+	version BIGINT PRIMARY KEY,
+	name TEXT NOT NULL,
+	applied_at TIMESTAMP WITH TIME ZONE NOT NULL DEFAULT CURRENT_TIMESTAMP
+);
+`
+
+type Migrator struct {
+	pool       *pgxpool.Pool
+	migrations []Migration
+}
+
+// NewMigrator returns a Migrator applying the embedded migrations.
+func NewMigrator(pool *pgxpool.Pool) (*Migrator, error) {
+	migrations, err := Load()
+	if err != nil {
+		return nil, err
+	}
+	return &Migrator{
+		pool:       pool,
+		migrations: migrations,
+	}, nil
+}
+
+// Up applies all pending migrations and returns how many were applied.
+func (m *Migrator) Up(ctx context.Context) (int, error) {
+	var count int
+	err := m.withLock(ctx, func(conn *pgxpool.Conn, applied []int64) error {
+		for _, migration := range m.migrations {
+			if slices.Contains(applied, migration.Version) {
+				continue
+			}
+			err := m.apply(ctx, conn, migration.Up, func(tx pgx.Tx) error {
+				_, err := tx.Exec(ctx, "INSERT INTO schema_migrations (version, name) VALUES ($1, $2);",
+					migration.Version, migration.Name)
+				return err
+			})
+			if err != nil {
+				return fmt.Errorf("failed to apply migration %d_%s: %w", migration.Version, migration.Name, err)
+			}
+			zap.L().Info("applied migration", zap.Int64("version", migration.Version), zap.String("name", migration.Name))
+			count++
+		}
+		return nil
+	})
+	return count, err
+}
+
+// Down reverts up to steps most recently applied migrations and returns how many were reverted.
+func (m *Migrator) Down(ctx context.Context, steps int) (int, error) {
+	var count int
+	err := m.withLock(ctx, func(conn *pgxpool.Conn, applied []int64) error {
+		slices.Reverse(applied)
+		for _, version := range applied[:min(steps, len(applied))] {
+			i := slices.IndexFunc(m.migrations, func(migration Migration) bool {
+				return migration.Version == version
+			})
+			if i < 0 {
+				return fmt.Errorf("%w: version %d", ErrUnknownMigration, version)
+			}
+			migration := m.migrations[i]
+			if migration.Down == "" {
+				return fmt.Errorf("%w: %d_%s", ErrIrreversible, migration.Version, migration.Name)
+			}
+			err := m.apply(ctx, conn, migration.Down, func(tx pgx.Tx) error {
+				_, err := tx.Exec(ctx, "DELETE FROM schema_migrations WHERE version = $1;", migration.Version)
+				return err
+			})
+			if err != nil {
+				return fmt.Errorf("failed to revert migration %d_%s: %w", migration.Version, migration.Name, err)
+			}
+			zap.L().Info("reverted migration", zap.Int64("version", migration.Version), zap.String("name", migration.Name))
+			count++
+		}
+		return nil
+	})
+	return count, err
+}
+
+// Version returns the version of the most recently applied migration, or 0 if none is applied.
+// It only reads the schema: it neither takes the migration lock nor creates the schema_migrations table.
+func (m *Migrator) Version(ctx context.Context) (int64, error) {
+	var exists bool
+	err := m.pool.QueryRow(ctx, "SELECT to_regclass('schema_migrations') IS NOT NULL;").Scan(&exists)
+	if err != nil {
+		return 0, fmt.Errorf("failed to check schema_migrations table: %w", err)
+	}
+	if !exists {
+		return 0, nil
+	}
+	var version int64
+	err = m.pool.QueryRow(ctx, "SELECT COALESCE(MAX(version), 0) FROM schema_migrations;").Scan(&version)
+	if err != nil {
+		return 0, fmt.Errorf("failed to get applied migrations: %w", err)
+	}
+	return version, nil
+}
+
+// withLock runs fn holding the migration advisory lock and passes it the applied versions in ascending order.
+func (m *Migrator) withLock(ctx context.Context, fn func(conn *pgxpool.Conn, applied []int64) error) error {
+	conn, err := m.pool.Acquire(ctx)
+	if err != nil {
+		return fmt.Errorf("failed to acquire connection: %w", err)
+	}
+	defer conn.Release()
+
+	if _, err := conn.Exec(ctx, "SELECT pg_advisory_lock($1);", lockID); err != nil {
+		return fmt.Errorf("failed to acquire migration lock: %w", err)
+	}
+	defer func() {
+		// The lock is held by the session, so it has to be released even if ctx is done.
+		if _, err := conn.Exec(context.Background(), "SELECT pg_advisory_unlock($1);", lockID); err != nil {
+			zap.L().Error("failed to release migration lock", zap.Error(err))
+		}
+	}()
+
+	if _, err := conn.Exec(ctx, createMigrationsTable); err != nil {
+		return fmt.Errorf("failed to create schema_migrations table: %w", err)
+	}
+	rows, err := conn.Query(ctx, "SELECT version FROM schema_migrations ORDER BY version;")
+	if err != nil {
+		return fmt.Errorf("failed to get applied migrations: %w", err)
+	}
+	var applied []int64
+	for rows.Next() {
+		var version int64
+		if err := rows.Scan(&version); err != nil {
+			rows.Close()
+			return fmt.Errorf("failed to scan applied migration: %w", err)
+		}
+		applied = append(applied, version)
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return fmt.Errorf("failed to get applied migrations: %w", err)
+	}
+	return fn(conn, applied)
+}
+
+// apply runs the migration SQL and records the result in one transaction.
+func (m *Migrator) apply(ctx context.Context, conn *pgxpool.Conn, sql string, record func(tx pgx.Tx) error) error {
+	tx, err := conn.Begin(ctx)
+	if err != nil {
+		return fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer func() {
+		// Rollback is a no-op after a successful commit.
+		_ = tx.Rollback(context.Background())
+	}()
+	if _, err := tx.Exec(ctx, sql); err != nil {
+		return err
+	}
+	if err := record(tx); err != nil {
+		return err
+	}
+	return tx.Commit(ctx)
+}
@@ -0,0 +1,8 @@
+CREATE TABLE IF NOT EXISTS shortened_urls (
+	id SERIAL PRIMARY KEY,
+	short_url VARCHAR(10) NOT NULL UNIQUE,
+	full_url TEXT NOT NULL UNIQUE,
+	created_at TIMESTAMP WITH TIME ZONE DEFAULT CURRENT_TIMESTAMP
+);
+CREATE INDEX IF NOT EXISTS idx_short_url ON shortened_urls(short_url);
+CREATE UNIQUE INDEX IF NOT EXISTS idx_full_url ON shortened_urls(full_url);
@@ -0,0 +1 @@
+ALTER TABLE shortened_urls ADD COLUMN IF NOT EXISTS user_id TEXT;
@@ -0,0 +1,2 @@
+-- Fails if there are short URLs longer than 10 characters.
+ALTER TABLE shortened_urls ALTER COLUMN short_url TYPE VARCHAR(10);
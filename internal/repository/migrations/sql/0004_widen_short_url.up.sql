@@ -0,0 +1,2 @@
+-- Custom aliases and grown generated codes do not fit into VARCHAR(10).
+ALTER TABLE shortened_urls ALTER COLUMN short_url TYPE TEXT;
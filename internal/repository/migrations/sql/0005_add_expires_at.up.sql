@@ -0,0 +1,2 @@
+ALTER TABLE shortened_urls ADD COLUMN IF NOT EXISTS expires_at TIMESTAMP WITH TIME ZONE;
+CREATE INDEX IF NOT EXISTS idx_expires_at ON shortened_urls(expires_at) WHERE expires_at IS NOT NULL;
@@ -0,0 +1,9 @@
+CREATE TABLE IF NOT EXISTS clicks (
+	id BIGSERIAL PRIMARY KEY,
+	short_url TEXT NOT NULL,
+	clicked_at TIMESTAMP WITH TIME ZONE NOT NULL,
+	referrer TEXT,
+	user_agent TEXT,
+	ip_hash TEXT
+);
+CREATE INDEX IF NOT EXISTS idx_clicks_short_url ON clicks(short_url, clicked_at);
@@ -0,0 +1,6 @@
+-- Fails if edited URLs share full URLs, those have to be resolved by hand first.
+DROP TABLE IF EXISTS url_revisions;
+DROP INDEX IF EXISTS idx_full_url;
+CREATE UNIQUE INDEX IF NOT EXISTS idx_full_url ON shortened_urls(full_url);
+ALTER TABLE shortened_urls ADD CONSTRAINT shortened_urls_full_url_key UNIQUE (full_url);
+ALTER TABLE shortened_urls DROP COLUMN IF EXISTS is_edited;
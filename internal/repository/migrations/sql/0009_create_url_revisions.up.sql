@@ -0,0 +1,14 @@
+-- Edited URLs no longer take part in full URL conflict checks,
+-- so only the URLs that have never been edited keep their full URLs unique.
+ALTER TABLE shortened_urls ADD COLUMN IF NOT EXISTS is_edited BOOLEAN NOT NULL DEFAULT FALSE;
+ALTER TABLE shortened_urls DROP CONSTRAINT IF EXISTS shortened_urls_full_url_key;
+DROP INDEX IF EXISTS idx_full_url;
+CREATE UNIQUE INDEX IF NOT EXISTS idx_full_url ON shortened_urls(full_url) WHERE NOT is_edited;
+CREATE TABLE IF NOT EXISTS url_revisions (
+	id BIGSERIAL PRIMARY KEY,
+	short_url TEXT NOT NULL,
+	previous_url TEXT NOT NULL,
+	full_url TEXT NOT NULL,
+	changed_at TIMESTAMP WITH TIME ZONE NOT NULL DEFAULT CURRENT_TIMESTAMP
+);
+CREATE INDEX IF NOT EXISTS idx_url_revisions_short_url ON url_revisions(short_url, id);
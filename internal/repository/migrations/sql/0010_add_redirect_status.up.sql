@@ -0,0 +1,2 @@
+-- Zero means the redirect status configured for the server.
+ALTER TABLE shortened_urls ADD COLUMN IF NOT EXISTS redirect_status SMALLINT NOT NULL DEFAULT 0;
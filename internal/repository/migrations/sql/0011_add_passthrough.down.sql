@@ -0,0 +1 @@
+ALTER TABLE shortened_urls DROP COLUMN IF EXISTS passthrough;
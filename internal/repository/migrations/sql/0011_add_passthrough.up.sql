@@ -0,0 +1,2 @@
+-- Empty means the redirect does not carry anything over from the request.
+ALTER TABLE shortened_urls ADD COLUMN IF NOT EXISTS passthrough TEXT NOT NULL DEFAULT '';
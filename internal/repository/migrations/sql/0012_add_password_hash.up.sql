@@ -0,0 +1,2 @@
+-- Empty means the short URL is not protected with a password.
+ALTER TABLE shortened_urls ADD COLUMN IF NOT EXISTS password_hash TEXT NOT NULL DEFAULT '';
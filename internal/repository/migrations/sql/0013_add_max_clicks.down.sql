@@ -0,0 +1,2 @@
+ALTER TABLE shortened_urls DROP COLUMN IF EXISTS redirects;
+ALTER TABLE shortened_urls DROP COLUMN IF EXISTS max_clicks;
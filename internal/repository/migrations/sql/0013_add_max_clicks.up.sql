@@ -0,0 +1,3 @@
+-- Zero max_clicks means no click limit. redirects is only counted for URLs with a limit.
+ALTER TABLE shortened_urls ADD COLUMN IF NOT EXISTS max_clicks BIGINT NOT NULL DEFAULT 0;
+ALTER TABLE shortened_urls ADD COLUMN IF NOT EXISTS redirects BIGINT NOT NULL DEFAULT 0;
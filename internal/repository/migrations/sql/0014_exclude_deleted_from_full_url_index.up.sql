@@ -0,0 +1,3 @@
+-- Deleted URLs no longer take part in full URL conflict checks, so that a deleted full URL can be shortened again.
+DROP INDEX IF EXISTS idx_full_url;
+CREATE UNIQUE INDEX IF NOT EXISTS idx_full_url ON shortened_urls(full_url) WHERE NOT is_edited AND NOT is_deleted;
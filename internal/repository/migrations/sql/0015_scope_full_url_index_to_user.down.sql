@@ -0,0 +1,3 @@
+-- Fails if several users share full URLs, those have to be resolved by hand first.
+DROP INDEX IF EXISTS idx_full_url;
+CREATE UNIQUE INDEX IF NOT EXISTS idx_full_url ON shortened_urls(full_url) WHERE NOT is_edited AND NOT is_deleted;
@@ -0,0 +1,3 @@
+-- Full URLs are only unique per user, so that a user is never handed a URL another user may edit or delete.
+DROP INDEX IF EXISTS idx_full_url;
+CREATE UNIQUE INDEX IF NOT EXISTS idx_full_url ON shortened_urls(COALESCE(user_id, ''), full_url) WHERE NOT is_edited AND NOT is_deleted;
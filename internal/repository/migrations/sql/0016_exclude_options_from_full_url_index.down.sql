@@ -0,0 +1,3 @@
+-- Fails if URLs with options share full URLs with other URLs of the same user, those have to be resolved by hand first.
+DROP INDEX IF EXISTS idx_full_url;
+CREATE UNIQUE INDEX IF NOT EXISTS idx_full_url ON shortened_urls(COALESCE(user_id, ''), full_url) WHERE NOT is_edited AND NOT is_deleted;
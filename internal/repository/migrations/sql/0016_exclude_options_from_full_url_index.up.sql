@@ -0,0 +1,7 @@
+-- URLs with an expiry, a redirect status, a passthrough mode, a password or a click limit no longer take part
+-- in full URL conflict checks: they are not interchangeable with other URLs of the same full URL.
+-- Expired URLs always have an expiry, so they no longer hold their full URLs either.
+DROP INDEX IF EXISTS idx_full_url;
+CREATE UNIQUE INDEX IF NOT EXISTS idx_full_url ON shortened_urls(COALESCE(user_id, ''), full_url)
+	WHERE NOT is_edited AND NOT is_deleted
+		AND expires_at IS NULL AND redirect_status = 0 AND passthrough = '' AND password_hash = '' AND max_clicks = 0;
@@ -4,6 +4,7 @@ import (
 	"context"
 	"errors"
 	"fmt"
+	"strconv"
 	"time"
 
 	"github.com/jackc/pgconn"
@@ -13,9 +14,13 @@ import (
 	"go.uber.org/zap"
 
 	"github.com/radiophysiker/shortener_link/internal/entity"
+	"github.com/radiophysiker/shortener_link/internal/repository/migrations"
 	"github.com/radiophysiker/shortener_link/internal/usecases"
 )
 
+// shortURLConstraint is the name of the unique constraint on shortened_urls.short_url.
+const shortURLConstraint = "shortened_urls_short_url_key"
+
 type PostgresStorage struct {
 	pool *pgxpool.Pool
 }
@@ -34,26 +39,20 @@ func NewPostgresStorage(dsn string) (*PostgresStorage, error) {
 	ps := &PostgresStorage{
 		pool: pool,
 	}
-	if err := ps.createTable(ctx); err != nil {
+	if err := ps.migrate(ctx); err != nil {
 		pool.Close()
-		return nil, fmt.Errorf("failed to create table: %w", err)
+		return nil, fmt.Errorf("failed to migrate database: %w", err)
 	}
 	return ps, nil
 }
 
-func (p *PostgresStorage) createTable(ctx context.Context) error {
-	query := `
-	CREATE TABLE IF NOT EXISTS shortened_urls (
-		id SERIAL PRIMARY KEY,
-		short_url VARCHAR(10) NOT NULL UNIQUE,
-		full_url TEXT NOT NULL UNIQUE,
-		created_at TIMESTAMP WITH TIME ZONE DEFAULT CURRENT_TIMESTAMP
-	);
-	CREATE INDEX IF NOT EXISTS idx_short_url ON shortened_urls(short_url);
-	CREATE UNIQUE INDEX IF NOT EXISTS idx_full_url ON shortened_urls(full_url);
-	`
-
-	_, err := p.pool.Exec(ctx, query)
+// migrate brings the schema up to date with the embedded migrations.
+func (p *PostgresStorage) migrate(ctx context.Context) error {
+	migrator, err := migrations.NewMigrator(p.pool)
+	if err != nil {
+		return err
+	}
+	_, err = migrator.Up(ctx)
 	return err
 }
 
@@ -61,12 +60,62 @@ func (p *PostgresStorage) Ping(ctx context.Context) error {
 	return p.pool.Ping(ctx)
 }
 
+// NextSequenceValue returns the next value of the short URL sequence.
+func (p *PostgresStorage) NextSequenceValue(ctx context.Context) (int64, error) {
+	var value int64
+	err := p.pool.QueryRow(ctx, "SELECT nextval('short_url_seq');").Scan(&value)
+	if err != nil {
+		return 0, fmt.Errorf("failed to get next sequence value: %w", err)
+	}
+	return value, nil
+}
+
+// GetCodeLength returns the stored code length, or 0 if none is stored.
+func (p *PostgresStorage) GetCodeLength(ctx context.Context) (int, error) {
+	query := `
+	SELECT value::int
+	FROM settings
+	WHERE key = 'code_length';
+	`
+	var length int
+	err := p.pool.QueryRow(ctx, query).Scan(&length)
+	if err != nil {
+		if errors.Is(err, pgx.ErrNoRows) {
+			return 0, nil
+		}
+		return 0, fmt.Errorf("failed to get code length: %w", err)
+	}
+	return length, nil
+}
+
+// SetCodeLength stores the code length unless a greater one is already stored,
+// so that replicas growing the length concurrently never shrink it.
+func (p *PostgresStorage) SetCodeLength(ctx context.Context, length int) error {
+	query := `
+	INSERT INTO settings (key, value)
+	VALUES ('code_length', $1)
+	ON CONFLICT (key) DO UPDATE
+	SET value = EXCLUDED.value
+	WHERE settings.value::int < EXCLUDED.value::int;
+	`
+	_, err := p.pool.Exec(ctx, query, strconv.Itoa(length))
+	if err != nil {
+		return fmt.Errorf("failed to set code length: %w", err)
+	}
+	return nil
+}
+
+// Stat returns the connection pool statistics.
+func (p *PostgresStorage) Stat() *pgxpool.Stat {
+	return p.pool.Stat()
+}
+
 func (p *PostgresStorage) Close() error {
 	p.pool.Close()
 	return nil
 }
 
-func (p *PostgresStorage) isShortURLExists(url entity.URL) (bool, error) {
+func (p *PostgresStorage) isShortURLExists(ctx context.Context, shortURL ShortURL) (bool, error) {
 	const query = `
 	SELECT EXISTS (
 		SELECT 1
@@ -76,7 +125,7 @@ func (p *PostgresStorage) isShortURLExists(url entity.URL) (bool, error) {
 `
 
 	var exists bool
-	err := p.pool.QueryRow(context.Background(), query, url.ShortURL).Scan(&exists)
+	err := p.pool.QueryRow(ctx, query, shortURL).Scan(&exists)
 	if err != nil {
 		return false, err
 	}
@@ -89,27 +138,33 @@ func (p *PostgresStorage) Save(ctx context.Context, url entity.URL) error {
 		return usecases.ErrEmptyFullURL
 	}
 
-	// First try to get the existing short URL for this full URL
-	existingShortURL, err := p.GetShortURLByFullURL(ctx, fullURL)
-	if err == nil {
-		// If we found an existing short URL, return it with a conflict error
-		return fmt.Errorf("%w: %s", usecases.ErrURLConflict, existingShortURL)
+	// First try to get the existing short URL the user has for this full URL. URLs with options are never deduplicated.
+	if !url.HasOptions() {
+		existingShortURL, err := p.GetShortURLByFullURL(ctx, url.UserID, fullURL)
+		if err == nil {
+			// If we found an existing short URL, return it with a conflict error
+			return &usecases.ConflictError{ExistingShortURL: existingShortURL}
+		}
 	}
 
 	// If no existing URL found, proceed with saving
 	query := `
-	INSERT INTO shortened_urls (short_url, full_url)
-	VALUES ($1, $2);
+	INSERT INTO shortened_urls (short_url, full_url, user_id, expires_at, redirect_status, passthrough, password_hash, max_clicks)
+	VALUES ($1, $2, $3, $4, $5, $6, $7, $8);
 	`
-	_, err = p.pool.Exec(context.Background(), query, url.ShortURL, url.FullURL)
+	_, err := p.pool.Exec(context.Background(), query, url.ShortURL, url.FullURL, url.UserID,
+		nullTime(url.ExpiresAt), url.RedirectStatus, string(url.Passthrough), url.PasswordHash, url.MaxClicks)
 	if err != nil {
 		if pgErr, ok := err.(*pgconn.PgError); ok && pgErr.Code == pgerrcode.UniqueViolation {
+			if pgErr.ConstraintName == shortURLConstraint {
+				return fmt.Errorf("%w: %s", usecases.ErrURLGeneratedBefore, url.ShortURL)
+			}
 			// If we get a unique violation, try to get the existing short URL again
-			existingShortURL, err = p.GetShortURLByFullURL(ctx, fullURL)
+			existingShortURL, err := p.GetShortURLByFullURL(ctx, url.UserID, fullURL)
 			if err != nil {
 				return fmt.Errorf("failed to get existing short URL: %w", err)
 			}
-			return fmt.Errorf("%w: %s", usecases.ErrURLConflict, existingShortURL)
+			return &usecases.ConflictError{ExistingShortURL: existingShortURL}
 		}
 		return fmt.Errorf("failed to save URL: %w", err)
 	}
@@ -117,26 +172,118 @@ func (p *PostgresStorage) Save(ctx context.Context, url entity.URL) error {
 }
 
 func (p *PostgresStorage) GetFullURL(ctx context.Context, shortURL ShortURL) (FullURL, error) {
+	url, err := p.ResolveURL(ctx, shortURL)
+	return url.FullURL, err
+}
+
+func (p *PostgresStorage) ResolveURL(ctx context.Context, shortURL ShortURL) (entity.URL, error) {
+	url, err := p.GetURL(ctx, shortURL)
+	if err != nil {
+		return entity.URL{}, err
+	}
+	return resolveURL(url, time.Now())
+}
+
+// ConsumeClick counts the redirect with a conditional UPDATE, so that concurrent redirects
+// never take more clicks than the limit allows, even across replicas.
+func (p *PostgresStorage) ConsumeClick(ctx context.Context, shortURL ShortURL) (int64, error) {
+	if shortURL == "" {
+		return 0, usecases.ErrEmptyShortURL
+	}
+	query := `
+	UPDATE shortened_urls
+	SET redirects = redirects + 1
+	WHERE short_url = $1 AND max_clicks > 0 AND redirects < max_clicks
+		AND NOT is_deleted AND (expires_at IS NULL OR expires_at > now())
+	RETURNING max_clicks - redirects;
+	`
+	var left int64
+	err := p.pool.QueryRow(ctx, query, shortURL).Scan(&left)
+	if err == nil {
+		return left, nil
+	}
+	if !errors.Is(err, pgx.ErrNoRows) {
+		return 0, fmt.Errorf("failed to consume click of %s: %w", shortURL, err)
+	}
+	// Nothing has been counted: find out why.
+	url, err := p.ResolveURL(ctx, shortURL)
+	if err != nil {
+		return 0, err
+	}
+	if url.MaxClicks == 0 {
+		return 0, nil
+	}
+	// The URL has expired or been exhausted between the two queries.
+	return 0, fmt.Errorf("%w: %s", usecases.ErrURLExhausted, shortURL)
+}
+
+func (p *PostgresStorage) GetURL(ctx context.Context, shortURL ShortURL) (entity.URL, error) {
 	if shortURL == "" {
-		return "", usecases.ErrEmptyShortURL
+		return entity.URL{}, usecases.ErrEmptyShortURL
 	}
 	query := `
-	SELECT full_url
+	SELECT short_url, full_url, COALESCE(user_id, ''), is_deleted, expires_at, redirect_status, passthrough, password_hash,
+		max_clicks, redirects
 	FROM shortened_urls
 	WHERE short_url = $1;
 	`
-	var fullURL FullURL
-	err := p.pool.QueryRow(ctx, query, shortURL).Scan(&fullURL)
+	var (
+		url         entity.URL
+		expiresAt   *time.Time
+		passthrough string
+	)
+	err := p.pool.QueryRow(ctx, query, shortURL).Scan(&url.ShortURL, &url.FullURL, &url.UserID, &url.IsDeleted,
+		&expiresAt, &url.RedirectStatus, &passthrough, &url.PasswordHash, &url.MaxClicks, &url.Redirects)
 	if err != nil {
 		if errors.Is(err, pgx.ErrNoRows) {
-			return "", fmt.Errorf("%w: %s", usecases.ErrURLNotFound, shortURL)
+			return entity.URL{}, fmt.Errorf("%w: %s", usecases.ErrURLNotFound, shortURL)
 		}
-		return "", fmt.Errorf("couldn't get full URL for %s: %w", shortURL, err)
+		return entity.URL{}, fmt.Errorf("couldn't get URL %s: %w", shortURL, err)
+	}
+	if expiresAt != nil {
+		url.ExpiresAt = *expiresAt
+	}
+	url.Passthrough = entity.PassthroughMode(passthrough)
+	return url, nil
+}
+
+func (p *PostgresStorage) GetURLsByUserID(ctx context.Context, userID string) ([]entity.URL, error) {
+	if userID == "" {
+		return nil, usecases.ErrEmptyUserID
+	}
+	query := `
+	SELECT short_url, full_url, expires_at
+	FROM shortened_urls
+	WHERE user_id = $1 AND NOT is_deleted
+	ORDER BY id;
+	`
+	rows, err := p.pool.Query(ctx, query, userID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get URLs for user %s: %w", userID, err)
+	}
+	defer rows.Close()
+
+	var urls []entity.URL
+	for rows.Next() {
+		url := entity.URL{UserID: userID}
+		var expiresAt *time.Time
+		if err := rows.Scan(&url.ShortURL, &url.FullURL, &expiresAt); err != nil {
+			return nil, fmt.Errorf("failed to scan URL: %w", err)
+		}
+		if expiresAt != nil {
+			url.ExpiresAt = *expiresAt
+		}
+		urls = append(urls, url)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("failed to read URLs for user %s: %w", userID, err)
 	}
-	return fullURL, nil
+	return urls, nil
 }
 
-func (p *PostgresStorage) GetShortURLByFullURL(ctx context.Context, fullURL string) (string, error) {
+// GetShortURLByFullURL returns the short URL the user has shortened the full URL to without options.
+// URLs of other users are never returned, since the user could not edit or delete them.
+func (p *PostgresStorage) GetShortURLByFullURL(ctx context.Context, userID, fullURL string) (string, error) {
 	if fullURL == "" {
 		return "", usecases.ErrEmptyFullURL
 	}
@@ -144,11 +291,12 @@ func (p *PostgresStorage) GetShortURLByFullURL(ctx context.Context, fullURL stri
 	query := `
 	SELECT short_url
 	FROM shortened_urls
-	WHERE full_url = $1;
+	WHERE COALESCE(user_id, '') = $1 AND full_url = $2 AND NOT is_edited AND NOT is_deleted
+		AND expires_at IS NULL AND redirect_status = 0 AND passthrough = '' AND password_hash = '' AND max_clicks = 0;
 	`
 
 	var shortURL string
-	err := p.pool.QueryRow(ctx, query, fullURL).Scan(&shortURL)
+	err := p.pool.QueryRow(ctx, query, userID, fullURL).Scan(&shortURL)
 	if err != nil {
 		if errors.Is(err, pgx.ErrNoRows) {
 			return "", fmt.Errorf("%w for URL: %s", usecases.ErrURLNotFound, fullURL)
@@ -159,46 +307,349 @@ func (p *PostgresStorage) GetShortURLByFullURL(ctx context.Context, fullURL stri
 	return shortURL, nil
 }
 
-func (p *PostgresStorage) SaveBatch(ctx context.Context, urls []entity.URL) error {
+// SaveBatch inserts the URLs with a single multi-row INSERT that skips the URLs conflicting with stored ones.
+// The skipped URLs without options are then looked up by their users and full URLs: those found are reported
+// as existing, the rest had their short URLs taken.
+func (p *PostgresStorage) SaveBatch(ctx context.Context, urls []entity.URL) ([]usecases.SaveResult, error) {
+	if len(urls) == 0 {
+		return nil, usecases.ErrEmptyBatch
+	}
+	shortURLs := make([]string, 0, len(urls))
+	fullURLs := make([]string, 0, len(urls))
+	userIDs := make([]string, 0, len(urls))
+	expiresAt := make([]*time.Time, 0, len(urls))
+	redirectStatuses := make([]int32, 0, len(urls))
+	passthroughs := make([]string, 0, len(urls))
+	passwordHashes := make([]string, 0, len(urls))
+	maxClicks := make([]int64, 0, len(urls))
+	for _, url := range urls {
+		shortURLs = append(shortURLs, url.ShortURL)
+		fullURLs = append(fullURLs, url.FullURL)
+		userIDs = append(userIDs, url.UserID)
+		expiresAt = append(expiresAt, nullTime(url.ExpiresAt))
+		redirectStatuses = append(redirectStatuses, int32(url.RedirectStatus))
+		passthroughs = append(passthroughs, string(url.Passthrough))
+		passwordHashes = append(passwordHashes, url.PasswordHash)
+		maxClicks = append(maxClicks, url.MaxClicks)
+	}
+
+	// The rows are inserted in the batch order, so that of several URLs with the same full URL the first one wins.
+	query := `
+	INSERT INTO shortened_urls (
+		short_url, full_url, user_id, expires_at, redirect_status, passthrough, password_hash, max_clicks
+	)
+	SELECT short_url, full_url, user_id, expires_at, redirect_status, passthrough, password_hash, max_clicks
+	FROM unnest($1::text[], $2::text[], $3::text[], $4::timestamptz[], $5::smallint[], $6::text[], $7::text[], $8::bigint[])
+		WITH ORDINALITY AS b(short_url, full_url, user_id, expires_at, redirect_status, passthrough, password_hash, max_clicks, n)
+	ORDER BY n
+	ON CONFLICT DO NOTHING
+	RETURNING short_url, full_url;
+	`
+	rows, err := p.pool.Query(ctx, query,
+		shortURLs, fullURLs, userIDs, expiresAt, redirectStatuses, passthroughs, passwordHashes, maxClicks)
+	if err != nil {
+		return nil, fmt.Errorf("failed to save batch: %w", err)
+	}
+	type row struct{ shortURL, fullURL string }
+	inserted := make(map[row]struct{}, len(urls))
+	for rows.Next() {
+		var r row
+		if err := rows.Scan(&r.shortURL, &r.fullURL); err != nil {
+			rows.Close()
+			return nil, fmt.Errorf("failed to read saved URL: %w", err)
+		}
+		inserted[r] = struct{}{}
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("failed to save batch: %w", err)
+	}
+
+	results := make([]usecases.SaveResult, len(urls))
+	var skipped []int
+	var skippedUserIDs, skippedFullURLs []string
+	for i, url := range urls {
+		key := row{shortURL: url.ShortURL, fullURL: url.FullURL}
+		if _, ok := inserted[key]; ok {
+			// A repeated URL has only been inserted once.
+			delete(inserted, key)
+			results[i] = usecases.SaveResult{Status: usecases.SaveCreated, ShortURL: url.ShortURL}
+			continue
+		}
+		if url.HasOptions() {
+			// A URL with options only conflicts with stored URLs by its short URL.
+			results[i] = usecases.SaveResult{Status: usecases.SaveShortURLTaken}
+			continue
+		}
+		skipped = append(skipped, i)
+		skippedUserIDs = append(skippedUserIDs, url.UserID)
+		skippedFullURLs = append(skippedFullURLs, url.FullURL)
+	}
+	if len(skipped) == 0 {
+		return results, nil
+	}
+
+	existing, err := p.getShortURLsByFullURLs(ctx, skippedUserIDs, skippedFullURLs)
+	if err != nil {
+		return nil, err
+	}
+	for _, i := range skipped {
+		if shortURL, ok := existing[newFullURLKey(urls[i])]; ok {
+			results[i] = usecases.SaveResult{Status: usecases.SaveExisting, ShortURL: shortURL}
+		} else {
+			results[i] = usecases.SaveResult{Status: usecases.SaveShortURLTaken}
+		}
+	}
+	return results, nil
+}
+
+// getShortURLsByFullURLs returns the short URLs the users have shortened the full URLs to,
+// the i-th full URL being looked up for the i-th user. Edited and deleted URLs and URLs with options
+// are left out, the same way they are left out of the unique index on full_url.
+func (p *PostgresStorage) getShortURLsByFullURLs(ctx context.Context, userIDs, fullURLs []string) (map[fullURLKey]ShortURL, error) {
+	query := `
+	SELECT DISTINCT COALESCE(s.user_id, ''), s.full_url, s.short_url
+	FROM shortened_urls AS s
+	JOIN unnest($1::text[], $2::text[]) AS r(user_id, full_url)
+		ON COALESCE(s.user_id, '') = r.user_id AND s.full_url = r.full_url
+	WHERE NOT s.is_edited AND NOT s.is_deleted AND s.expires_at IS NULL AND s.redirect_status = 0 AND s.passthrough = ''
+		AND s.password_hash = '' AND s.max_clicks = 0;
+	`
+	rows, err := p.pool.Query(ctx, query, userIDs, fullURLs)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get existing short URLs: %w", err)
+	}
+	defer rows.Close()
+	shortURLs := make(map[fullURLKey]ShortURL, len(fullURLs))
+	for rows.Next() {
+		var (
+			key      fullURLKey
+			shortURL string
+		)
+		if err := rows.Scan(&key.userID, &key.fullURL, &shortURL); err != nil {
+			return nil, fmt.Errorf("failed to read existing short URL: %w", err)
+		}
+		shortURLs[key] = shortURL
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("failed to get existing short URLs: %w", err)
+	}
+	return shortURLs, nil
+}
+
+// DeleteURLs marks the URLs as deleted with a single UPDATE.
+// A URL is only deleted if it belongs to the given user, other URLs are silently skipped.
+func (p *PostgresStorage) DeleteURLs(ctx context.Context, urls []entity.URL) error {
 	if len(urls) == 0 {
-		return usecases.ErrEmptyBatch
+		return nil
+	}
+	shortURLs := make([]string, 0, len(urls))
+	userIDs := make([]string, 0, len(urls))
+	for _, url := range urls {
+		shortURLs = append(shortURLs, url.ShortURL)
+		userIDs = append(userIDs, url.UserID)
+	}
+
+	query := `
+	UPDATE shortened_urls AS s
+	SET is_deleted = TRUE
+	FROM unnest($1::text[], $2::text[]) AS d(short_url, user_id)
+	WHERE s.short_url = d.short_url AND s.user_id = d.user_id AND NOT s.is_deleted;
+	`
+	_, err := p.pool.Exec(ctx, query, shortURLs, userIDs)
+	if err != nil {
+		return fmt.Errorf("failed to delete URLs: %w", err)
+	}
+	return nil
+}
+
+// PurgeExpiredURLs removes URLs that expired before the given moment and returns how many were removed.
+func (p *PostgresStorage) PurgeExpiredURLs(ctx context.Context, before time.Time) (int64, error) {
+	// The revisions and clicks go along with the URLs, so that a reused short URL does not inherit them.
+	query := `
+	WITH purged AS (
+		DELETE FROM shortened_urls
+		WHERE expires_at < $1
+		RETURNING short_url
+	), purged_revisions AS (
+		DELETE FROM url_revisions
+		WHERE short_url IN (SELECT short_url FROM purged)
+	), purged_clicks AS (
+		DELETE FROM clicks
+		WHERE short_url IN (SELECT short_url FROM purged)
+	)
+	SELECT count(*) FROM purged;
+	`
+	var purged int64
+	err := p.pool.QueryRow(ctx, query, before).Scan(&purged)
+	if err != nil {
+		return 0, fmt.Errorf("failed to purge expired URLs: %w", err)
+	}
+	return purged, nil
+}
+
+// UpdateFullURL changes the full URL and inserts the revision in a single transaction.
+// The row is locked first, so that concurrent edits of the same short URL record consistent revisions.
+func (p *PostgresStorage) UpdateFullURL(ctx context.Context, url entity.URL) error {
+	if url.ShortURL == "" {
+		return usecases.ErrEmptyShortURL
+	}
+	if url.FullURL == "" {
+		return usecases.ErrEmptyFullURL
 	}
 	tx, err := p.pool.Begin(ctx)
 	if err != nil {
 		return fmt.Errorf("failed to begin transaction: %w", err)
 	}
 	defer func() {
-		if err != nil {
-			err := tx.Rollback(ctx)
-			if err != nil {
-				zap.L().Error("failed to rollback transaction", zap.Error(err))
-			}
+		// Rollback is a no-op after a successful commit.
+		if err := tx.Rollback(ctx); err != nil && !errors.Is(err, pgx.ErrTxClosed) {
+			zap.L().Error("failed to rollback transaction", zap.Error(err))
 		}
 	}()
 
-	for _, url := range urls {
-		query := `
-		INSERT INTO shortened_urls	 (short_url, full_url)
-		VALUES ($1, $2);
-		`
-		_, err = tx.Exec(ctx, query, url.ShortURL, url.FullURL)
-		if err != nil {
-			zap.L().Error("failed to save URL in batch", zap.Error(err))
-			if pgErr, ok := err.(*pgconn.PgError); ok && pgErr.Code == pgerrcode.UniqueViolation {
-				// If we get a unique violation, try to get the existing short URL again
-				existingShortURL, err := p.GetShortURLByFullURL(ctx, url.FullURL)
-				if err != nil {
-					return fmt.Errorf("failed to get existing short URL: %w", err)
-				}
-				return fmt.Errorf("%w: %s", usecases.ErrURLConflict, existingShortURL)
-			}
-			return fmt.Errorf("failed to save URL: %w", err)
+	query := `
+	SELECT full_url, COALESCE(user_id, ''), is_deleted
+	FROM shortened_urls
+	WHERE short_url = $1
+	FOR UPDATE;
+	`
+	var (
+		previousURL string
+		userID      string
+		isDeleted   bool
+	)
+	err = tx.QueryRow(ctx, query, url.ShortURL).Scan(&previousURL, &userID, &isDeleted)
+	if err != nil {
+		if errors.Is(err, pgx.ErrNoRows) {
+			return fmt.Errorf("%w: %s", usecases.ErrURLNotFound, url.ShortURL)
 		}
+		return fmt.Errorf("couldn't get URL %s: %w", url.ShortURL, err)
+	}
+	if userID != url.UserID {
+		return fmt.Errorf("%w: %s", usecases.ErrNotURLOwner, url.ShortURL)
+	}
+	if isDeleted {
+		return fmt.Errorf("%w: %s", usecases.ErrURLDeleted, url.ShortURL)
+	}
+	if previousURL == url.FullURL {
+		return nil
 	}
 
+	_, err = tx.Exec(ctx, `
+	UPDATE shortened_urls
+	SET full_url = $2, is_edited = TRUE
+	WHERE short_url = $1;
+	`, url.ShortURL, url.FullURL)
+	if err != nil {
+		return fmt.Errorf("failed to update URL: %w", err)
+	}
+	_, err = tx.Exec(ctx, `
+	INSERT INTO url_revisions (short_url, previous_url, full_url)
+	VALUES ($1, $2, $3);
+	`, url.ShortURL, previousURL, url.FullURL)
+	if err != nil {
+		return fmt.Errorf("failed to save URL revision: %w", err)
+	}
 	if err = tx.Commit(ctx); err != nil {
 		return fmt.Errorf("failed to commit transaction: %w", err)
 	}
+	return nil
+}
+
+func (p *PostgresStorage) GetURLRevisions(ctx context.Context, shortURL ShortURL) ([]entity.URLRevision, error) {
+	if shortURL == "" {
+		return nil, usecases.ErrEmptyShortURL
+	}
+	query := `
+	SELECT previous_url, full_url, changed_at
+	FROM url_revisions
+	WHERE short_url = $1
+	ORDER BY id;
+	`
+	rows, err := p.pool.Query(ctx, query, shortURL)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get revisions of %s: %w", shortURL, err)
+	}
+	defer rows.Close()
+	var revisions []entity.URLRevision
+	for rows.Next() {
+		revision := entity.URLRevision{ShortURL: shortURL}
+		if err := rows.Scan(&revision.PreviousFullURL, &revision.FullURL, &revision.ChangedAt); err != nil {
+			return nil, fmt.Errorf("failed to read revision of %s: %w", shortURL, err)
+		}
+		revisions = append(revisions, revision)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("failed to get revisions of %s: %w", shortURL, err)
+	}
+	return revisions, nil
+}
+
+// nullTime converts zero time to NULL.
+func nullTime(t time.Time) *time.Time {
+	if t.IsZero() {
+		return nil
+	}
+	return &t
+}
 
+// SaveClicks inserts the clicks with a single COPY.
+func (p *PostgresStorage) SaveClicks(ctx context.Context, clicks []entity.Click) error {
+	if len(clicks) == 0 {
+		return nil
+	}
+	_, err := p.pool.CopyFrom(
+		ctx,
+		pgx.Identifier{"clicks"},
+		[]string{"short_url", "clicked_at", "referrer", "user_agent", "ip_hash"},
+		pgx.CopyFromSlice(len(clicks), func(i int) ([]interface{}, error) {
+			c := clicks[i]
+			return []interface{}{c.ShortURL, c.ClickedAt, c.Referrer, c.UserAgent, c.IPHash}, nil
+		}),
+	)
+	if err != nil {
+		return fmt.Errorf("failed to save clicks: %w", err)
+	}
 	return nil
 }
+
+func (p *PostgresStorage) GetClickStats(ctx context.Context, shortURL ShortURL) (entity.ClickStats, error) {
+	if shortURL == "" {
+		return entity.ClickStats{}, usecases.ErrEmptyShortURL
+	}
+	exists, err := p.isShortURLExists(ctx, shortURL)
+	if err != nil {
+		return entity.ClickStats{}, fmt.Errorf("failed to check short URL %s: %w", shortURL, err)
+	}
+	if !exists {
+		return entity.ClickStats{}, fmt.Errorf("%w: %s", usecases.ErrURLNotFound, shortURL)
+	}
+
+	query := `
+	SELECT (clicked_at AT TIME ZONE 'UTC')::date AS day, count(*)
+	FROM clicks
+	WHERE short_url = $1
+	GROUP BY day
+	ORDER BY day;
+	`
+	rows, err := p.pool.Query(ctx, query, shortURL)
+	if err != nil {
+		return entity.ClickStats{}, fmt.Errorf("failed to get click stats for %s: %w", shortURL, err)
+	}
+	defer rows.Close()
+
+	stats := entity.ClickStats{ShortURL: shortURL}
+	for rows.Next() {
+		var daily entity.DailyClicks
+		if err := rows.Scan(&daily.Date, &daily.Clicks); err != nil {
+			return entity.ClickStats{}, fmt.Errorf("failed to scan click stats: %w", err)
+		}
+		stats.Total += daily.Clicks
+		stats.Daily = append(stats.Daily, daily)
+	}
+	if err := rows.Err(); err != nil {
+		return entity.ClickStats{}, fmt.Errorf("failed to read click stats for %s: %w", shortURL, err)
+	}
+	return stats, nil
+}
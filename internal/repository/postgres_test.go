@@ -0,0 +1,52 @@
+package repository
+
+import (
+	"context"
+	"net/url"
+	"testing"
+
+	"github.com/jackc/pgx/v4/pgxpool"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/radiophysiker/shortener_link/internal/repository/migrations"
+)
+
+// TestMigratorVersionIsReadOnly runs against a schema of its own, so that it sees a database without migrations.
+func TestMigratorVersionIsReadOnly(t *testing.T) {
+	ctx := context.Background()
+	dsn := postgresDSN(t)
+	admin, err := pgxpool.Connect(ctx, dsn)
+	require.NoError(t, err)
+	defer admin.Close()
+	_, err = admin.Exec(ctx, "DROP SCHEMA IF EXISTS migrator_version_test CASCADE; CREATE SCHEMA migrator_version_test;")
+	require.NoError(t, err)
+	defer func() {
+		_, err := admin.Exec(ctx, "DROP SCHEMA migrator_version_test CASCADE;")
+		assert.NoError(t, err)
+	}()
+
+	parsed, err := url.Parse(dsn)
+	require.NoError(t, err)
+	query := parsed.Query()
+	query.Set("search_path", "migrator_version_test")
+	parsed.RawQuery = query.Encode()
+	pool, err := pgxpool.Connect(ctx, parsed.String())
+	require.NoError(t, err)
+	defer pool.Close()
+	migrator, err := migrations.NewMigrator(pool)
+	require.NoError(t, err)
+
+	version, err := migrator.Version(ctx)
+	require.NoError(t, err, "Version should succeed without the schema_migrations table")
+	assert.Zero(t, version)
+	var exists bool
+	require.NoError(t, pool.QueryRow(ctx, "SELECT to_regclass('schema_migrations') IS NOT NULL;").Scan(&exists))
+	assert.False(t, exists, "Version should not create the schema_migrations table")
+
+	applied, err := migrator.Up(ctx)
+	require.NoError(t, err)
+	version, err = migrator.Version(ctx)
+	require.NoError(t, err)
+	assert.Equal(t, int64(applied), version)
+}
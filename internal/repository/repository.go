@@ -2,18 +2,65 @@ package repository
 
 import (
 	"context"
+	"fmt"
+	"time"
 
 	"github.com/radiophysiker/shortener_link/internal/config"
 	"github.com/radiophysiker/shortener_link/internal/entity"
+	"github.com/radiophysiker/shortener_link/internal/usecases"
 )
 
 type Saver interface {
 	Save(ctx context.Context, url entity.URL) error
-	SaveBatch(ctx context.Context, urls []entity.URL) error
+	SaveBatch(ctx context.Context, urls []entity.URL) ([]usecases.SaveResult, error)
 }
 
 type Finder interface {
 	GetFullURL(ctx context.Context, shortURL ShortURL) (FullURL, error)
+	// ResolveURL returns the URL a short URL redirects to. It fails if the URL is deleted or expired.
+	ResolveURL(ctx context.Context, shortURL ShortURL) (entity.URL, error)
+	// GetURL returns the stored URL, even if it is deleted or expired.
+	GetURL(ctx context.Context, shortURL ShortURL) (entity.URL, error)
+}
+
+type Editor interface {
+	// UpdateFullURL changes the full URL of url.ShortURL to url.FullURL and records the revision.
+	// It fails with usecases.ErrNotURLOwner unless the short URL belongs to url.UserID.
+	// An edited URL is left out of full URL conflict checks.
+	UpdateFullURL(ctx context.Context, url entity.URL) error
+	GetURLRevisions(ctx context.Context, shortURL ShortURL) ([]entity.URLRevision, error)
+}
+
+type ClickLimiter interface {
+	// ConsumeClick atomically counts a redirect of a URL with a click limit and returns how many are left.
+	// It fails with usecases.ErrURLExhausted once the limit is reached. URLs without a limit are not counted.
+	ConsumeClick(ctx context.Context, shortURL ShortURL) (int64, error)
+}
+
+type Lister interface {
+	GetURLsByUserID(ctx context.Context, userID string) ([]entity.URL, error)
+}
+
+type Deleter interface {
+	DeleteURLs(ctx context.Context, urls []entity.URL) error
+}
+
+type Purger interface {
+	PurgeExpiredURLs(ctx context.Context, before time.Time) (int64, error)
+}
+
+type ClickStorage interface {
+	SaveClicks(ctx context.Context, clicks []entity.Click) error
+	GetClickStats(ctx context.Context, shortURL ShortURL) (entity.ClickStats, error)
+}
+
+type Sequencer interface {
+	NextSequenceValue(ctx context.Context) (int64, error)
+}
+
+type CodeLengthStore interface {
+	GetCodeLength(ctx context.Context) (int, error)
+	SetCodeLength(ctx context.Context, length int) error
 }
 
 type Closer interface {
@@ -23,9 +70,31 @@ type Closer interface {
 type Storage interface {
 	Saver
 	Finder
+	Editor
+	ClickLimiter
+	Lister
+	Deleter
+	Purger
+	ClickStorage
+	Sequencer
+	CodeLengthStore
 	Closer
 }
 
+// resolveURL returns the URL found for a redirect, or an error if it does not redirect at the given moment.
+func resolveURL(url entity.URL, now time.Time) (entity.URL, error) {
+	if url.IsDeleted {
+		return entity.URL{}, fmt.Errorf("%w: %s", usecases.ErrURLDeleted, url.ShortURL)
+	}
+	if url.IsExpired(now) {
+		return entity.URL{}, fmt.Errorf("%w: %s", usecases.ErrURLExpired, url.ShortURL)
+	}
+	if url.IsExhausted() {
+		return entity.URL{}, fmt.Errorf("%w: %s", usecases.ErrURLExhausted, url.ShortURL)
+	}
+	return url, nil
+}
+
 func NewStorage(cfg *config.Config) (Storage, error) {
 	if cfg.DatabaseDSN != "" {
 		pgStorage, err := NewPostgresStorage(cfg.DatabaseDSN)
@@ -34,5 +103,13 @@ func NewStorage(cfg *config.Config) (Storage, error) {
 		}
 		return pgStorage, nil
 	}
-	return NewGenericStorage(cfg.FileStoragePath)
+	if cfg.SQLitePath != "" {
+		return NewSQLiteStorage(cfg.SQLitePath)
+	}
+	return NewGenericStorageWithOptions(cfg.FileStoragePath, GenericStorageOptions{
+		SyncPolicy:         SyncPolicy(cfg.FileSyncPolicy),
+		SyncInterval:       cfg.FileSyncInterval,
+		CompactionRatio:    cfg.CompactionRatio,
+		CompactionInterval: cfg.CompactionInterval,
+	})
 }
@@ -0,0 +1,702 @@
+package repository
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+
+	"go.uber.org/zap"
+	"modernc.org/sqlite"
+	sqlite3 "modernc.org/sqlite/lib"
+
+	"github.com/radiophysiker/shortener_link/internal/entity"
+	"github.com/radiophysiker/shortener_link/internal/usecases"
+)
+
+// sqliteShortURLColumn is how SQLite names shortened_urls.short_url in unique constraint errors.
+const sqliteShortURLColumn = "shortened_urls.short_url"
+
+// sqliteShortURLByFullURL finds the URL of the same user a full URL conflicts with. Edited and deleted URLs
+// and URLs with options are left out, the same way PostgresStorage.GetShortURLByFullURL leaves them out.
+const sqliteShortURLByFullURL = `
+	SELECT short_url
+	FROM shortened_urls
+	WHERE COALESCE(user_id, '') = ? AND full_url = ? AND NOT is_edited AND NOT is_deleted
+		AND expires_at IS NULL AND redirect_status = 0 AND passthrough = '' AND password_hash = '' AND max_clicks = 0;
+	`
+
+// sqliteMigrations are applied in order, PRAGMA user_version holds how many of them are applied.
+// Append new migrations to the end, never edit the applied ones.
+var sqliteMigrations = []string{
+	`
+	CREATE TABLE shortened_urls (
+		id INTEGER PRIMARY KEY AUTOINCREMENT,
+		short_url TEXT NOT NULL UNIQUE,
+		full_url TEXT NOT NULL UNIQUE,
+		user_id TEXT,
+		is_deleted INTEGER NOT NULL DEFAULT 0,
+		-- Unix time in nanoseconds.
+		expires_at INTEGER,
+		created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP
+	);
+	CREATE INDEX idx_user_id ON shortened_urls(user_id);
+	CREATE INDEX idx_expires_at ON shortened_urls(expires_at) WHERE expires_at IS NOT NULL;
+	CREATE TABLE clicks (
+		id INTEGER PRIMARY KEY AUTOINCREMENT,
+		short_url TEXT NOT NULL,
+		-- Unix time in nanoseconds.
+		clicked_at INTEGER NOT NULL,
+		referrer TEXT,
+		user_agent TEXT,
+		ip_hash TEXT
+	);
+	CREATE INDEX idx_clicks_short_url ON clicks(short_url, clicked_at);
+	CREATE TABLE sequences (
+		name TEXT PRIMARY KEY,
+		value INTEGER NOT NULL
+	);
+	CREATE TABLE settings (
+		key TEXT PRIMARY KEY,
+		value TEXT NOT NULL
+	);
+	`,
+	// Edited URLs no longer take part in full URL conflict checks, so only the URLs that have never been edited
+	// keep their full URLs unique. SQLite cannot drop a column constraint, the table is rebuilt instead.
+	`
+	CREATE TABLE shortened_urls_new (
+		id INTEGER PRIMARY KEY AUTOINCREMENT,
+		short_url TEXT NOT NULL UNIQUE,
+		full_url TEXT NOT NULL,
+		user_id TEXT,
+		is_deleted INTEGER NOT NULL DEFAULT 0,
+		-- Unix time in nanoseconds.
+		expires_at INTEGER,
+		created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+		is_edited INTEGER NOT NULL DEFAULT 0
+	);
+	INSERT INTO shortened_urls_new (id, short_url, full_url, user_id, is_deleted, expires_at, created_at)
+	SELECT id, short_url, full_url, user_id, is_deleted, expires_at, created_at FROM shortened_urls;
+	DROP TABLE shortened_urls;
+	ALTER TABLE shortened_urls_new RENAME TO shortened_urls;
+	CREATE INDEX idx_user_id ON shortened_urls(user_id);
+	CREATE INDEX idx_expires_at ON shortened_urls(expires_at) WHERE expires_at IS NOT NULL;
+	CREATE UNIQUE INDEX idx_full_url ON shortened_urls(full_url) WHERE NOT is_edited;
+	CREATE TABLE url_revisions (
+		id INTEGER PRIMARY KEY AUTOINCREMENT,
+		short_url TEXT NOT NULL,
+		previous_url TEXT NOT NULL,
+		full_url TEXT NOT NULL,
+		-- Unix time in nanoseconds.
+		changed_at INTEGER NOT NULL
+	);
+	CREATE INDEX idx_url_revisions_short_url ON url_revisions(short_url, id);
+	`,
+	`
+	-- Zero means the redirect status configured for the server.
+	ALTER TABLE shortened_urls ADD COLUMN redirect_status INTEGER NOT NULL DEFAULT 0;
+	`,
+	`
+	-- Empty means the redirect does not carry anything over from the request.
+	ALTER TABLE shortened_urls ADD COLUMN passthrough TEXT NOT NULL DEFAULT '';
+	`,
+	`
+	-- Empty means the short URL is not protected with a password.
+	ALTER TABLE shortened_urls ADD COLUMN password_hash TEXT NOT NULL DEFAULT '';
+	`,
+	`
+	-- Zero max_clicks means no click limit. redirects is only counted for URLs with a limit.
+	ALTER TABLE shortened_urls ADD COLUMN max_clicks INTEGER NOT NULL DEFAULT 0;
+	ALTER TABLE shortened_urls ADD COLUMN redirects INTEGER NOT NULL DEFAULT 0;
+	`,
+	`
+	-- Deleted URLs no longer take part in full URL conflict checks, so that a deleted full URL can be shortened again.
+	DROP INDEX idx_full_url;
+	CREATE UNIQUE INDEX idx_full_url ON shortened_urls(full_url) WHERE NOT is_edited AND NOT is_deleted;
+	`,
+	`
+	-- Full URLs are only unique per user, so that a user is never handed a URL another user may edit or delete.
+	DROP INDEX idx_full_url;
+	CREATE UNIQUE INDEX idx_full_url ON shortened_urls(COALESCE(user_id, ''), full_url) WHERE NOT is_edited AND NOT is_deleted;
+	`,
+	`
+	-- URLs with options no longer take part in full URL conflict checks, the same way as in PostgreSQL.
+	DROP INDEX idx_full_url;
+	CREATE UNIQUE INDEX idx_full_url ON shortened_urls(COALESCE(user_id, ''), full_url)
+		WHERE NOT is_edited AND NOT is_deleted
+			AND expires_at IS NULL AND redirect_status = 0 AND passthrough = '' AND password_hash = '' AND max_clicks = 0;
+	`,
+}
+
+// SQLiteStorage keeps URLs in an SQLite database. It uses a pure Go driver, so the binary does not need cgo.
+type SQLiteStorage struct {
+	db *sql.DB
+}
+
+func NewSQLiteStorage(path string) (*SQLiteStorage, error) {
+	dsn := "file:" + path + "?" + url.Values{
+		"_pragma": []string{"busy_timeout(5000)", "journal_mode(WAL)", "synchronous(NORMAL)"},
+	}.Encode()
+	db, err := sql.Open("sqlite", dsn)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open SQLite database: %w", err)
+	}
+	// SQLite allows a single writer at a time anyway. One connection serializes writes in the pool
+	// instead of failing them with SQLITE_BUSY.
+	db.SetMaxOpenConns(1)
+
+	ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+	defer cancel()
+	s := &SQLiteStorage{
+		db: db,
+	}
+	if err := s.migrate(ctx); err != nil {
+		db.Close()
+		return nil, fmt.Errorf("failed to migrate SQLite database: %w", err)
+	}
+	return s, nil
+}
+
+// migrate applies the pending sqliteMigrations.
+func (s *SQLiteStorage) migrate(ctx context.Context) error {
+	var version int
+	if err := s.db.QueryRowContext(ctx, "PRAGMA user_version;").Scan(&version); err != nil {
+		return fmt.Errorf("failed to get schema version: %w", err)
+	}
+	if version > len(sqliteMigrations) {
+		return fmt.Errorf("schema version %d is newer than this build supports", version)
+	}
+	for i := version; i < len(sqliteMigrations); i++ {
+		tx, err := s.db.BeginTx(ctx, nil)
+		if err != nil {
+			return fmt.Errorf("failed to begin transaction: %w", err)
+		}
+		if _, err := tx.ExecContext(ctx, sqliteMigrations[i]); err != nil {
+			_ = tx.Rollback()
+			return fmt.Errorf("failed to apply migration %d: %w", i+1, err)
+		}
+		// PRAGMA does not accept parameters.
+		if _, err := tx.ExecContext(ctx, "PRAGMA user_version = "+strconv.Itoa(i+1)+";"); err != nil {
+			_ = tx.Rollback()
+			return fmt.Errorf("failed to set schema version: %w", err)
+		}
+		if err := tx.Commit(); err != nil {
+			return fmt.Errorf("failed to commit migration %d: %w", i+1, err)
+		}
+		zap.L().Info("applied SQLite migration", zap.Int("version", i+1))
+	}
+	return nil
+}
+
+func (s *SQLiteStorage) Ping(ctx context.Context) error {
+	return s.db.PingContext(ctx)
+}
+
+func (s *SQLiteStorage) Close() error {
+	return s.db.Close()
+}
+
+// execer is implemented by both *sql.DB and *sql.Tx.
+type execer interface {
+	ExecContext(ctx context.Context, query string, args ...any) (sql.Result, error)
+	QueryRowContext(ctx context.Context, query string, args ...any) *sql.Row
+}
+
+func (s *SQLiteStorage) Save(ctx context.Context, url entity.URL) error {
+	if url.FullURL == "" {
+		return usecases.ErrEmptyFullURL
+	}
+	return s.insert(ctx, s.db, url)
+}
+
+// insert inserts the URL and translates unique violations the same way PostgresStorage does.
+func (s *SQLiteStorage) insert(ctx context.Context, db execer, url entity.URL) error {
+	query := `
+	INSERT INTO shortened_urls (short_url, full_url, user_id, expires_at, redirect_status, passthrough, password_hash, max_clicks)
+	VALUES (?, ?, ?, ?, ?, ?, ?, ?);
+	`
+	_, err := db.ExecContext(ctx, query, url.ShortURL, url.FullURL, url.UserID,
+		nullUnixNano(url.ExpiresAt), url.RedirectStatus, string(url.Passthrough), url.PasswordHash, url.MaxClicks)
+	if err == nil {
+		return nil
+	}
+	var sqliteErr *sqlite.Error
+	if errors.As(err, &sqliteErr) && sqliteErr.Code() == sqlite3.SQLITE_CONSTRAINT_UNIQUE {
+		if strings.Contains(sqliteErr.Error(), sqliteShortURLColumn) {
+			return fmt.Errorf("%w: %s", usecases.ErrURLGeneratedBefore, url.ShortURL)
+		}
+		var existingShortURL string
+		err := db.QueryRowContext(ctx, sqliteShortURLByFullURL, url.UserID, url.FullURL).Scan(&existingShortURL)
+		if err != nil {
+			return fmt.Errorf("failed to get existing short URL: %w", err)
+		}
+		return &usecases.ConflictError{ExistingShortURL: existingShortURL}
+	}
+	return fmt.Errorf("failed to save URL: %w", err)
+}
+
+// SaveBatch inserts the URLs in a single transaction, skipping those that conflict with a stored URL.
+func (s *SQLiteStorage) SaveBatch(ctx context.Context, urls []entity.URL) ([]usecases.SaveResult, error) {
+	if len(urls) == 0 {
+		return nil, usecases.ErrEmptyBatch
+	}
+	tx, err := s.db.BeginTx(ctx, nil)
+	if err != nil {
+		return nil, fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer func() {
+		// Rollback is a no-op after a successful commit.
+		_ = tx.Rollback()
+	}()
+	insert, err := tx.PrepareContext(ctx, `
+	INSERT INTO shortened_urls (short_url, full_url, user_id, expires_at, redirect_status, passthrough, password_hash, max_clicks)
+	VALUES (?, ?, ?, ?, ?, ?, ?, ?)
+	ON CONFLICT DO NOTHING;
+	`)
+	if err != nil {
+		return nil, fmt.Errorf("failed to prepare insert: %w", err)
+	}
+	defer insert.Close()
+
+	results := make([]usecases.SaveResult, 0, len(urls))
+	for _, url := range urls {
+		res, err := insert.ExecContext(ctx, url.ShortURL, url.FullURL, url.UserID,
+			nullUnixNano(url.ExpiresAt), url.RedirectStatus, string(url.Passthrough), url.PasswordHash, url.MaxClicks)
+		if err != nil {
+			return nil, fmt.Errorf("failed to save URL: %w", err)
+		}
+		inserted, err := res.RowsAffected()
+		if err != nil {
+			return nil, fmt.Errorf("failed to save URL: %w", err)
+		}
+		if inserted > 0 {
+			results = append(results, usecases.SaveResult{Status: usecases.SaveCreated, ShortURL: url.ShortURL})
+			continue
+		}
+		if url.HasOptions() {
+			// A URL with options only conflicts with stored URLs by its short URL.
+			results = append(results, usecases.SaveResult{Status: usecases.SaveShortURLTaken})
+			continue
+		}
+		var existingShortURL string
+		err = tx.QueryRowContext(ctx, sqliteShortURLByFullURL, url.UserID, url.FullURL).Scan(&existingShortURL)
+		switch {
+		case err == nil:
+			results = append(results, usecases.SaveResult{Status: usecases.SaveExisting, ShortURL: existingShortURL})
+		case errors.Is(err, sql.ErrNoRows):
+			results = append(results, usecases.SaveResult{Status: usecases.SaveShortURLTaken})
+		default:
+			return nil, fmt.Errorf("failed to get existing short URL: %w", err)
+		}
+	}
+	if err := tx.Commit(); err != nil {
+		return nil, fmt.Errorf("failed to commit transaction: %w", err)
+	}
+	return results, nil
+}
+
+func (s *SQLiteStorage) GetFullURL(ctx context.Context, shortURL ShortURL) (FullURL, error) {
+	url, err := s.ResolveURL(ctx, shortURL)
+	return url.FullURL, err
+}
+
+func (s *SQLiteStorage) ResolveURL(ctx context.Context, shortURL ShortURL) (entity.URL, error) {
+	url, err := s.GetURL(ctx, shortURL)
+	if err != nil {
+		return entity.URL{}, err
+	}
+	return resolveURL(url, time.Now())
+}
+
+// ConsumeClick counts the redirect with a conditional UPDATE, the same way PostgresStorage does.
+func (s *SQLiteStorage) ConsumeClick(ctx context.Context, shortURL ShortURL) (int64, error) {
+	if shortURL == "" {
+		return 0, usecases.ErrEmptyShortURL
+	}
+	query := `
+	UPDATE shortened_urls
+	SET redirects = redirects + 1
+	WHERE short_url = ? AND max_clicks > 0 AND redirects < max_clicks
+		AND NOT is_deleted AND (expires_at IS NULL OR expires_at > ?)
+	RETURNING max_clicks - redirects;
+	`
+	var left int64
+	err := s.db.QueryRowContext(ctx, query, shortURL, time.Now().UnixNano()).Scan(&left)
+	if err == nil {
+		return left, nil
+	}
+	if !errors.Is(err, sql.ErrNoRows) {
+		return 0, fmt.Errorf("failed to consume click of %s: %w", shortURL, err)
+	}
+	// Nothing has been counted: find out why.
+	url, err := s.ResolveURL(ctx, shortURL)
+	if err != nil {
+		return 0, err
+	}
+	if url.MaxClicks == 0 {
+		return 0, nil
+	}
+	// The URL has expired or been exhausted between the two queries.
+	return 0, fmt.Errorf("%w: %s", usecases.ErrURLExhausted, shortURL)
+}
+
+func (s *SQLiteStorage) GetURL(ctx context.Context, shortURL ShortURL) (entity.URL, error) {
+	if shortURL == "" {
+		return entity.URL{}, usecases.ErrEmptyShortURL
+	}
+	query := `
+	SELECT short_url, full_url, COALESCE(user_id, ''), is_deleted, expires_at, redirect_status, passthrough, password_hash,
+		max_clicks, redirects
+	FROM shortened_urls
+	WHERE short_url = ?;
+	`
+	var (
+		url         entity.URL
+		expiresAt   sql.NullInt64
+		passthrough string
+	)
+	err := s.db.QueryRowContext(ctx, query, shortURL).Scan(&url.ShortURL, &url.FullURL, &url.UserID, &url.IsDeleted,
+		&expiresAt, &url.RedirectStatus, &passthrough, &url.PasswordHash, &url.MaxClicks, &url.Redirects)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return entity.URL{}, fmt.Errorf("%w: %s", usecases.ErrURLNotFound, shortURL)
+		}
+		return entity.URL{}, fmt.Errorf("couldn't get URL %s: %w", shortURL, err)
+	}
+	if expiresAt.Valid {
+		url.ExpiresAt = time.Unix(0, expiresAt.Int64)
+	}
+	url.Passthrough = entity.PassthroughMode(passthrough)
+	return url, nil
+}
+
+// UpdateFullURL changes the full URL and inserts the revision in a single transaction.
+func (s *SQLiteStorage) UpdateFullURL(ctx context.Context, url entity.URL) error {
+	if url.ShortURL == "" {
+		return usecases.ErrEmptyShortURL
+	}
+	if url.FullURL == "" {
+		return usecases.ErrEmptyFullURL
+	}
+	tx, err := s.db.BeginTx(ctx, nil)
+	if err != nil {
+		return fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer func() {
+		// Rollback is a no-op after a successful commit.
+		_ = tx.Rollback()
+	}()
+
+	var (
+		previousURL string
+		userID      string
+		isDeleted   bool
+	)
+	err = tx.QueryRowContext(ctx, "SELECT full_url, COALESCE(user_id, ''), is_deleted FROM shortened_urls WHERE short_url = ?;",
+		url.ShortURL).Scan(&previousURL, &userID, &isDeleted)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return fmt.Errorf("%w: %s", usecases.ErrURLNotFound, url.ShortURL)
+		}
+		return fmt.Errorf("couldn't get URL %s: %w", url.ShortURL, err)
+	}
+	if userID != url.UserID {
+		return fmt.Errorf("%w: %s", usecases.ErrNotURLOwner, url.ShortURL)
+	}
+	if isDeleted {
+		return fmt.Errorf("%w: %s", usecases.ErrURLDeleted, url.ShortURL)
+	}
+	if previousURL == url.FullURL {
+		return nil
+	}
+
+	_, err = tx.ExecContext(ctx, "UPDATE shortened_urls SET full_url = ?, is_edited = 1 WHERE short_url = ?;",
+		url.FullURL, url.ShortURL)
+	if err != nil {
+		return fmt.Errorf("failed to update URL: %w", err)
+	}
+	query := `
+	INSERT INTO url_revisions (short_url, previous_url, full_url, changed_at)
+	VALUES (?, ?, ?, ?);
+	`
+	_, err = tx.ExecContext(ctx, query, url.ShortURL, previousURL, url.FullURL, time.Now().UnixNano())
+	if err != nil {
+		return fmt.Errorf("failed to save URL revision: %w", err)
+	}
+	if err := tx.Commit(); err != nil {
+		return fmt.Errorf("failed to commit transaction: %w", err)
+	}
+	return nil
+}
+
+func (s *SQLiteStorage) GetURLRevisions(ctx context.Context, shortURL ShortURL) ([]entity.URLRevision, error) {
+	if shortURL == "" {
+		return nil, usecases.ErrEmptyShortURL
+	}
+	query := `
+	SELECT previous_url, full_url, changed_at
+	FROM url_revisions
+	WHERE short_url = ?
+	ORDER BY id;
+	`
+	rows, err := s.db.QueryContext(ctx, query, shortURL)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get revisions of %s: %w", shortURL, err)
+	}
+	defer rows.Close()
+	var revisions []entity.URLRevision
+	for rows.Next() {
+		revision := entity.URLRevision{ShortURL: shortURL}
+		var changedAt int64
+		if err := rows.Scan(&revision.PreviousFullURL, &revision.FullURL, &changedAt); err != nil {
+			return nil, fmt.Errorf("failed to read revision of %s: %w", shortURL, err)
+		}
+		revision.ChangedAt = time.Unix(0, changedAt)
+		revisions = append(revisions, revision)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("failed to get revisions of %s: %w", shortURL, err)
+	}
+	return revisions, nil
+}
+
+func (s *SQLiteStorage) GetURLsByUserID(ctx context.Context, userID string) ([]entity.URL, error) {
+	if userID == "" {
+		return nil, usecases.ErrEmptyUserID
+	}
+	query := `
+	SELECT short_url, full_url, expires_at
+	FROM shortened_urls
+	WHERE user_id = ? AND NOT is_deleted
+	ORDER BY id;
+	`
+	rows, err := s.db.QueryContext(ctx, query, userID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get URLs for user %s: %w", userID, err)
+	}
+	defer rows.Close()
+
+	var urls []entity.URL
+	for rows.Next() {
+		url := entity.URL{UserID: userID}
+		var expiresAt sql.NullInt64
+		if err := rows.Scan(&url.ShortURL, &url.FullURL, &expiresAt); err != nil {
+			return nil, fmt.Errorf("failed to scan URL: %w", err)
+		}
+		if expiresAt.Valid {
+			url.ExpiresAt = time.Unix(0, expiresAt.Int64)
+		}
+		urls = append(urls, url)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("failed to read URLs for user %s: %w", userID, err)
+	}
+	return urls, nil
+}
+
+// DeleteURLs marks the URLs as deleted in a single transaction.
+// A URL is only deleted if it belongs to the given user, other URLs are silently skipped.
+func (s *SQLiteStorage) DeleteURLs(ctx context.Context, urls []entity.URL) error {
+	if len(urls) == 0 {
+		return nil
+	}
+	tx, err := s.db.BeginTx(ctx, nil)
+	if err != nil {
+		return fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer func() {
+		_ = tx.Rollback()
+	}()
+	stmt, err := tx.PrepareContext(ctx, `
+	UPDATE shortened_urls
+	SET is_deleted = 1
+	WHERE short_url = ? AND user_id = ? AND NOT is_deleted;
+	`)
+	if err != nil {
+		return fmt.Errorf("failed to prepare delete: %w", err)
+	}
+	defer stmt.Close()
+	for _, url := range urls {
+		if _, err := stmt.ExecContext(ctx, url.ShortURL, url.UserID); err != nil {
+			return fmt.Errorf("failed to delete URLs: %w", err)
+		}
+	}
+	if err := tx.Commit(); err != nil {
+		return fmt.Errorf("failed to commit transaction: %w", err)
+	}
+	return nil
+}
+
+// PurgeExpiredURLs removes URLs that expired before the given moment and returns how many were removed.
+func (s *SQLiteStorage) PurgeExpiredURLs(ctx context.Context, before time.Time) (int64, error) {
+	tx, err := s.db.BeginTx(ctx, nil)
+	if err != nil {
+		return 0, fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer func() {
+		_ = tx.Rollback()
+	}()
+	// The revisions and clicks go along with the URLs, so that a reused short URL does not inherit them.
+	_, err = tx.ExecContext(ctx, `
+	DELETE FROM url_revisions
+	WHERE short_url IN (SELECT short_url FROM shortened_urls WHERE expires_at < ?);
+	`, before.UnixNano())
+	if err != nil {
+		return 0, fmt.Errorf("failed to purge revisions of expired URLs: %w", err)
+	}
+	_, err = tx.ExecContext(ctx, `
+	DELETE FROM clicks
+	WHERE short_url IN (SELECT short_url FROM shortened_urls WHERE expires_at < ?);
+	`, before.UnixNano())
+	if err != nil {
+		return 0, fmt.Errorf("failed to purge clicks of expired URLs: %w", err)
+	}
+	result, err := tx.ExecContext(ctx, "DELETE FROM shortened_urls WHERE expires_at < ?;", before.UnixNano())
+	if err != nil {
+		return 0, fmt.Errorf("failed to purge expired URLs: %w", err)
+	}
+	purged, err := result.RowsAffected()
+	if err != nil {
+		return 0, fmt.Errorf("failed to purge expired URLs: %w", err)
+	}
+	if err := tx.Commit(); err != nil {
+		return 0, fmt.Errorf("failed to commit transaction: %w", err)
+	}
+	return purged, nil
+}
+
+// SaveClicks inserts the clicks in a single transaction.
+func (s *SQLiteStorage) SaveClicks(ctx context.Context, clicks []entity.Click) error {
+	if len(clicks) == 0 {
+		return nil
+	}
+	tx, err := s.db.BeginTx(ctx, nil)
+	if err != nil {
+		return fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer func() {
+		_ = tx.Rollback()
+	}()
+	stmt, err := tx.PrepareContext(ctx, `
+	INSERT INTO clicks (short_url, clicked_at, referrer, user_agent, ip_hash)
+	VALUES (?, ?, ?, ?, ?);
+	`)
+	if err != nil {
+		return fmt.Errorf("failed to prepare click insert: %w", err)
+	}
+	defer stmt.Close()
+	for _, c := range clicks {
+		if _, err := stmt.ExecContext(ctx, c.ShortURL, c.ClickedAt.UnixNano(), c.Referrer, c.UserAgent, c.IPHash); err != nil {
+			return fmt.Errorf("failed to save clicks: %w", err)
+		}
+	}
+	if err := tx.Commit(); err != nil {
+		return fmt.Errorf("failed to commit transaction: %w", err)
+	}
+	return nil
+}
+
+func (s *SQLiteStorage) GetClickStats(ctx context.Context, shortURL ShortURL) (entity.ClickStats, error) {
+	if shortURL == "" {
+		return entity.ClickStats{}, usecases.ErrEmptyShortURL
+	}
+	var exists bool
+	err := s.db.QueryRowContext(ctx, "SELECT EXISTS (SELECT 1 FROM shortened_urls WHERE short_url = ?);", shortURL).
+		Scan(&exists)
+	if err != nil {
+		return entity.ClickStats{}, fmt.Errorf("failed to check short URL %s: %w", shortURL, err)
+	}
+	if !exists {
+		return entity.ClickStats{}, fmt.Errorf("%w: %s", usecases.ErrURLNotFound, shortURL)
+	}
+
+	query := `
+	SELECT date(clicked_at / 1000000000, 'unixepoch') AS day, count(*)
+	FROM clicks
+	WHERE short_url = ?
+	GROUP BY day
+	ORDER BY day;
+	`
+	rows, err := s.db.QueryContext(ctx, query, shortURL)
+	if err != nil {
+		return entity.ClickStats{}, fmt.Errorf("failed to get click stats for %s: %w", shortURL, err)
+	}
+	defer rows.Close()
+
+	stats := entity.ClickStats{ShortURL: shortURL}
+	for rows.Next() {
+		var (
+			day   string
+			daily entity.DailyClicks
+		)
+		if err := rows.Scan(&day, &daily.Clicks); err != nil {
+			return entity.ClickStats{}, fmt.Errorf("failed to scan click stats: %w", err)
+		}
+		if daily.Date, err = time.Parse(time.DateOnly, day); err != nil {
+			return entity.ClickStats{}, fmt.Errorf("failed to parse click day %q: %w", day, err)
+		}
+		stats.Total += daily.Clicks
+		stats.Daily = append(stats.Daily, daily)
+	}
+	if err := rows.Err(); err != nil {
+		return entity.ClickStats{}, fmt.Errorf("failed to read click stats for %s: %w", shortURL, err)
+	}
+	return stats, nil
+}
+
+// NextSequenceValue returns the next value of the short URL sequence.
+func (s *SQLiteStorage) NextSequenceValue(ctx context.Context) (int64, error) {
+	query := `
+	INSERT INTO sequences (name, value)
+	VALUES ('short_url', 1)
+	ON CONFLICT (name) DO UPDATE
+	SET value = value + 1
+	RETURNING value;
+	`
+	var value int64
+	if err := s.db.QueryRowContext(ctx, query).Scan(&value); err != nil {
+		return 0, fmt.Errorf("failed to get next sequence value: %w", err)
+	}
+	return value, nil
+}
+
+// GetCodeLength returns the stored code length, or 0 if none is stored.
+func (s *SQLiteStorage) GetCodeLength(ctx context.Context) (int, error) {
+	var length int
+	err := s.db.QueryRowContext(ctx, "SELECT CAST(value AS INTEGER) FROM settings WHERE key = 'code_length';").
+		Scan(&length)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return 0, nil
+		}
+		return 0, fmt.Errorf("failed to get code length: %w", err)
+	}
+	return length, nil
+}
+
+// SetCodeLength stores the code length unless a greater one is already stored.
+func (s *SQLiteStorage) SetCodeLength(ctx context.Context, length int) error {
+	query := `
+	INSERT INTO settings (key, value)
+	VALUES ('code_length', ?)
+	ON CONFLICT (key) DO UPDATE
+	SET value = excluded.value
+	WHERE CAST(settings.value AS INTEGER) < CAST(excluded.value AS INTEGER);
+	`
+	if _, err := s.db.ExecContext(ctx, query, strconv.Itoa(length)); err != nil {
+		return fmt.Errorf("failed to set code length: %w", err)
+	}
+	return nil
+}
+
+// nullUnixNano converts time to Unix nanoseconds, and zero time to NULL.
+func nullUnixNano(t time.Time) sql.NullInt64 {
+	if t.IsZero() {
+		return sql.NullInt64{}
+	}
+	return sql.NullInt64{Int64: t.UnixNano(), Valid: true}
+}
@@ -0,0 +1,50 @@
+package repository
+
+import (
+	"context"
+	"database/sql"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/radiophysiker/shortener_link/internal/entity"
+)
+
+func TestSQLiteStorageMigratesOnce(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "storage.db")
+	storage, err := NewSQLiteStorage(path)
+	require.NoError(t, err, "NewSQLiteStorage should not return an error")
+	require.NoError(t, storage.Close())
+
+	storage, err = NewSQLiteStorage(path)
+	require.NoError(t, err, "NewSQLiteStorage should reopen a migrated database")
+	defer storage.Close()
+	var version int
+	require.NoError(t, storage.db.QueryRowContext(context.Background(), "PRAGMA user_version;").Scan(&version))
+	assert.Equal(t, len(sqliteMigrations), version)
+}
+
+func TestSQLiteStorageKeepsURLsWhenRebuildingTable(t *testing.T) {
+	ctx := context.Background()
+	path := filepath.Join(t.TempDir(), "storage.db")
+	db, err := sql.Open("sqlite", "file:"+path)
+	require.NoError(t, err)
+	_, err = db.ExecContext(ctx, sqliteMigrations[0])
+	require.NoError(t, err)
+	_, err = db.ExecContext(ctx, "PRAGMA user_version = 1;")
+	require.NoError(t, err)
+	_, err = db.ExecContext(ctx, "INSERT INTO shortened_urls (short_url, full_url, user_id) VALUES ('short', 'full', 'user');")
+	require.NoError(t, err)
+	require.NoError(t, db.Close())
+
+	storage, err := NewSQLiteStorage(path)
+	require.NoError(t, err, "NewSQLiteStorage should migrate a database of the first schema version")
+	defer storage.Close()
+	url, err := storage.GetURL(ctx, "short")
+	require.NoError(t, err, "URLs should survive the table rebuild")
+	assert.Equal(t, entity.URL{ShortURL: "short", FullURL: "full", UserID: "user"}, url)
+	assert.Error(t, storage.Save(ctx, entity.URL{ShortURL: "another_short", FullURL: "full", UserID: "user"}),
+		"full URLs should stay unique after the table rebuild")
+}
@@ -0,0 +1,40 @@
+package usecases
+
+import (
+	"errors"
+	"fmt"
+
+	"github.com/radiophysiker/shortener_link/internal/utils"
+)
+
+const maxAliasLength = 64
+
+var (
+	ErrInvalidAlias  = errors.New("invalid alias")
+	ErrAliasReserved = errors.New("alias is reserved")
+	ErrAliasTaken    = errors.New("alias is already taken")
+)
+
+// reservedAliases are the first path segments used by the service's own routes.
+// Every new top-level route of the v1 router has to be added here.
+var reservedAliases = map[string]struct{}{
+	"api":     {},
+	"metrics": {},
+	"ping":    {},
+}
+
+// validateAlias checks that a custom short code can be used as a short URL.
+// Aliases are checked against the fixed URL-safe character set of utils.IsShortString rather than
+// the configured CODE_ALPHABET, so an alias may contain characters that generated codes never do.
+func validateAlias(alias string) error {
+	if len(alias) > maxAliasLength {
+		return fmt.Errorf("%w: must be at most %d characters long", ErrInvalidAlias, maxAliasLength)
+	}
+	if !utils.IsShortString(alias) {
+		return fmt.Errorf("%w: may only contain letters, digits, '-' and '_'", ErrInvalidAlias)
+	}
+	if _, reserved := reservedAliases[alias]; reserved {
+		return fmt.Errorf("%w: %s", ErrAliasReserved, alias)
+	}
+	return nil
+}
@@ -0,0 +1,34 @@
+package usecases
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestValidateAlias(t *testing.T) {
+	tests := []struct {
+		name    string
+		alias   string
+		wantErr error
+	}{
+		{name: "valid", alias: "spring-sale"},
+		{name: "max length", alias: strings.Repeat("a", maxAliasLength)},
+		{name: "too long", alias: strings.Repeat("a", maxAliasLength+1), wantErr: ErrInvalidAlias},
+		{name: "invalid characters", alias: "spring/sale", wantErr: ErrInvalidAlias},
+		{name: "reserved api", alias: "api", wantErr: ErrAliasReserved},
+		{name: "reserved ping", alias: "ping", wantErr: ErrAliasReserved},
+		{name: "reserved metrics", alias: "metrics", wantErr: ErrAliasReserved},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := validateAlias(tt.alias)
+			if tt.wantErr == nil {
+				assert.NoError(t, err)
+				return
+			}
+			assert.ErrorIs(t, err, tt.wantErr)
+		})
+	}
+}
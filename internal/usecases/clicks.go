@@ -0,0 +1,137 @@
+package usecases
+
+import (
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"sync"
+	"time"
+
+	"go.uber.org/zap"
+
+	"github.com/radiophysiker/shortener_link/internal/entity"
+)
+
+const (
+	clickBufferSize    = 1024
+	clickBatchSize     = 100
+	clickFlushInterval = time.Second
+	clickFlushTimeout  = 10 * time.Second
+)
+
+type ClickRepository interface {
+	SaveClicks(ctx context.Context, clicks []entity.Click) error
+}
+
+// ClickInfo describes the client that followed a short URL.
+type ClickInfo struct {
+	Referrer  string
+	UserAgent string
+	IP        string
+}
+
+// ClickRecorder records clicks in the background, so that redirects don't wait for the storage.
+// Clicks are buffered and flushed to the repository in batches; if the buffer is full, the click is dropped.
+type ClickRecorder struct {
+	repository ClickRepository
+	salt       []byte
+	clickCh    chan entity.Click
+
+	mu         sync.RWMutex
+	closed     bool
+	workerDone chan struct{}
+}
+
+func NewClickRecorder(re ClickRepository, salt string) *ClickRecorder {
+	r := &ClickRecorder{
+		repository: re,
+		salt:       []byte(salt),
+		clickCh:    make(chan entity.Click, clickBufferSize),
+		workerDone: make(chan struct{}),
+	}
+	go r.worker()
+	return r
+}
+
+// RecordClick schedules the click to be saved. It never blocks.
+func (r *ClickRecorder) RecordClick(shortURL string, info ClickInfo) {
+	click := entity.Click{
+		ShortURL:  shortURL,
+		ClickedAt: time.Now().UTC(),
+		Referrer:  info.Referrer,
+		UserAgent: info.UserAgent,
+		IPHash:    r.hashIP(info.IP),
+	}
+
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	if r.closed {
+		return
+	}
+	select {
+	case r.clickCh <- click:
+	default:
+		zap.L().Warn("click buffer is full, dropping click", zap.String("shortURL", shortURL))
+	}
+}
+
+// Close stops accepting new clicks and waits until the buffered ones are flushed.
+func (r *ClickRecorder) Close() error {
+	r.mu.Lock()
+	if r.closed {
+		r.mu.Unlock()
+		return nil
+	}
+	r.closed = true
+	close(r.clickCh)
+	r.mu.Unlock()
+
+	<-r.workerDone
+	return nil
+}
+
+func (r *ClickRecorder) hashIP(ip string) string {
+	if ip == "" {
+		return ""
+	}
+	mac := hmac.New(sha256.New, r.salt)
+	mac.Write([]byte(ip))
+	return hex.EncodeToString(mac.Sum(nil))
+}
+
+func (r *ClickRecorder) worker() {
+	defer close(r.workerDone)
+	ticker := time.NewTicker(clickFlushInterval)
+	defer ticker.Stop()
+
+	batch := make([]entity.Click, 0, clickBatchSize)
+	for {
+		select {
+		case click, ok := <-r.clickCh:
+			if !ok {
+				r.flush(batch)
+				return
+			}
+			batch = append(batch, click)
+			if len(batch) >= clickBatchSize {
+				r.flush(batch)
+				batch = batch[:0]
+			}
+		case <-ticker.C:
+			r.flush(batch)
+			batch = batch[:0]
+		}
+	}
+}
+
+func (r *ClickRecorder) flush(batch []entity.Click) {
+	if len(batch) == 0 {
+		return
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), clickFlushTimeout)
+	defer cancel()
+	if err := r.repository.SaveClicks(ctx, batch); err != nil {
+		zap.L().Error("cannot save clicks", zap.Error(err), zap.Int("count", len(batch)))
+	}
+}
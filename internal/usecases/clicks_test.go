@@ -0,0 +1,45 @@
+package usecases
+
+import (
+	"context"
+	"sync"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/radiophysiker/shortener_link/internal/entity"
+)
+
+type fakeClickRepository struct {
+	mu     sync.Mutex
+	clicks []entity.Click
+}
+
+func (r *fakeClickRepository) SaveClicks(ctx context.Context, clicks []entity.Click) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.clicks = append(r.clicks, clicks...)
+	return nil
+}
+
+func TestClickRecorder(t *testing.T) {
+	repo := &fakeClickRepository{}
+	recorder := NewClickRecorder(repo, "salt")
+
+	recorder.RecordClick("short", ClickInfo{Referrer: "https://ref", UserAgent: "agent", IP: "10.0.0.1"})
+	recorder.RecordClick("short", ClickInfo{IP: "10.0.0.1"})
+	recorder.RecordClick("other", ClickInfo{})
+	require.NoError(t, recorder.Close())
+	recorder.RecordClick("short", ClickInfo{})
+
+	require.Len(t, repo.clicks, 3, "clicks recorded before close should be flushed, later ones dropped")
+	first := repo.clicks[0]
+	assert.Equal(t, "short", first.ShortURL)
+	assert.Equal(t, "https://ref", first.Referrer)
+	assert.Equal(t, "agent", first.UserAgent)
+	assert.NotEmpty(t, first.IPHash)
+	assert.NotContains(t, first.IPHash, "10.0.0.1", "raw IP should not be stored")
+	assert.Equal(t, first.IPHash, repo.clicks[1].IPHash, "the same IP should have the same hash")
+	assert.Empty(t, repo.clicks[2].IPHash)
+}
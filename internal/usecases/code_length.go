@@ -0,0 +1,121 @@
+package usecases
+
+import (
+	"context"
+	"fmt"
+	"sync"
+
+	"go.uber.org/zap"
+
+	"github.com/radiophysiker/shortener_link/internal/config"
+	"github.com/radiophysiker/shortener_link/internal/metrics"
+)
+
+// collisionWindow is the number of code generation attempts the collision rate is measured over.
+const collisionWindow = 100
+
+type CodeLengthStore interface {
+	// GetCodeLength returns the stored code length, or 0 if none is stored.
+	GetCodeLength(ctx context.Context) (int, error)
+	// SetCodeLength stores the code length unless a greater one is already stored.
+	SetCodeLength(ctx context.Context, length int) error
+}
+
+// CodeLengthController decides how long generated codes are. It starts with the greater of
+// the configured and the stored length, and grows the length when the keyspace gets crowded:
+// either when the collision rate over the last attempts exceeds the threshold,
+// or when all attempts to generate a free code of the current length failed.
+type CodeLengthController struct {
+	store     CodeLengthStore
+	threshold float64
+	maxLength int
+
+	mu         sync.Mutex
+	length     int
+	attempts   int
+	collisions int
+}
+
+func NewCodeLengthController(ctx context.Context, store CodeLengthStore, cfg *config.Config) (*CodeLengthController, error) {
+	stored, err := store.GetCodeLength(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("failed to load code length: %w", err)
+	}
+	c := &CodeLengthController{
+		store:     store,
+		threshold: cfg.CodeGrowthThreshold,
+		maxLength: max(cfg.CodeMaxLength, cfg.CodeLength),
+		length:    max(cfg.CodeLength, stored),
+	}
+	metrics.CodeLength.Set(float64(c.length))
+	return c, nil
+}
+
+// Length returns the current code length.
+func (c *CodeLengthController) Length() int {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.length
+}
+
+// Observe records the outcome of an attempt to save a generated code
+// and grows the code length if too many attempts collide.
+func (c *CodeLengthController) Observe(ctx context.Context, collided bool) {
+	c.mu.Lock()
+	c.attempts++
+	if collided {
+		c.collisions++
+	}
+	if c.attempts < collisionWindow {
+		c.mu.Unlock()
+		return
+	}
+	rate := float64(c.collisions) / float64(c.attempts)
+	c.attempts, c.collisions = 0, 0
+	from := c.length
+	c.mu.Unlock()
+	if rate > c.threshold {
+		c.grow(ctx, from, fmt.Sprintf("collision rate %.2f exceeded %.2f", rate, c.threshold))
+	}
+}
+
+// Grow increases the code length after all attempts with the given length failed.
+// It reports whether codes longer than from can be generated now.
+func (c *CodeLengthController) Grow(ctx context.Context, from int) bool {
+	return c.grow(ctx, from, fmt.Sprintf("no free code of length %d found in %d attempts", from, maxNumberAttempts))
+}
+
+// grow increases the code length past from, unless someone else has already grown it.
+// The new length is stored before it is used, and c.mu is not held while it is stored,
+// so that a slow store does not hold up code generation.
+func (c *CodeLengthController) grow(ctx context.Context, from int, reason string) bool {
+	c.mu.Lock()
+	length := c.length
+	c.mu.Unlock()
+	if length > from {
+		return true
+	}
+	if from >= c.maxLength {
+		zap.L().Error("short code length cannot grow any further",
+			zap.Int("length", from), zap.String("reason", reason))
+		return false
+	}
+
+	next := from + 1
+	if err := c.store.SetCodeLength(ctx, next); err != nil {
+		zap.L().Error("cannot persist short code length", zap.Error(err), zap.Int("length", next))
+	}
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if c.length >= next {
+		// Someone else has grown it in the meantime.
+		return true
+	}
+	c.length = next
+	c.attempts, c.collisions = 0, 0
+	metrics.CodeLength.Set(float64(c.length))
+	zap.L().Info("short code length increased",
+		zap.Int("length", c.length), zap.String("reason", reason))
+	return true
+}
@@ -0,0 +1,191 @@
+package usecases
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/radiophysiker/shortener_link/internal/config"
+	"github.com/radiophysiker/shortener_link/internal/entity"
+)
+
+type fakeCodeLengthStore struct {
+	length int
+}
+
+func (s *fakeCodeLengthStore) GetCodeLength(ctx context.Context) (int, error) {
+	return s.length, nil
+}
+
+func (s *fakeCodeLengthStore) SetCodeLength(ctx context.Context, length int) error {
+	s.length = max(s.length, length)
+	return nil
+}
+
+func newTestCodeLengthController(t *testing.T, store CodeLengthStore) *CodeLengthController {
+	cfg := &config.Config{CodeLength: 6, CodeMaxLength: 8, CodeGrowthThreshold: 0.1}
+	c, err := NewCodeLengthController(context.Background(), store, cfg)
+	require.NoError(t, err, "NewCodeLengthController should not return an error")
+	return c
+}
+
+func TestCodeLengthStartsFromStoredLength(t *testing.T) {
+	c := newTestCodeLengthController(t, &fakeCodeLengthStore{length: 7})
+	assert.Equal(t, 7, c.Length(), "stored length should win over the configured one")
+
+	c = newTestCodeLengthController(t, &fakeCodeLengthStore{length: 3})
+	assert.Equal(t, 6, c.Length(), "configured length should win over a smaller stored one")
+}
+
+func TestCodeLengthGrowsOnCollisionRate(t *testing.T) {
+	store := &fakeCodeLengthStore{}
+	c := newTestCodeLengthController(t, store)
+
+	for i := range collisionWindow {
+		c.Observe(context.Background(), i%20 == 0)
+	}
+	assert.Equal(t, 6, c.Length(), "collision rate below the threshold should not grow the length")
+
+	for i := range collisionWindow {
+		c.Observe(context.Background(), i%5 == 0)
+	}
+	assert.Equal(t, 7, c.Length(), "collision rate above the threshold should grow the length")
+	assert.Equal(t, 7, store.length, "grown length should be persisted")
+}
+
+func TestCodeLengthGrowStopsAtMax(t *testing.T) {
+	c := newTestCodeLengthController(t, &fakeCodeLengthStore{})
+	assert.True(t, c.Grow(context.Background(), 6))
+	assert.True(t, c.Grow(context.Background(), 6), "growing from an outdated length should report the length has grown")
+	assert.Equal(t, 7, c.Length())
+	assert.True(t, c.Grow(context.Background(), 7))
+	assert.False(t, c.Grow(context.Background(), 8), "length should not grow beyond the maximum")
+	assert.Equal(t, 8, c.Length())
+}
+
+// blockingCodeLengthStore holds SetCodeLength until release is closed.
+type blockingCodeLengthStore struct {
+	fakeCodeLengthStore
+	setting chan int
+	release chan struct{}
+}
+
+func (s *blockingCodeLengthStore) SetCodeLength(ctx context.Context, length int) error {
+	s.setting <- length
+	<-s.release
+	return s.fakeCodeLengthStore.SetCodeLength(ctx, length)
+}
+
+func TestCodeLengthIsNotLockedWhilePersisted(t *testing.T) {
+	store := &blockingCodeLengthStore{setting: make(chan int, 1), release: make(chan struct{})}
+	c := newTestCodeLengthController(t, store)
+
+	grown := make(chan bool)
+	go func() {
+		grown <- c.Grow(context.Background(), 6)
+	}()
+	assert.Equal(t, 7, <-store.setting, "the grown length should be persisted")
+
+	lengthRead := make(chan int)
+	go func() {
+		c.Observe(context.Background(), true)
+		lengthRead <- c.Length()
+	}()
+	select {
+	case length := <-lengthRead:
+		assert.Equal(t, 6, length, "the grown length should not be used before it is persisted")
+	case <-time.After(time.Second):
+		t.Fatal("the controller should not be locked while the length is persisted")
+	}
+
+	close(store.release)
+	assert.True(t, <-grown)
+	assert.Equal(t, 7, c.Length())
+	assert.Equal(t, 7, store.length)
+}
+
+// crowdedRepository rejects every generated code shorter than minLength as already taken.
+type crowdedRepository struct {
+	URLRepository
+	minLength int
+	saved     []entity.URL
+}
+
+func (r *crowdedRepository) Save(ctx context.Context, url entity.URL) error {
+	if len(url.ShortURL) < r.minLength {
+		return ErrURLGeneratedBefore
+	}
+	r.saved = append(r.saved, url)
+	return nil
+}
+
+func (r *crowdedRepository) SaveBatch(ctx context.Context, urls []entity.URL) ([]SaveResult, error) {
+	results := make([]SaveResult, 0, len(urls))
+	for _, url := range urls {
+		if len(url.ShortURL) < r.minLength {
+			results = append(results, SaveResult{Status: SaveShortURLTaken})
+			continue
+		}
+		r.saved = append(r.saved, url)
+		results = append(results, SaveResult{Status: SaveCreated, ShortURL: url.ShortURL})
+	}
+	return results, nil
+}
+
+type fixedLengthGenerator struct{}
+
+func (fixedLengthGenerator) Generate(ctx context.Context, req CodeRequest) (string, error) {
+	code := make([]byte, req.Length)
+	for i := range code {
+		code[i] = 'a'
+	}
+	return string(code), nil
+}
+
+func TestCreateShortURLGrowsLengthWhenAttemptsAreExhausted(t *testing.T) {
+	store := &fakeCodeLengthStore{}
+	cfg := &config.Config{CodeLength: 6, CodeMaxLength: 8, CodeGrowthThreshold: 0.1}
+	repo := &crowdedRepository{minLength: 7}
+	us := NewURLShortener(repo, fixedLengthGenerator{}, newTestCodeLengthController(t, store), cfg)
+
+	shortURL, err := us.CreateShortURL(context.Background(), "https://example.com", CreateOptions{})
+	require.NoError(t, err, "CreateShortURL should succeed with a longer code")
+	assert.Len(t, shortURL, 7)
+	assert.Equal(t, 7, store.length)
+
+	repo.minLength = 100
+	_, err = us.CreateShortURL(context.Background(), "https://example.com", CreateOptions{})
+	assert.ErrorIs(t, err, ErrFailedToGenerateShortURL, "CreateShortURL should fail once the maximum length is reached")
+}
+
+func TestCreateBatchURLsRetriesTakenCodes(t *testing.T) {
+	store := &fakeCodeLengthStore{}
+	cfg := &config.Config{CodeLength: 6, CodeMaxLength: 8, CodeGrowthThreshold: 0.1}
+	repo := &crowdedRepository{minLength: 7}
+	us := NewURLShortener(repo, fixedLengthGenerator{}, newTestCodeLengthController(t, store), cfg)
+
+	items, err := us.CreateBatchURLs(context.Background(), []BatchItem{
+		{CorrelationID: "1", OriginalURL: "https://example.com/1"},
+		{CorrelationID: "2", OriginalURL: "https://example.com/2", Alias: "short"},
+		{CorrelationID: "3", OriginalURL: "https://example.com/3", Alias: "long_alias"},
+	})
+	require.NoError(t, err, "CreateBatchURLs should not fail because of single items")
+	require.Len(t, items, 3)
+
+	assert.NoError(t, items[0].Err)
+	assert.Len(t, items[0].ShortURL, 7, "a taken generated code should be retried with a longer one")
+	assert.ErrorIs(t, items[1].Err, ErrAliasTaken, "a taken alias should fail its item only")
+	assert.NoError(t, items[2].Err)
+	assert.Equal(t, "long_alias", items[2].ShortURL)
+	assert.Len(t, repo.saved, 2)
+
+	repo.minLength = 100
+	items, err = us.CreateBatchURLs(context.Background(), []BatchItem{
+		{CorrelationID: "1", OriginalURL: "https://example.com/4"},
+	})
+	require.NoError(t, err)
+	assert.ErrorIs(t, items[0].Err, ErrFailedToGenerateShortURL, "the item should fail once the maximum length is reached")
+}
@@ -0,0 +1,140 @@
+package usecases
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sync"
+	"time"
+
+	"go.uber.org/zap"
+
+	"github.com/radiophysiker/shortener_link/internal/auth"
+	"github.com/radiophysiker/shortener_link/internal/entity"
+)
+
+const (
+	deleteBatchSize = 100
+	// deleteQueueSize is how many deletion requests may wait for the worker. Requests beyond it are rejected,
+	// so that a burst of deletions cannot pile up without bound.
+	deleteQueueSize     = 1000
+	deleteFlushInterval = time.Second
+	deleteFlushTimeout  = 10 * time.Second
+)
+
+var (
+	ErrDeleterClosed   = errors.New("deleter is closed")
+	ErrDeleteQueueFull = errors.New("too many deletions are pending")
+)
+
+type URLDeleteRepository interface {
+	DeleteURLs(ctx context.Context, urls []entity.URL) error
+}
+
+// URLDeleter marks URLs as deleted in the background.
+// Every request feeds its short URLs into a shared bounded channel (fan-in),
+// and a single worker flushes them to the repository in batches.
+type URLDeleter struct {
+	repository URLDeleteRepository
+	deleteCh   chan []entity.URL
+
+	// mu guards closed and keeps deleteCh open while requests are put into it.
+	mu         sync.RWMutex
+	closed     bool
+	workerDone chan struct{}
+}
+
+func NewURLDeleter(re URLDeleteRepository) *URLDeleter {
+	d := &URLDeleter{
+		repository: re,
+		deleteCh:   make(chan []entity.URL, deleteQueueSize),
+		workerDone: make(chan struct{}),
+	}
+	go d.worker()
+	return d
+}
+
+// DeleteUserURLs schedules deletion of the short URLs owned by the user from the context
+// and returns without waiting for the deletion to happen.
+// ErrDeleteQueueFull is returned if too many deletions are already waiting.
+func (d *URLDeleter) DeleteUserURLs(ctx context.Context, shortURLs []string) error {
+	userID := auth.UserIDFromContext(ctx)
+	if userID == "" {
+		return ErrEmptyUserID
+	}
+	if len(shortURLs) == 0 {
+		return ErrEmptyBatch
+	}
+	if len(shortURLs) > maxBatchSize {
+		return fmt.Errorf("%w: must contain at most %d short URLs", ErrBatchTooLarge, maxBatchSize)
+	}
+	urls := make([]entity.URL, 0, len(shortURLs))
+	for _, shortURL := range shortURLs {
+		urls = append(urls, entity.URL{ShortURL: shortURL, UserID: userID})
+	}
+
+	d.mu.RLock()
+	defer d.mu.RUnlock()
+	if d.closed {
+		return ErrDeleterClosed
+	}
+	select {
+	case d.deleteCh <- urls:
+		return nil
+	default:
+		return ErrDeleteQueueFull
+	}
+}
+
+// Close stops accepting new deletions and waits until all scheduled ones are flushed.
+func (d *URLDeleter) Close() error {
+	d.mu.Lock()
+	if d.closed {
+		d.mu.Unlock()
+		return nil
+	}
+	d.closed = true
+	close(d.deleteCh)
+	d.mu.Unlock()
+
+	<-d.workerDone
+	return nil
+}
+
+func (d *URLDeleter) worker() {
+	defer close(d.workerDone)
+	ticker := time.NewTicker(deleteFlushInterval)
+	defer ticker.Stop()
+
+	batch := make([]entity.URL, 0, deleteBatchSize)
+	for {
+		select {
+		case urls, ok := <-d.deleteCh:
+			if !ok {
+				d.flush(batch)
+				return
+			}
+			for _, url := range urls {
+				batch = append(batch, url)
+				if len(batch) >= deleteBatchSize {
+					d.flush(batch)
+					batch = batch[:0]
+				}
+			}
+		case <-ticker.C:
+			d.flush(batch)
+			batch = batch[:0]
+		}
+	}
+}
+
+func (d *URLDeleter) flush(batch []entity.URL) {
+	if len(batch) == 0 {
+		return
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), deleteFlushTimeout)
+	defer cancel()
+	if err := d.repository.DeleteURLs(ctx, batch); err != nil {
+		zap.L().Error("cannot delete URLs", zap.Error(err), zap.Int("count", len(batch)))
+	}
+}
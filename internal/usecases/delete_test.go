@@ -0,0 +1,107 @@
+package usecases
+
+import (
+	"context"
+	"fmt"
+	"sync"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/radiophysiker/shortener_link/internal/auth"
+	"github.com/radiophysiker/shortener_link/internal/entity"
+)
+
+type fakeDeleteRepository struct {
+	mu      sync.Mutex
+	deleted []entity.URL
+}
+
+func (r *fakeDeleteRepository) DeleteURLs(ctx context.Context, urls []entity.URL) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.deleted = append(r.deleted, urls...)
+	return nil
+}
+
+func TestURLDeleterFlushesOnClose(t *testing.T) {
+	repo := &fakeDeleteRepository{}
+	deleter := NewURLDeleter(repo)
+
+	var wg sync.WaitGroup
+	for _, userID := range []string{"user1", "user2", "user3"} {
+		wg.Add(1)
+		go func(userID string) {
+			defer wg.Done()
+			ctx := auth.WithUserID(context.Background(), userID)
+			err := deleter.DeleteUserURLs(ctx, []string{userID + "-a", userID + "-b"})
+			assert.NoError(t, err)
+		}(userID)
+	}
+	wg.Wait()
+	require.NoError(t, deleter.Close())
+
+	assert.Len(t, repo.deleted, 6, "all scheduled URLs should be flushed on close")
+	assert.Contains(t, repo.deleted, entity.URL{ShortURL: "user2-b", UserID: "user2"})
+}
+
+func TestURLDeleterRejects(t *testing.T) {
+	deleter := NewURLDeleter(&fakeDeleteRepository{})
+	ctx := auth.WithUserID(context.Background(), "user")
+
+	err := deleter.DeleteUserURLs(context.Background(), []string{"short"})
+	assert.ErrorIs(t, err, ErrEmptyUserID)
+
+	err = deleter.DeleteUserURLs(ctx, nil)
+	assert.ErrorIs(t, err, ErrEmptyBatch)
+
+	require.NoError(t, deleter.Close())
+	err = deleter.DeleteUserURLs(ctx, []string{"short"})
+	assert.ErrorIs(t, err, ErrDeleterClosed)
+}
+
+// blockingDeleteRepository holds DeleteURLs until release is closed. flushing is closed on the first call.
+type blockingDeleteRepository struct {
+	fakeDeleteRepository
+	flushOnce sync.Once
+	flushing  chan struct{}
+	release   chan struct{}
+}
+
+func (r *blockingDeleteRepository) DeleteURLs(ctx context.Context, urls []entity.URL) error {
+	r.flushOnce.Do(func() { close(r.flushing) })
+	<-r.release
+	return r.fakeDeleteRepository.DeleteURLs(ctx, urls)
+}
+
+func TestURLDeleterRejectsWhenQueueIsFull(t *testing.T) {
+	repo := &blockingDeleteRepository{flushing: make(chan struct{}), release: make(chan struct{})}
+	deleter := NewURLDeleter(repo)
+	ctx := auth.WithUserID(context.Background(), "user")
+
+	// A full batch makes the worker flush it and wait for the repository.
+	batch := make([]string, deleteBatchSize)
+	for i := range batch {
+		batch[i] = fmt.Sprintf("short%d", i)
+	}
+	require.NoError(t, deleter.DeleteUserURLs(ctx, batch))
+	<-repo.flushing
+	for range deleteQueueSize {
+		require.NoError(t, deleter.DeleteUserURLs(ctx, []string{"queued"}))
+	}
+	assert.ErrorIs(t, deleter.DeleteUserURLs(ctx, []string{"rejected"}), ErrDeleteQueueFull)
+
+	close(repo.release)
+	require.NoError(t, deleter.Close())
+	assert.Len(t, repo.deleted, deleteBatchSize+deleteQueueSize, "the queued deletions should be flushed on close")
+	assert.NotContains(t, repo.deleted, entity.URL{ShortURL: "rejected", UserID: "user"})
+}
+
+func TestURLDeleterRejectsTooManyURLs(t *testing.T) {
+	deleter := NewURLDeleter(&fakeDeleteRepository{})
+	defer deleter.Close()
+	ctx := auth.WithUserID(context.Background(), "user")
+
+	assert.ErrorIs(t, deleter.DeleteUserURLs(ctx, make([]string, maxBatchSize+1)), ErrBatchTooLarge)
+}
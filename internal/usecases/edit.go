@@ -0,0 +1,79 @@
+package usecases
+
+import (
+	"context"
+	"errors"
+	"fmt"
+
+	"github.com/radiophysiker/shortener_link/internal/auth"
+	"github.com/radiophysiker/shortener_link/internal/entity"
+)
+
+var ErrNotURLOwner = errors.New("URL belongs to another user")
+
+type URLEditRepository interface {
+	GetURL(ctx context.Context, shortURL string) (entity.URL, error)
+	// UpdateFullURL changes the full URL of url.ShortURL to url.FullURL and records the revision,
+	// provided that the short URL belongs to url.UserID.
+	UpdateFullURL(ctx context.Context, url entity.URL) error
+	// GetURLRevisions returns the revisions of the short URL, oldest first.
+	GetURLRevisions(ctx context.Context, shortURL string) ([]entity.URLRevision, error)
+}
+
+// URLEditor changes where short URLs redirect to.
+type URLEditor struct {
+	repository URLEditRepository
+}
+
+func NewURLEditor(re URLEditRepository) *URLEditor {
+	return &URLEditor{repository: re}
+}
+
+// UpdateFullURL makes the short URL owned by the user from the context redirect to fullURL.
+// An edited short URL is no longer returned for its full URL when the same full URL is shortened again.
+func (e *URLEditor) UpdateFullURL(ctx context.Context, shortURL, fullURL string) error {
+	userID := auth.UserIDFromContext(ctx)
+	if userID == "" {
+		return ErrEmptyUserID
+	}
+	if shortURL == "" {
+		return ErrEmptyShortURL
+	}
+	if fullURL == "" {
+		return ErrEmptyFullURL
+	}
+	err := e.repository.UpdateFullURL(ctx, entity.URL{ShortURL: shortURL, FullURL: fullURL, UserID: userID})
+	if err != nil {
+		if errors.Is(err, ErrURLNotFound) || errors.Is(err, ErrNotURLOwner) || errors.Is(err, ErrURLDeleted) {
+			return err
+		}
+		return fmt.Errorf("failed to update URL: %w", err)
+	}
+	return nil
+}
+
+// GetURLHistory returns the short URL owned by the user from the context along with its revisions, oldest first.
+func (e *URLEditor) GetURLHistory(ctx context.Context, shortURL string) (entity.URL, []entity.URLRevision, error) {
+	userID := auth.UserIDFromContext(ctx)
+	if userID == "" {
+		return entity.URL{}, nil, ErrEmptyUserID
+	}
+	if shortURL == "" {
+		return entity.URL{}, nil, ErrEmptyShortURL
+	}
+	url, err := e.repository.GetURL(ctx, shortURL)
+	if err != nil {
+		if errors.Is(err, ErrURLNotFound) {
+			return entity.URL{}, nil, err
+		}
+		return entity.URL{}, nil, fmt.Errorf("failed to get URL: %w", err)
+	}
+	if url.UserID != userID {
+		return entity.URL{}, nil, fmt.Errorf("%w: %s", ErrNotURLOwner, shortURL)
+	}
+	revisions, err := e.repository.GetURLRevisions(ctx, shortURL)
+	if err != nil {
+		return entity.URL{}, nil, fmt.Errorf("failed to get URL revisions: %w", err)
+	}
+	return url, revisions, nil
+}
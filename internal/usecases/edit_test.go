@@ -0,0 +1,66 @@
+package usecases
+
+import (
+	"context"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/radiophysiker/shortener_link/internal/auth"
+	"github.com/radiophysiker/shortener_link/internal/entity"
+)
+
+type fakeEditRepository struct {
+	url       entity.URL
+	updated   []entity.URL
+	revisions []entity.URLRevision
+}
+
+func (r *fakeEditRepository) GetURL(ctx context.Context, shortURL string) (entity.URL, error) {
+	if shortURL != r.url.ShortURL {
+		return entity.URL{}, ErrURLNotFound
+	}
+	return r.url, nil
+}
+
+func (r *fakeEditRepository) UpdateFullURL(ctx context.Context, url entity.URL) error {
+	r.updated = append(r.updated, url)
+	return nil
+}
+
+func (r *fakeEditRepository) GetURLRevisions(ctx context.Context, shortURL string) ([]entity.URLRevision, error) {
+	return r.revisions, nil
+}
+
+func TestUpdateFullURL(t *testing.T) {
+	repo := &fakeEditRepository{}
+	editor := NewURLEditor(repo)
+
+	err := editor.UpdateFullURL(context.Background(), "short", "https://example.com")
+	assert.ErrorIs(t, err, ErrEmptyUserID, "an anonymous request should be rejected")
+
+	ctx := auth.WithUserID(context.Background(), "user")
+	assert.ErrorIs(t, editor.UpdateFullURL(ctx, "short", ""), ErrEmptyFullURL)
+	require.NoError(t, editor.UpdateFullURL(ctx, "short", "https://example.com"))
+	assert.Equal(t, []entity.URL{{ShortURL: "short", FullURL: "https://example.com", UserID: "user"}}, repo.updated,
+		"the edit should be made on behalf of the user from the context")
+}
+
+func TestGetURLHistoryChecksOwner(t *testing.T) {
+	repo := &fakeEditRepository{
+		url:       entity.URL{ShortURL: "short", FullURL: "https://example.com/new", UserID: "owner"},
+		revisions: []entity.URLRevision{{ShortURL: "short", PreviousFullURL: "https://example.com", FullURL: "https://example.com/new"}},
+	}
+	editor := NewURLEditor(repo)
+
+	_, _, err := editor.GetURLHistory(auth.WithUserID(context.Background(), "another_user"), "short")
+	assert.ErrorIs(t, err, ErrNotURLOwner, "the history should only be shown to the owner")
+	_, _, err = editor.GetURLHistory(auth.WithUserID(context.Background(), "owner"), "unknown")
+	assert.ErrorIs(t, err, ErrURLNotFound)
+
+	url, revisions, err := editor.GetURLHistory(auth.WithUserID(context.Background(), "owner"), "short")
+	require.NoError(t, err)
+	assert.Equal(t, repo.url, url)
+	assert.Equal(t, repo.revisions, revisions)
+}
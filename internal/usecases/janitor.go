@@ -0,0 +1,82 @@
+package usecases
+
+import (
+	"context"
+	"time"
+
+	"go.uber.org/zap"
+)
+
+type URLPurgeRepository interface {
+	PurgeExpiredURLs(ctx context.Context, before time.Time) (int64, error)
+}
+
+// ExpiredURLJanitor periodically purges URLs that have been expired for longer than the retention period,
+// so that they still answer 410 Gone for a while and then stop taking up space.
+type ExpiredURLJanitor struct {
+	repository URLPurgeRepository
+	interval   time.Duration
+	retention  time.Duration
+
+	stop context.CancelFunc
+	done chan struct{}
+}
+
+func NewExpiredURLJanitor(re URLPurgeRepository, interval, retention time.Duration) *ExpiredURLJanitor {
+	return &ExpiredURLJanitor{
+		repository: re,
+		interval:   interval,
+		retention:  retention,
+	}
+}
+
+// Start runs the janitor in the background until Close is called.
+func (j *ExpiredURLJanitor) Start() {
+	ctx, stop := context.WithCancel(context.Background())
+	j.stop = stop
+	j.done = make(chan struct{})
+	go func() {
+		defer close(j.done)
+		j.Run(ctx)
+	}()
+}
+
+// Close stops a started janitor and waits until a purge in progress is over,
+// so that the repository can be closed right after it.
+func (j *ExpiredURLJanitor) Close() error {
+	if j.stop == nil {
+		return nil
+	}
+	j.stop()
+	<-j.done
+	return nil
+}
+
+// Run purges expired URLs every interval until ctx is done.
+func (j *ExpiredURLJanitor) Run(ctx context.Context) {
+	if j.interval <= 0 {
+		zap.L().Info("expired URL janitor is disabled")
+		return
+	}
+	ticker := time.NewTicker(j.interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			j.purge(ctx)
+		}
+	}
+}
+
+func (j *ExpiredURLJanitor) purge(ctx context.Context) {
+	purged, err := j.repository.PurgeExpiredURLs(ctx, time.Now().Add(-j.retention))
+	if err != nil {
+		zap.L().Error("cannot purge expired URLs", zap.Error(err))
+		return
+	}
+	if purged > 0 {
+		zap.L().Info("purged expired URLs", zap.Int64("count", purged))
+	}
+}
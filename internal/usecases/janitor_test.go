@@ -0,0 +1,50 @@
+package usecases
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// blockingPurgeRepository signals when a purge starts and blocks it until release is closed.
+type blockingPurgeRepository struct {
+	started chan struct{}
+	release chan struct{}
+}
+
+func (r *blockingPurgeRepository) PurgeExpiredURLs(ctx context.Context, before time.Time) (int64, error) {
+	select {
+	case r.started <- struct{}{}:
+	default:
+	}
+	<-r.release
+	return 0, nil
+}
+
+func TestExpiredURLJanitorCloseWaitsForPurge(t *testing.T) {
+	repo := &blockingPurgeRepository{started: make(chan struct{}, 1), release: make(chan struct{})}
+	janitor := NewExpiredURLJanitor(repo, time.Millisecond, time.Hour)
+	janitor.Start()
+	<-repo.started
+
+	closed := make(chan struct{})
+	go func() {
+		defer close(closed)
+		assert.NoError(t, janitor.Close())
+	}()
+	select {
+	case <-closed:
+		t.Fatal("Close should wait for the purge in progress")
+	case <-time.After(50 * time.Millisecond):
+	}
+	close(repo.release)
+	<-closed
+}
+
+func TestExpiredURLJanitorCloseWithoutStart(t *testing.T) {
+	janitor := NewExpiredURLJanitor(&blockingPurgeRepository{}, time.Minute, time.Hour)
+	require.NoError(t, janitor.Close(), "closing a janitor that has not been started should do nothing")
+}
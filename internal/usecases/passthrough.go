@@ -0,0 +1,90 @@
+package usecases
+
+import (
+	"errors"
+	"fmt"
+	"net/url"
+	"path"
+	"strings"
+
+	"github.com/radiophysiker/shortener_link/internal/entity"
+)
+
+var ErrInvalidPassthrough = errors.New("invalid passthrough mode")
+
+// normalizePassthrough checks the passthrough mode of a short URL being created.
+// PassthroughNone is stored as an empty mode.
+func normalizePassthrough(mode entity.PassthroughMode) (entity.PassthroughMode, error) {
+	switch mode {
+	case "", entity.PassthroughNone:
+		return "", nil
+	case entity.PassthroughQuery, entity.PassthroughPathAndQuery:
+		return mode, nil
+	}
+	return "", fmt.Errorf("%w: %s, must be one of %s, %s or %s", ErrInvalidPassthrough, mode,
+		entity.PassthroughNone, entity.PassthroughQuery, entity.PassthroughPathAndQuery)
+}
+
+// RedirectLocation returns the URL a request to the short URL redirects to.
+// pathSuffix is the request path following the short code, rawQuery is the request query string.
+// They are carried over to the full URL as the passthrough mode of the URL allows:
+//   - pathSuffix is appended to the path of the full URL. Dot segments are resolved within the suffix,
+//     so they cannot climb above the path of the full URL;
+//   - the request query parameters are appended to the query of the full URL, in the request order.
+//     A parameter the full URL already has keeps the value set in the full URL, the request values are dropped;
+//   - the fragment of the full URL is kept. If it has none, clients keep the fragment they requested.
+//
+// A request with a path suffix is ErrURLNotFound unless the URL passes the path through.
+func RedirectLocation(u entity.URL, pathSuffix, rawQuery string) (string, error) {
+	passPath := u.Passthrough == entity.PassthroughPathAndQuery
+	passQuery := passPath || u.Passthrough == entity.PassthroughQuery
+	if pathSuffix != "" && !passPath {
+		return "", fmt.Errorf("%w for: %s/%s", ErrURLNotFound, u.ShortURL, pathSuffix)
+	}
+	if pathSuffix == "" && (rawQuery == "" || !passQuery) {
+		return u.FullURL, nil
+	}
+
+	location, err := url.Parse(u.FullURL)
+	if err != nil {
+		return "", fmt.Errorf("cannot parse full URL of %s: %w", u.ShortURL, err)
+	}
+	if pathSuffix != "" {
+		suffix := path.Clean("/" + pathSuffix)
+		if strings.HasSuffix(pathSuffix, "/") && suffix != "/" {
+			suffix += "/"
+		}
+		location = location.JoinPath(suffix)
+	}
+	location.RawQuery = mergeQuery(location.RawQuery, rawQuery)
+	return location.String(), nil
+}
+
+// mergeQuery appends the parameters of the request query to the full URL query, skipping those the full URL has.
+// The parameters are copied as they are, without re-encoding.
+func mergeQuery(fullURLQuery, requestQuery string) string {
+	if requestQuery == "" {
+		return fullURLQuery
+	}
+	// ParseQuery returns the parameters it could parse along with the error, a malformed one is just ignored.
+	own, _ := url.ParseQuery(fullURLQuery)
+	var params []string
+	if fullURLQuery != "" {
+		params = append(params, fullURLQuery)
+	}
+	for _, param := range strings.Split(requestQuery, "&") {
+		if param == "" {
+			continue
+		}
+		rawKey, _, _ := strings.Cut(param, "=")
+		key, err := url.QueryUnescape(rawKey)
+		if err != nil {
+			continue
+		}
+		if _, exists := own[key]; exists {
+			continue
+		}
+		params = append(params, param)
+	}
+	return strings.Join(params, "&")
+}
@@ -0,0 +1,103 @@
+package usecases
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/radiophysiker/shortener_link/internal/entity"
+)
+
+func TestRedirectLocation(t *testing.T) {
+	tests := []struct {
+		name        string
+		fullURL     string
+		passthrough entity.PassthroughMode
+		pathSuffix  string
+		rawQuery    string
+		want        string
+		wantErr     error
+	}{
+		{
+			name:     "none ignores the query",
+			fullURL:  "https://example.com/docs?a=1",
+			rawQuery: "utm_source=x",
+			want:     "https://example.com/docs?a=1",
+		},
+		{
+			name:       "none rejects a path",
+			fullURL:    "https://example.com/docs",
+			pathSuffix: "page",
+			wantErr:    ErrURLNotFound,
+		},
+		{
+			name:        "query is appended",
+			fullURL:     "https://example.com/docs",
+			passthrough: entity.PassthroughQuery,
+			rawQuery:    "utm_source=x&q=a%20b",
+			want:        "https://example.com/docs?utm_source=x&q=a%20b",
+		},
+		{
+			name:        "full URL parameters win",
+			fullURL:     "https://example.com/docs?utm_source=own&a=1#top",
+			passthrough: entity.PassthroughQuery,
+			rawQuery:    "utm_source=x&b=2&b=3&a=4",
+			want:        "https://example.com/docs?utm_source=own&a=1&b=2&b=3#top",
+		},
+		{
+			name:        "query only rejects a path",
+			fullURL:     "https://example.com/docs",
+			passthrough: entity.PassthroughQuery,
+			pathSuffix:  "page",
+			wantErr:     ErrURLNotFound,
+		},
+		{
+			name:        "path and query",
+			fullURL:     "https://example.com/docs/?lang=en#intro",
+			passthrough: entity.PassthroughPathAndQuery,
+			pathSuffix:  "guide/page",
+			rawQuery:    "utm_source=x",
+			want:        "https://example.com/docs/guide/page?lang=en&utm_source=x#intro",
+		},
+		{
+			name:        "trailing slash is kept",
+			fullURL:     "https://example.com/docs",
+			passthrough: entity.PassthroughPathAndQuery,
+			pathSuffix:  "guide/",
+			want:        "https://example.com/docs/guide/",
+		},
+		{
+			name:        "dot segments stay within the full URL path",
+			fullURL:     "https://example.com/docs",
+			passthrough: entity.PassthroughPathAndQuery,
+			pathSuffix:  "../../admin",
+			want:        "https://example.com/docs/admin",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			u := entity.URL{ShortURL: "short", FullURL: tt.fullURL, Passthrough: tt.passthrough}
+			got, err := RedirectLocation(u, tt.pathSuffix, tt.rawQuery)
+			if tt.wantErr != nil {
+				assert.ErrorIs(t, err, tt.wantErr)
+				return
+			}
+			require.NoError(t, err)
+			assert.Equal(t, tt.want, got)
+		})
+	}
+}
+
+func TestNormalizePassthrough(t *testing.T) {
+	for _, mode := range []entity.PassthroughMode{"", entity.PassthroughNone} {
+		got, err := normalizePassthrough(mode)
+		require.NoError(t, err)
+		assert.Empty(t, got, "none should be stored as an empty mode")
+	}
+	got, err := normalizePassthrough(entity.PassthroughPathAndQuery)
+	require.NoError(t, err)
+	assert.Equal(t, entity.PassthroughPathAndQuery, got)
+	_, err = normalizePassthrough("fragment")
+	assert.ErrorIs(t, err, ErrInvalidPassthrough)
+}
@@ -0,0 +1,149 @@
+package usecases
+
+import (
+	"errors"
+	"fmt"
+	"sync"
+	"time"
+
+	"golang.org/x/crypto/bcrypt"
+
+	"github.com/radiophysiker/shortener_link/internal/cache"
+	"github.com/radiophysiker/shortener_link/internal/config"
+	"github.com/radiophysiker/shortener_link/internal/entity"
+)
+
+// maxPasswordLength is the longest password bcrypt can hash, in bytes.
+const maxPasswordLength = 72
+
+// maxBatchPasswords is how many password-protected URLs may be created in one batch.
+// Each password is hashed with bcrypt, which is slow on purpose.
+const maxBatchPasswords = 10
+
+// attemptLimiterSize is how many links and IP addresses the failed attempts are counted for.
+// The least recently failed ones are forgotten first.
+const attemptLimiterSize = 10000
+
+var (
+	ErrInvalidPassword = errors.New("invalid password")
+	ErrWrongPassword   = errors.New("wrong password")
+	ErrTooManyAttempts = errors.New("too many password attempts")
+)
+
+// TooManyAttemptsError is returned when a password may not be tried for a while.
+// It matches ErrTooManyAttempts, use errors.As to get when the next attempt is allowed.
+type TooManyAttemptsError struct {
+	RetryAfter time.Duration
+}
+
+func (e *TooManyAttemptsError) Error() string {
+	return fmt.Sprintf("%s, retry after %s", ErrTooManyAttempts, e.RetryAfter.Round(time.Second))
+}
+
+func (e *TooManyAttemptsError) Unwrap() error {
+	return ErrTooManyAttempts
+}
+
+// hashPassword returns the bcrypt hash of the password of a short URL being created,
+// or an empty hash if the URL is not protected.
+func hashPassword(password string) (string, error) {
+	if password == "" {
+		return "", nil
+	}
+	if len(password) > maxPasswordLength {
+		return "", fmt.Errorf("%w: must be at most %d bytes long", ErrInvalidPassword, maxPasswordLength)
+	}
+	hash, err := bcrypt.GenerateFromPassword([]byte(password), bcrypt.DefaultCost)
+	if err != nil {
+		return "", fmt.Errorf("failed to hash password: %w", err)
+	}
+	return string(hash), nil
+}
+
+// attempts is the number of failed attempts in the window that ends at resetAt.
+type attempts struct {
+	count   int
+	resetAt time.Time
+}
+
+// URLUnlocker checks the passwords of protected short URLs.
+// Failed attempts are counted per short URL and per client IP address, and once either reaches its limit
+// within the window, no more attempts are allowed until the window ends.
+type URLUnlocker struct {
+	perURL int
+	perIP  int
+	window time.Duration
+
+	mu     sync.Mutex
+	failed *cache.LRU[string, attempts]
+}
+
+func NewURLUnlocker(cfg *config.Config) *URLUnlocker {
+	return &URLUnlocker{
+		perURL: cfg.PasswordAttemptsPerURL,
+		perIP:  cfg.PasswordAttemptsPerIP,
+		window: cfg.PasswordAttemptWindow,
+		failed: cache.NewLRU[string, attempts](attemptLimiterSize),
+	}
+}
+
+// Unlock checks the password of the URL tried by the client with the given IP address.
+// It returns nil if the URL is not protected.
+func (u *URLUnlocker) Unlock(url entity.URL, password, ip string) error {
+	if url.PasswordHash == "" {
+		return nil
+	}
+	urlKey, ipKey := "url:"+url.ShortURL, "ip:"+ip
+	if err := u.begin(time.Now(), urlKey, ipKey); err != nil {
+		return err
+	}
+	// A password too long for bcrypt cannot be right, CompareHashAndPassword rejects it as well.
+	if bcrypt.CompareHashAndPassword([]byte(url.PasswordHash), []byte(password)) != nil {
+		return fmt.Errorf("%w for: %s", ErrWrongPassword, url.ShortURL)
+	}
+	u.forgive(time.Now(), urlKey, ipKey)
+	return nil
+}
+
+// begin returns a *TooManyAttemptsError if any of the keys has used up its attempts, and otherwise counts
+// the attempt as failed for every key. The attempt is counted before the slow password check, in the same
+// critical section as the limit check, so that concurrent attempts cannot all pass the check before any of them
+// is counted. An attempt with the right password is taken back by forgive.
+func (u *URLUnlocker) begin(now time.Time, urlKey, ipKey string) error {
+	u.mu.Lock()
+	defer u.mu.Unlock()
+	limits := map[string]int{urlKey: u.perURL, ipKey: u.perIP}
+	var retryAfter time.Duration
+	for key, limit := range limits {
+		a, ok := u.failed.Get(key)
+		if ok && limit > 0 && a.count >= limit {
+			retryAfter = max(retryAfter, a.resetAt.Sub(now))
+		}
+	}
+	if retryAfter > 0 {
+		return &TooManyAttemptsError{RetryAfter: retryAfter}
+	}
+	for key := range limits {
+		a, ok := u.failed.Get(key)
+		if !ok {
+			a = attempts{resetAt: now.Add(u.window)}
+		}
+		a.count++
+		u.failed.Set(key, a, a.resetAt.Sub(now))
+	}
+	return nil
+}
+
+// forgive takes back the attempt counted by begin for every key.
+func (u *URLUnlocker) forgive(now time.Time, keys ...string) {
+	u.mu.Lock()
+	defer u.mu.Unlock()
+	for _, key := range keys {
+		a, ok := u.failed.Get(key)
+		if !ok || a.count == 0 {
+			continue
+		}
+		a.count--
+		u.failed.Set(key, a, a.resetAt.Sub(now))
+	}
+}
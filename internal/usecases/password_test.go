@@ -0,0 +1,127 @@
+package usecases
+
+import (
+	"errors"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"golang.org/x/crypto/bcrypt"
+
+	"github.com/radiophysiker/shortener_link/internal/config"
+	"github.com/radiophysiker/shortener_link/internal/entity"
+)
+
+func TestHashPassword(t *testing.T) {
+	hash, err := hashPassword("")
+	require.NoError(t, err)
+	assert.Empty(t, hash, "an empty password should leave the URL unprotected")
+
+	hash, err = hashPassword("secret")
+	require.NoError(t, err)
+	assert.NoError(t, bcrypt.CompareHashAndPassword([]byte(hash), []byte("secret")))
+
+	_, err = hashPassword(strings.Repeat("a", maxPasswordLength+1))
+	assert.ErrorIs(t, err, ErrInvalidPassword)
+}
+
+func newProtectedURL(t *testing.T, shortURL, password string) entity.URL {
+	t.Helper()
+	hash, err := bcrypt.GenerateFromPassword([]byte(password), bcrypt.MinCost)
+	require.NoError(t, err)
+	return entity.URL{ShortURL: shortURL, FullURL: "https://example.com", PasswordHash: string(hash)}
+}
+
+func TestURLUnlocker(t *testing.T) {
+	unlocker := NewURLUnlocker(&config.Config{PasswordAttemptsPerURL: 10, PasswordAttemptsPerIP: 10, PasswordAttemptWindow: time.Minute})
+	url := newProtectedURL(t, "short", "secret")
+
+	assert.NoError(t, unlocker.Unlock(url, "secret", "10.0.0.1"))
+	assert.ErrorIs(t, unlocker.Unlock(url, "wrong", "10.0.0.1"), ErrWrongPassword)
+	assert.NoError(t, unlocker.Unlock(entity.URL{ShortURL: "open"}, "", "10.0.0.1"), "unprotected URLs should always unlock")
+}
+
+func TestURLUnlockerThrottlesPerIP(t *testing.T) {
+	unlocker := NewURLUnlocker(&config.Config{PasswordAttemptsPerURL: 100, PasswordAttemptsPerIP: 2, PasswordAttemptWindow: time.Minute})
+	first := newProtectedURL(t, "first", "secret")
+	second := newProtectedURL(t, "second", "secret")
+
+	assert.ErrorIs(t, unlocker.Unlock(first, "wrong", "10.0.0.1"), ErrWrongPassword)
+	assert.ErrorIs(t, unlocker.Unlock(second, "wrong", "10.0.0.1"), ErrWrongPassword)
+
+	err := unlocker.Unlock(first, "secret", "10.0.0.1")
+	var tooMany *TooManyAttemptsError
+	require.ErrorAs(t, err, &tooMany, "the IP address should be throttled across URLs, even with the right password")
+	assert.InDelta(t, time.Minute.Seconds(), tooMany.RetryAfter.Seconds(), 1)
+	assert.NoError(t, unlocker.Unlock(first, "secret", "10.0.0.2"), "other IP addresses should not be throttled")
+}
+
+func TestURLUnlockerThrottlesPerURL(t *testing.T) {
+	unlocker := NewURLUnlocker(&config.Config{PasswordAttemptsPerURL: 2, PasswordAttemptsPerIP: 100, PasswordAttemptWindow: time.Minute})
+	url := newProtectedURL(t, "short", "secret")
+
+	assert.ErrorIs(t, unlocker.Unlock(url, "wrong", "10.0.0.1"), ErrWrongPassword)
+	assert.ErrorIs(t, unlocker.Unlock(url, "wrong", "10.0.0.2"), ErrWrongPassword)
+	assert.ErrorIs(t, unlocker.Unlock(url, "secret", "10.0.0.3"), ErrTooManyAttempts,
+		"the URL should be throttled across IP addresses")
+	assert.NoError(t, unlocker.Unlock(newProtectedURL(t, "other", "secret"), "secret", "10.0.0.3"))
+}
+
+func TestURLUnlockerWindowEnds(t *testing.T) {
+	unlocker := NewURLUnlocker(&config.Config{PasswordAttemptsPerURL: 1, PasswordAttemptsPerIP: 1, PasswordAttemptWindow: 50 * time.Millisecond})
+	url := newProtectedURL(t, "short", "secret")
+
+	assert.ErrorIs(t, unlocker.Unlock(url, "wrong", "10.0.0.1"), ErrWrongPassword)
+	assert.ErrorIs(t, unlocker.Unlock(url, "secret", "10.0.0.1"), ErrTooManyAttempts)
+	assert.Eventually(t, func() bool {
+		return unlocker.Unlock(url, "secret", "10.0.0.1") == nil
+	}, time.Second, 10*time.Millisecond, "the attempts should be allowed again once the window ends")
+}
+
+func TestURLUnlockerThrottlesConcurrentAttempts(t *testing.T) {
+	const limit, clients = 5, 50
+	unlocker := NewURLUnlocker(&config.Config{PasswordAttemptsPerURL: 100, PasswordAttemptsPerIP: limit, PasswordAttemptWindow: time.Minute})
+	// The hash is slow enough for the attempts to overlap.
+	hash, err := bcrypt.GenerateFromPassword([]byte("secret"), bcrypt.MinCost+4)
+	require.NoError(t, err)
+	url := entity.URL{ShortURL: "short", FullURL: "https://example.com", PasswordHash: string(hash)}
+
+	var (
+		wg      sync.WaitGroup
+		mu      sync.Mutex
+		checked int
+		start   = make(chan struct{})
+	)
+	for range clients {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			<-start
+			err := unlocker.Unlock(url, "wrong", "10.0.0.1")
+			if errors.Is(err, ErrWrongPassword) {
+				mu.Lock()
+				defer mu.Unlock()
+				checked++
+				return
+			}
+			assert.ErrorIs(t, err, ErrTooManyAttempts)
+		}()
+	}
+	close(start)
+	wg.Wait()
+	assert.Equal(t, limit, checked, "only the allowed number of passwords should be checked")
+}
+
+func TestURLUnlockerDoesNotCountRightPasswords(t *testing.T) {
+	unlocker := NewURLUnlocker(&config.Config{PasswordAttemptsPerURL: 2, PasswordAttemptsPerIP: 2, PasswordAttemptWindow: time.Minute})
+	url := newProtectedURL(t, "short", "secret")
+
+	for range 5 {
+		require.NoError(t, unlocker.Unlock(url, "secret", "10.0.0.1"), "the right password should not use up attempts")
+	}
+	assert.ErrorIs(t, unlocker.Unlock(url, "wrong", "10.0.0.1"), ErrWrongPassword)
+	assert.NoError(t, unlocker.Unlock(url, "secret", "10.0.0.1"))
+}
@@ -0,0 +1,24 @@
+package usecases
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+)
+
+var ErrInvalidRedirectType = errors.New("invalid redirect type")
+
+// ValidateRedirectStatus checks that status is one of the HTTP redirect statuses a short URL can redirect with:
+// 301 and 308 for permanent redirects, 302 and 307 for temporary ones.
+func ValidateRedirectStatus(status int) error {
+	switch status {
+	case http.StatusMovedPermanently, http.StatusFound, http.StatusTemporaryRedirect, http.StatusPermanentRedirect:
+		return nil
+	}
+	return fmt.Errorf("%w: %d, must be one of 301, 302, 307 or 308", ErrInvalidRedirectType, status)
+}
+
+// IsPermanentRedirect reports whether the redirect status tells clients to cache the redirect.
+func IsPermanentRedirect(status int) bool {
+	return status == http.StatusMovedPermanently || status == http.StatusPermanentRedirect
+}
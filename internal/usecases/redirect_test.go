@@ -0,0 +1,63 @@
+package usecases
+
+import (
+	"context"
+	"net/http"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/radiophysiker/shortener_link/internal/config"
+	"github.com/radiophysiker/shortener_link/internal/entity"
+)
+
+func TestValidateRedirectStatus(t *testing.T) {
+	for _, status := range []int{301, 302, 307, 308} {
+		assert.NoError(t, ValidateRedirectStatus(status))
+	}
+	for _, status := range []int{0, 200, 303, 404} {
+		assert.ErrorIs(t, ValidateRedirectStatus(status), ErrInvalidRedirectType)
+	}
+}
+
+// redirectRepository resolves every short URL to a URL with the given redirect status.
+type redirectRepository struct {
+	URLRepository
+	redirectStatus int
+}
+
+func (r redirectRepository) ResolveURL(ctx context.Context, shortURL string) (entity.URL, error) {
+	return entity.URL{ShortURL: shortURL, FullURL: "https://example.com", RedirectStatus: r.redirectStatus}, nil
+}
+
+func TestResolveURLRedirectStatus(t *testing.T) {
+	cfg := &config.Config{RedirectStatus: http.StatusFound}
+	tests := []struct {
+		name   string
+		stored int
+		want   int
+	}{
+		{name: "configured default", stored: 0, want: http.StatusFound},
+		{name: "own status", stored: http.StatusMovedPermanently, want: http.StatusMovedPermanently},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			us := NewURLShortener(redirectRepository{redirectStatus: tt.stored}, nil, nil, cfg)
+			url, err := us.ResolveURL(context.Background(), "short")
+			require.NoError(t, err)
+			assert.Equal(t, tt.want, url.RedirectStatus)
+		})
+	}
+}
+
+func TestCreateShortURLRejectsInvalidRedirectStatus(t *testing.T) {
+	cfg := &config.Config{CodeLength: 6, CodeMaxLength: 8, CodeGrowthThreshold: 0.1}
+	us := NewURLShortener(redirectRepository{}, fixedLengthGenerator{}, newTestCodeLengthController(t, &fakeCodeLengthStore{}), cfg)
+	_, err := us.CreateShortURL(context.Background(), "https://example.com", CreateOptions{RedirectStatus: 303})
+	assert.ErrorIs(t, err, ErrInvalidRedirectType)
+	_, err = us.CreateBatchURLs(context.Background(), []BatchItem{
+		{CorrelationID: "1", OriginalURL: "https://example.com", RedirectStatus: 200},
+	})
+	assert.ErrorIs(t, err, ErrInvalidRedirectType)
+}
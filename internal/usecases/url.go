@@ -4,15 +4,19 @@ import (
 	"context"
 	"errors"
 	"fmt"
+	"time"
 
+	"github.com/radiophysiker/shortener_link/internal/auth"
 	"github.com/radiophysiker/shortener_link/internal/config"
 	"github.com/radiophysiker/shortener_link/internal/entity"
-	"github.com/radiophysiker/shortener_link/internal/utils"
+	"github.com/radiophysiker/shortener_link/internal/metrics"
 )
 
-const lenShortenedURL = 6
 const maxNumberAttempts = 5
 
+// maxBatchSize is how many URLs may be created in one batch.
+const maxBatchSize = 1000
+
 var (
 	ErrURLGeneratedBefore       = errors.New("shortURL already generated before")
 	ErrFailedToGenerateShortURL = errors.New("failed to generate short URL")
@@ -20,76 +24,303 @@ var (
 	ErrEmptyShortURL            = errors.New("empty short URL")
 	ErrURLNotFound              = errors.New("URL not found")
 	ErrEmptyBatch               = errors.New("empty batch")
+	ErrBatchTooLarge            = errors.New("batch is too large")
 	ErrURLConflict              = errors.New("URL already exists in the database")
+	ErrEmptyUserID              = errors.New("empty user ID")
+	ErrURLDeleted               = errors.New("URL has been deleted")
+	ErrURLExpired               = errors.New("URL has expired")
+	ErrInvalidExpiry            = errors.New("invalid expiry")
+	ErrURLExhausted             = errors.New("URL has reached its click limit")
+	ErrInvalidMaxClicks         = errors.New("invalid max clicks")
 )
 
+// ConflictError is returned when the full URL being saved has already been shortened by the same user.
+// URLs of other users are never returned, since the user could not edit or delete them.
+// It matches ErrURLConflict, use errors.As to get the existing short URL.
+type ConflictError struct {
+	ExistingShortURL string
+}
+
+func (e *ConflictError) Error() string {
+	return fmt.Sprintf("%s: %s", ErrURLConflict, e.ExistingShortURL)
+}
+
+func (e *ConflictError) Unwrap() error {
+	return ErrURLConflict
+}
+
 type BatchItem struct {
 	CorrelationID string
 	OriginalURL   string
-	ShortURL      string
+	Alias         string
+	Expiry        Expiry
+	// RedirectStatus is the HTTP status the short URL redirects with. Zero means the configured default.
+	RedirectStatus int
+	Passthrough    entity.PassthroughMode
+	// Password protects the short URL. It is not protected if the password is empty.
+	Password string
+	// MaxClicks is how many times the short URL redirects. Zero means no limit.
+	MaxClicks int64
+	ShortURL  string
+	// Existing is set if the original URL had already been shortened, ShortURL is the existing short URL then.
+	Existing bool
+	// Err is set if the item could not be saved, e.g. because its alias is taken.
+	Err error
+}
+
+// SaveStatus is the outcome of saving a single URL of a batch.
+type SaveStatus int
+
+const (
+	// SaveCreated means the URL has been saved.
+	SaveCreated SaveStatus = iota
+	// SaveExisting means the full URL had already been shortened, so nothing has been saved.
+	SaveExisting
+	// SaveShortURLTaken means the short URL belongs to another full URL, so nothing has been saved.
+	SaveShortURLTaken
+)
+
+// SaveResult is the outcome of saving a single URL of a batch.
+type SaveResult struct {
+	Status SaveStatus
+	// ShortURL is the short URL the full URL is available at. It is empty if the short URL is taken.
+	ShortURL string
 }
 
 type URLRepository interface {
 	Save(ctx context.Context, url entity.URL) error
-	GetFullURL(ctx context.Context, shortURL string) (string, error)
-	SaveBatch(ctx context.Context, urls []entity.URL) error
+	// ResolveURL returns the URL the short URL redirects to. It fails if the URL is deleted, expired or exhausted.
+	ResolveURL(ctx context.Context, shortURL string) (entity.URL, error)
+	// ConsumeClick atomically counts a redirect of a URL with a click limit and returns how many are left.
+	ConsumeClick(ctx context.Context, shortURL string) (int64, error)
+	// SaveBatch saves the URLs and returns the outcome of every URL in the same order.
+	// A URL that conflicts with a stored one does not prevent the rest of the batch from being saved.
+	SaveBatch(ctx context.Context, urls []entity.URL) ([]SaveResult, error)
+	// GetURLsByUserID returns the URLs of the user that are not deleted, in the order they were created in.
+	GetURLsByUserID(ctx context.Context, userID string) ([]entity.URL, error)
+	// GetURL returns the stored URL, even if it is deleted or expired.
+	GetURL(ctx context.Context, shortURL string) (entity.URL, error)
+	GetClickStats(ctx context.Context, shortURL string) (entity.ClickStats, error)
+}
+
+// CodeRequest describes the short code to generate.
+type CodeRequest struct {
+	FullURL string
+	Length  int
+	// Attempt is the number of codes for this URL that already turned out to be taken.
+	Attempt int
+}
+
+// CodeGenerator generates short codes for new short URLs.
+type CodeGenerator interface {
+	Generate(ctx context.Context, req CodeRequest) (string, error)
 }
 
 type URLUseCase struct {
 	urlRepository URLRepository
+	generator     CodeGenerator
+	codeLength    *CodeLengthController
 	config        *config.Config
 }
 
-func NewURLShortener(re URLRepository, cfg *config.Config) *URLUseCase {
+func NewURLShortener(
+	re URLRepository,
+	generator CodeGenerator,
+	codeLength *CodeLengthController,
+	cfg *config.Config,
+) *URLUseCase {
 	return &URLUseCase{
 		urlRepository: re,
+		generator:     generator,
+		codeLength:    codeLength,
 		config:        cfg,
 	}
 }
 
-// CreateShortURL creates a short URL.
-func (us URLUseCase) CreateShortURL(ctx context.Context, fullURL string) (string, error) {
-	return us.retryCreateShortURL(ctx, 1, fullURL)
+// CreateOptions holds optional parameters of a short URL being created.
+type CreateOptions struct {
+	// Alias is a custom short code. A random one is generated if empty.
+	Alias string
+	// Expiry sets when the short URL expires. The URL never expires if it is empty.
+	Expiry Expiry
+	// RedirectStatus is the HTTP status the short URL redirects with. Zero means the configured default.
+	RedirectStatus int
+	// Passthrough says what the redirect carries over from the request. Nothing is if it is empty.
+	Passthrough entity.PassthroughMode
+	// Password protects the short URL. It is not protected if the password is empty.
+	Password string
+	// MaxClicks is how many times the short URL redirects before it is exhausted. Zero means no limit.
+	MaxClicks int64
 }
 
-// retryCreateShortURL is a recursive function that tries to create a short URL.
-func (us URLUseCase) retryCreateShortURL(ctx context.Context, numberAttempts int, fullURL string) (string, error) {
-	shortURL := utils.GetShortRandomString(lenShortenedURL)
+// Expiry is either an absolute expiry moment or a TTL counted from the creation, not both.
+type Expiry struct {
+	ExpiresAt *time.Time
+	TTL       time.Duration
+}
+
+// resolve returns the absolute expiry moment, or zero time if the URL never expires.
+func (e Expiry) resolve(now time.Time) (time.Time, error) {
+	if e.ExpiresAt != nil && e.TTL != 0 {
+		return time.Time{}, fmt.Errorf("%w: expires_at and ttl_seconds are mutually exclusive", ErrInvalidExpiry)
+	}
+	if e.TTL < 0 {
+		return time.Time{}, fmt.Errorf("%w: ttl_seconds must be positive", ErrInvalidExpiry)
+	}
+	if e.TTL > 0 {
+		return now.Add(e.TTL), nil
+	}
+	if e.ExpiresAt != nil {
+		if !e.ExpiresAt.After(now) {
+			return time.Time{}, fmt.Errorf("%w: expires_at must be in the future", ErrInvalidExpiry)
+		}
+		return *e.ExpiresAt, nil
+	}
+	return time.Time{}, nil
+}
+
+// CreateShortURL creates a short URL owned by the user from the context.
+// If the user has already shortened the full URL, the existing short URL is returned along with a *ConflictError.
+// A short URL with options is always created, since it is not interchangeable with an existing one.
+func (us URLUseCase) CreateShortURL(ctx context.Context, fullURL string, opts CreateOptions) (string, error) {
+	expiresAt, err := opts.Expiry.resolve(time.Now())
+	if err != nil {
+		return "", err
+	}
+	if opts.RedirectStatus != 0 {
+		if err := ValidateRedirectStatus(opts.RedirectStatus); err != nil {
+			return "", err
+		}
+	}
+	passthrough, err := normalizePassthrough(opts.Passthrough)
+	if err != nil {
+		return "", err
+	}
+	passwordHash, err := hashPassword(opts.Password)
+	if err != nil {
+		return "", err
+	}
+	if opts.MaxClicks < 0 {
+		return "", fmt.Errorf("%w: must not be negative", ErrInvalidMaxClicks)
+	}
 	url := entity.URL{
-		ShortURL: shortURL,
-		FullURL:  fullURL,
+		FullURL:        fullURL,
+		UserID:         auth.UserIDFromContext(ctx),
+		ExpiresAt:      expiresAt,
+		RedirectStatus: opts.RedirectStatus,
+		Passthrough:    passthrough,
+		PasswordHash:   passwordHash,
+		MaxClicks:      opts.MaxClicks,
+	}
+	if opts.Alias != "" {
+		if err := validateAlias(opts.Alias); err != nil {
+			return "", err
+		}
+		url.ShortURL = opts.Alias
+		shortURL, err := us.saveURL(ctx, url)
+		if errors.Is(err, ErrURLGeneratedBefore) {
+			return "", fmt.Errorf("%w: %s", ErrAliasTaken, opts.Alias)
+		}
+		return shortURL, err
+	}
+	return us.retryCreateShortURL(ctx, 1, url)
+}
+
+// generateCode generates a short code of the given length for the full URL.
+func (us URLUseCase) generateCode(ctx context.Context, fullURL string, length, attempt int) (string, error) {
+	code, err := us.generator.Generate(ctx, CodeRequest{
+		FullURL: fullURL,
+		Length:  length,
+		Attempt: attempt,
+	})
+	if err != nil {
+		return "", fmt.Errorf("%w: %w", ErrFailedToGenerateShortURL, err)
+	}
+	return code, nil
+}
+
+// retryCreateShortURL is a recursive function that tries to create a short URL.
+// If no free code of the current length is found in maxNumberAttempts, the length grows and the attempts start over.
+func (us URLUseCase) retryCreateShortURL(ctx context.Context, numberAttempts int, url entity.URL) (string, error) {
+	length := us.codeLength.Length()
+	shortURL, err := us.generateCode(ctx, url.FullURL, length, numberAttempts-1)
+	if err != nil {
+		return "", err
+	}
+	url.ShortURL = shortURL
+	shortURL, err = us.saveURL(ctx, url)
+	collided := errors.Is(err, ErrURLGeneratedBefore)
+	if err == nil || collided || errors.Is(err, ErrURLConflict) {
+		us.codeLength.Observe(ctx, collided)
+	}
+	if collided {
+		metrics.ShortURLCollisionsTotal.Inc()
+		if numberAttempts >= maxNumberAttempts {
+			if !us.codeLength.Grow(ctx, length) {
+				return "", ErrFailedToGenerateShortURL
+			}
+			return us.retryCreateShortURL(ctx, 1, url)
+		}
+		return us.retryCreateShortURL(ctx, numberAttempts+1, url)
 	}
+	return shortURL, err
+}
+
+// saveURL saves the URL and returns its short URL.
+// If the full URL has already been shortened, the existing short URL is returned along with a *ConflictError.
+// ErrURLGeneratedBefore is returned as is, so that the caller can decide how to handle a taken short URL.
+func (us URLUseCase) saveURL(ctx context.Context, url entity.URL) (string, error) {
 	err := us.urlRepository.Save(ctx, url)
 	if err != nil {
 		if errors.Is(err, ErrEmptyFullURL) {
 			return "", ErrEmptyFullURL
 		}
 		if errors.Is(err, ErrURLGeneratedBefore) {
-			if numberAttempts >= maxNumberAttempts {
-				return "", ErrFailedToGenerateShortURL
-			} else {
-				return us.retryCreateShortURL(ctx, numberAttempts+1, fullURL)
-			}
+			return "", ErrURLGeneratedBefore
 		}
-		if errors.Is(err, ErrURLConflict) {
-			errStr := err.Error()
-			if len(errStr) > len(ErrURLConflict.Error())+2 { // +2 for ": "
-				existingShortURL := errStr[len(ErrURLConflict.Error())+2:]
-				return existingShortURL, ErrURLConflict
-			}
+		var conflict *ConflictError
+		if errors.As(err, &conflict) {
+			return conflict.ExistingShortURL, conflict
 		}
 		return "", fmt.Errorf("failed to save URL: %w", err)
 	}
-	return shortURL, nil
+	return url.ShortURL, nil
+}
+
+// validateBatchSize checks the batch before any of its items is processed,
+// so that an oversized batch cannot spend the CPU time of hashing its passwords.
+func validateBatchSize(items []BatchItem) error {
+	if len(items) > maxBatchSize {
+		return fmt.Errorf("%w: must contain at most %d URLs", ErrBatchTooLarge, maxBatchSize)
+	}
+	var protected int
+	for i := range items {
+		if items[i].Password != "" {
+			protected++
+		}
+	}
+	if protected > maxBatchPasswords {
+		return fmt.Errorf("%w: at most %d URLs may be password-protected", ErrBatchTooLarge, maxBatchPasswords)
+	}
+	return nil
 }
 
 // CreateBatchURLs creates multiple short URLs in a batch.
+// The items are saved independently: an item without options whose original URL the user had already shortened
+// is returned with Existing set, and an item that could not be saved is returned with Err set.
+// An error is only returned if the batch is invalid or could not be saved at all.
 func (us URLUseCase) CreateBatchURLs(ctx context.Context, items []BatchItem) ([]BatchItem, error) {
 	if len(items) == 0 {
 		return nil, ErrEmptyBatch
 	}
+	if err := validateBatchSize(items); err != nil {
+		return nil, err
+	}
+	userID := auth.UserIDFromContext(ctx)
+	now := time.Now()
+	length := us.codeLength.Length()
 	urls := make([]entity.URL, 0, len(items))
-	resultItems := make([]BatchItem, 0, len(items))
+	aliases := make(map[string]struct{})
 
 	for i := range items {
 		if items[i].OriginalURL == "" {
@@ -99,34 +330,209 @@ func (us URLUseCase) CreateBatchURLs(ctx context.Context, items []BatchItem) ([]
 			return nil, ErrEmptyShortURL
 		}
 
-		shortURL := utils.GetShortRandomString(lenShortenedURL)
+		expiresAt, err := items[i].Expiry.resolve(now)
+		if err != nil {
+			return nil, err
+		}
+		if items[i].RedirectStatus != 0 {
+			if err := ValidateRedirectStatus(items[i].RedirectStatus); err != nil {
+				return nil, err
+			}
+		}
+		passthrough, err := normalizePassthrough(items[i].Passthrough)
+		if err != nil {
+			return nil, err
+		}
+		passwordHash, err := hashPassword(items[i].Password)
+		if err != nil {
+			return nil, err
+		}
+		if items[i].MaxClicks < 0 {
+			return nil, fmt.Errorf("%w: must not be negative", ErrInvalidMaxClicks)
+		}
+
+		shortURL := items[i].Alias
+		if shortURL != "" {
+			if err := validateAlias(shortURL); err != nil {
+				return nil, err
+			}
+			if _, exists := aliases[shortURL]; exists {
+				return nil, fmt.Errorf("%w: %s", ErrAliasTaken, shortURL)
+			}
+			aliases[shortURL] = struct{}{}
+		} else {
+			shortURL, err = us.generateCode(ctx, items[i].OriginalURL, length, 0)
+			if err != nil {
+				return nil, err
+			}
+		}
 		urls = append(urls, entity.URL{
-			ShortURL: shortURL,
-			FullURL:  items[i].OriginalURL,
+			ShortURL:       shortURL,
+			FullURL:        items[i].OriginalURL,
+			UserID:         userID,
+			ExpiresAt:      expiresAt,
+			RedirectStatus: items[i].RedirectStatus,
+			Passthrough:    passthrough,
+			PasswordHash:   passwordHash,
+			MaxClicks:      items[i].MaxClicks,
 		})
+	}
 
-		items[i].ShortURL = shortURL
-		resultItems = append(resultItems, items[i])
+	if err := us.saveBatch(ctx, items, urls, length); err != nil {
+		return nil, err
 	}
+	return items, nil
+}
 
-	if err := us.urlRepository.SaveBatch(ctx, urls); err != nil {
-		return nil, fmt.Errorf("failed to save batch of URLs: %w", err)
+// saveBatch saves the URLs of the items and fills in the outcome of every item.
+// Generated codes that turn out to be taken are regenerated and saved again, the same way
+// retryCreateShortURL does it for a single URL.
+func (us URLUseCase) saveBatch(ctx context.Context, items []BatchItem, urls []entity.URL, length int) error {
+	pending := make([]int, len(urls))
+	for i := range pending {
+		pending[i] = i
 	}
+	for attempt := 1; ; attempt++ {
+		batch := make([]entity.URL, 0, len(pending))
+		for _, i := range pending {
+			batch = append(batch, urls[i])
+		}
+		results, err := us.urlRepository.SaveBatch(ctx, batch)
+		if err != nil {
+			return fmt.Errorf("failed to save batch of URLs: %w", err)
+		}
 
-	return resultItems, nil
+		var collided []int
+		for j, i := range pending {
+			result := results[j]
+			generated := items[i].Alias == ""
+			if generated {
+				us.codeLength.Observe(ctx, result.Status == SaveShortURLTaken)
+			}
+			switch result.Status {
+			case SaveCreated:
+				items[i].ShortURL = result.ShortURL
+			case SaveExisting:
+				items[i].ShortURL = result.ShortURL
+				items[i].Existing = true
+			case SaveShortURLTaken:
+				if !generated {
+					items[i].Err = fmt.Errorf("%w: %s", ErrAliasTaken, items[i].Alias)
+					continue
+				}
+				metrics.ShortURLCollisionsTotal.Inc()
+				collided = append(collided, i)
+			}
+		}
+		if len(collided) == 0 {
+			return nil
+		}
+
+		if attempt >= maxNumberAttempts {
+			if !us.codeLength.Grow(ctx, length) {
+				for _, i := range collided {
+					items[i].Err = ErrFailedToGenerateShortURL
+				}
+				return nil
+			}
+			attempt = 0
+			length = us.codeLength.Length()
+		}
+		for _, i := range collided {
+			shortURL, err := us.generateCode(ctx, urls[i].FullURL, length, attempt)
+			if err != nil {
+				return err
+			}
+			urls[i].ShortURL = shortURL
+		}
+		pending = collided
+	}
 }
 
-// GetFullURL returns the full URL by the short URL.
-func (us URLUseCase) GetFullURL(ctx context.Context, shortURL string) (string, error) {
-	fullURL, err := us.urlRepository.GetFullURL(ctx, shortURL)
+// ResolveURL returns the URL the short URL redirects to.
+// Its RedirectStatus is set to the configured default unless the URL has its own.
+func (us URLUseCase) ResolveURL(ctx context.Context, shortURL string) (entity.URL, error) {
+	url, err := us.urlRepository.ResolveURL(ctx, shortURL)
 	if err != nil {
 		if errors.Is(err, ErrEmptyShortURL) {
-			return "", ErrEmptyShortURL
+			return entity.URL{}, ErrEmptyShortURL
+		}
+		if errors.Is(err, ErrURLNotFound) {
+			return entity.URL{}, fmt.Errorf("%w for: %s", ErrURLNotFound, shortURL)
+		}
+		if errors.Is(err, ErrURLDeleted) {
+			return entity.URL{}, fmt.Errorf("%w: %s", ErrURLDeleted, shortURL)
 		}
+		if errors.Is(err, ErrURLExpired) {
+			return entity.URL{}, fmt.Errorf("%w: %s", ErrURLExpired, shortURL)
+		}
+		if errors.Is(err, ErrURLExhausted) {
+			return entity.URL{}, fmt.Errorf("%w: %s", ErrURLExhausted, shortURL)
+		}
+		return entity.URL{}, fmt.Errorf("failed to get full URL: %w", err)
+	}
+	if url.RedirectStatus == 0 {
+		url.RedirectStatus = us.config.RedirectStatus
+	}
+	return url, nil
+}
+
+// ConsumeClick takes one of the clicks of a URL with a click limit before it redirects.
+// It fails with ErrURLExhausted if there are none left, for instance because a concurrent redirect took the last one.
+// URLs without a limit are not counted.
+func (us URLUseCase) ConsumeClick(ctx context.Context, url entity.URL) error {
+	if url.MaxClicks == 0 {
+		return nil
+	}
+	if _, err := us.urlRepository.ConsumeClick(ctx, url.ShortURL); err != nil {
+		if errors.Is(err, ErrURLExhausted) || errors.Is(err, ErrURLDeleted) || errors.Is(err, ErrURLExpired) ||
+			errors.Is(err, ErrURLNotFound) {
+			return err
+		}
+		return fmt.Errorf("failed to consume click: %w", err)
+	}
+	return nil
+}
+
+// GetUserURLs returns all URLs owned by the user from the context.
+func (us URLUseCase) GetUserURLs(ctx context.Context) ([]entity.URL, error) {
+	userID := auth.UserIDFromContext(ctx)
+	if userID == "" {
+		return nil, ErrEmptyUserID
+	}
+	urls, err := us.urlRepository.GetURLsByUserID(ctx, userID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get user URLs: %w", err)
+	}
+	return urls, nil
+}
+
+// GetClickStats returns click statistics of the short URL owned by the user from the context.
+func (us URLUseCase) GetClickStats(ctx context.Context, shortURL string) (entity.ClickStats, error) {
+	userID := auth.UserIDFromContext(ctx)
+	if userID == "" {
+		return entity.ClickStats{}, ErrEmptyUserID
+	}
+	if shortURL == "" {
+		return entity.ClickStats{}, ErrEmptyShortURL
+	}
+	url, err := us.urlRepository.GetURL(ctx, shortURL)
+	if err != nil {
+		if errors.Is(err, ErrURLNotFound) {
+			return entity.ClickStats{}, fmt.Errorf("%w for: %s", ErrURLNotFound, shortURL)
+		}
+		return entity.ClickStats{}, fmt.Errorf("failed to get URL: %w", err)
+	}
+	if url.UserID != userID {
+		return entity.ClickStats{}, fmt.Errorf("%w: %s", ErrNotURLOwner, shortURL)
+	}
+
+	stats, err := us.urlRepository.GetClickStats(ctx, shortURL)
+	if err != nil {
 		if errors.Is(err, ErrURLNotFound) {
-			return "", fmt.Errorf("%w for: %s", ErrURLNotFound, shortURL)
+			return entity.ClickStats{}, fmt.Errorf("%w for: %s", ErrURLNotFound, shortURL)
 		}
-		return "", fmt.Errorf("failed to get full URL: %w", err)
+		return entity.ClickStats{}, fmt.Errorf("failed to get click stats: %w", err)
 	}
-	return fullURL, nil
+	return stats, nil
 }
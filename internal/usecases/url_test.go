@@ -0,0 +1,163 @@
+package usecases
+
+import (
+	"context"
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/radiophysiker/shortener_link/internal/auth"
+	"github.com/radiophysiker/shortener_link/internal/config"
+	"github.com/radiophysiker/shortener_link/internal/entity"
+)
+
+func TestExpiryResolve(t *testing.T) {
+	now := time.Date(2025, 3, 1, 12, 0, 0, 0, time.UTC)
+	future := now.Add(time.Hour)
+	past := now.Add(-time.Hour)
+
+	tests := []struct {
+		name    string
+		expiry  Expiry
+		want    time.Time
+		wantErr bool
+	}{
+		{name: "never expires", expiry: Expiry{}},
+		{name: "ttl", expiry: Expiry{TTL: time.Minute}, want: now.Add(time.Minute)},
+		{name: "absolute", expiry: Expiry{ExpiresAt: &future}, want: future},
+		{name: "both set", expiry: Expiry{ExpiresAt: &future, TTL: time.Minute}, wantErr: true},
+		{name: "negative ttl", expiry: Expiry{TTL: -time.Minute}, wantErr: true},
+		{name: "in the past", expiry: Expiry{ExpiresAt: &past}, wantErr: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := tt.expiry.resolve(now)
+			if tt.wantErr {
+				assert.ErrorIs(t, err, ErrInvalidExpiry)
+				return
+			}
+			require.NoError(t, err)
+			assert.True(t, tt.want.Equal(got), "expected %v, got %v", tt.want, got)
+		})
+	}
+}
+
+// conflictingRepository reports every full URL as already shortened to existingShortURL.
+type conflictingRepository struct {
+	URLRepository
+	existingShortURL string
+}
+
+func (r conflictingRepository) Save(ctx context.Context, url entity.URL) error {
+	return fmt.Errorf("failed to insert: %w", &ConflictError{ExistingShortURL: r.existingShortURL})
+}
+
+func TestCreateShortURLReturnsExistingShortURL(t *testing.T) {
+	cfg := &config.Config{CodeLength: 6, CodeMaxLength: 8, CodeGrowthThreshold: 0.1}
+	repo := conflictingRepository{existingShortURL: "existing"}
+	us := NewURLShortener(repo, fixedLengthGenerator{}, newTestCodeLengthController(t, &fakeCodeLengthStore{}), cfg)
+
+	for _, opts := range []CreateOptions{{}, {Alias: "alias"}} {
+		shortURL, err := us.CreateShortURL(context.Background(), "https://example.com", opts)
+		require.ErrorIs(t, err, ErrURLConflict)
+		var conflict *ConflictError
+		require.ErrorAs(t, err, &conflict, "the conflict should stay a ConflictError")
+		assert.Equal(t, "existing", conflict.ExistingShortURL)
+		assert.Equal(t, "existing", shortURL)
+	}
+}
+
+func TestCreateShortURLRejectsNegativeMaxClicks(t *testing.T) {
+	cfg := &config.Config{CodeLength: 6, CodeMaxLength: 8, CodeGrowthThreshold: 0.1}
+	us := NewURLShortener(conflictingRepository{}, fixedLengthGenerator{}, newTestCodeLengthController(t, &fakeCodeLengthStore{}), cfg)
+	_, err := us.CreateShortURL(context.Background(), "https://example.com", CreateOptions{MaxClicks: -1})
+	assert.ErrorIs(t, err, ErrInvalidMaxClicks)
+	_, err = us.CreateBatchURLs(context.Background(), []BatchItem{
+		{CorrelationID: "1", OriginalURL: "https://example.com", MaxClicks: -1},
+	})
+	assert.ErrorIs(t, err, ErrInvalidMaxClicks)
+}
+
+func TestCreateBatchURLsRejectsLargeBatches(t *testing.T) {
+	cfg := &config.Config{CodeLength: 6, CodeMaxLength: 8, CodeGrowthThreshold: 0.1}
+	us := NewURLShortener(conflictingRepository{}, fixedLengthGenerator{}, newTestCodeLengthController(t, &fakeCodeLengthStore{}), cfg)
+
+	items := make([]BatchItem, maxBatchSize+1)
+	for i := range items {
+		items[i] = BatchItem{CorrelationID: fmt.Sprint(i), OriginalURL: fmt.Sprintf("https://example.com/%d", i)}
+	}
+	_, err := us.CreateBatchURLs(context.Background(), items)
+	assert.ErrorIs(t, err, ErrBatchTooLarge)
+
+	items = items[:maxBatchPasswords+1]
+	for i := range items {
+		items[i].Password = "secret"
+	}
+	start := time.Now()
+	_, err = us.CreateBatchURLs(context.Background(), items)
+	assert.ErrorIs(t, err, ErrBatchTooLarge)
+	assert.Less(t, time.Since(start), time.Second, "the batch should be rejected before any password is hashed")
+}
+
+// countingRepository counts the clicks consumed and reports the URL exhausted after the first one.
+type countingRepository struct {
+	URLRepository
+	consumed *int
+}
+
+func (r countingRepository) ConsumeClick(ctx context.Context, shortURL string) (int64, error) {
+	*r.consumed++
+	if *r.consumed > 1 {
+		return 0, fmt.Errorf("%w: %s", ErrURLExhausted, shortURL)
+	}
+	return 0, nil
+}
+
+func TestConsumeClick(t *testing.T) {
+	var consumed int
+	us := NewURLShortener(countingRepository{consumed: &consumed}, nil, nil, &config.Config{})
+
+	require.NoError(t, us.ConsumeClick(context.Background(), entity.URL{ShortURL: "unlimited"}))
+	assert.Zero(t, consumed, "URLs without a click limit should not be counted")
+
+	oneTime := entity.URL{ShortURL: "once", MaxClicks: 1}
+	require.NoError(t, us.ConsumeClick(context.Background(), oneTime))
+	assert.ErrorIs(t, us.ConsumeClick(context.Background(), oneTime), ErrURLExhausted)
+	assert.Equal(t, 2, consumed)
+}
+
+// statsRepository holds a single URL with a single click.
+type statsRepository struct {
+	URLRepository
+	url entity.URL
+}
+
+func (r statsRepository) GetURL(ctx context.Context, shortURL string) (entity.URL, error) {
+	if shortURL != r.url.ShortURL {
+		return entity.URL{}, ErrURLNotFound
+	}
+	return r.url, nil
+}
+
+func (r statsRepository) GetClickStats(ctx context.Context, shortURL string) (entity.ClickStats, error) {
+	return entity.ClickStats{ShortURL: shortURL, Total: 1}, nil
+}
+
+func TestGetClickStatsChecksOwner(t *testing.T) {
+	repo := statsRepository{url: entity.URL{ShortURL: "short", FullURL: "https://example.com", UserID: "owner"}}
+	us := NewURLShortener(repo, nil, nil, &config.Config{})
+
+	_, err := us.GetClickStats(context.Background(), "short")
+	assert.ErrorIs(t, err, ErrEmptyUserID, "an anonymous request should be rejected")
+	_, err = us.GetClickStats(auth.WithUserID(context.Background(), "another_user"), "short")
+	assert.ErrorIs(t, err, ErrNotURLOwner, "the stats should only be shown to the owner")
+	_, err = us.GetClickStats(auth.WithUserID(context.Background(), "owner"), "unknown")
+	assert.ErrorIs(t, err, ErrURLNotFound)
+
+	stats, err := us.GetClickStats(auth.WithUserID(context.Background(), "owner"), "short")
+	require.NoError(t, err)
+	assert.Equal(t, int64(1), stats.Total)
+}
@@ -0,0 +1,20 @@
+package utils
+
+import "slices"
+
+// shortStringAlphabet holds the URL-safe characters allowed in short codes.
+var shortStringAlphabet = []rune("abcdefghijklmnopqrstuvwxyzABCDEFGHIJKLMNOPQRSTUVWXYZ0123456789-_")
+
+// IsShortString reports whether s is non-empty and consists only of URL-safe characters:
+// letters, digits, '-' and '_'.
+func IsShortString(s string) bool {
+	if s == "" {
+		return false
+	}
+	for _, r := range s {
+		if !slices.Contains(shortStringAlphabet, r) {
+			return false
+		}
+	}
+	return true
+}
@@ -1,21 +1,22 @@
 package utils
 
-import (
-	"testing"
-	"unicode/utf8"
-)
+import "testing"
 
-func TestGetShortRandomString(t *testing.T) {
-	length := 10
-	result := GetShortRandomString(length)
-
-	if utf8.RuneCountInString(result) != length {
-		t.Errorf("Expected string of length %d, but got %d", length, utf8.RuneCountInString(result))
+func TestIsShortString(t *testing.T) {
+	tests := []struct {
+		s    string
+		want bool
+	}{
+		{s: "spring-sale", want: true},
+		{s: "Spring_Sale2", want: true},
+		{s: "", want: false},
+		{s: "spring sale", want: false},
+		{s: "spring/sale", want: false},
+		{s: "весна", want: false},
 	}
-
-	// Check if the string is unique by running the function again
-	secondResult := GetShortRandomString(length)
-	if result == secondResult {
-		t.Errorf("Expected unique strings, but got two identical ones")
+	for _, tt := range tests {
+		if got := IsShortString(tt.s); got != tt.want {
+			t.Errorf("IsShortString(%q) = %v, want %v", tt.s, got, tt.want)
+		}
 	}
 }